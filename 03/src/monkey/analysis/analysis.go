@@ -0,0 +1,350 @@
+package analysis
+
+// 运行前的语义分析：按 object.Environment 的嵌套方式建立词法作用域，
+// 报告未定义的标识符、重复的参数、遮蔽外层绑定和未使用的局部绑定。
+//
+// 作用域规则与求值器一致：程序顶层一个作用域，每个函数（宏）字面量一个作用域，
+// if 的 {} 不产生新作用域。函数体在调用时才执行，因此函数体中可以引用外层作用域
+// 在任意位置定义的名字（例：递归、相互调用）；同一作用域内则必须先定义后使用。
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+	"sort"
+)
+
+// Severity 诊断的严重程度
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// Diagnostic 一条诊断信息，[Pos, End) 是相关的源码范围
+type Diagnostic struct {
+	Pos      token.Position
+	End      token.Position
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", d.Pos, d.Severity, d.Message)
+}
+
+// Config 分析选项
+type Config struct {
+	Globals []string //预先定义的名字，例：内置函数、REPL中已绑定的名字
+}
+
+// 求值器特殊处理的调用，不需要定义
+var specialForms = map[string]bool{"quote": true, "unquote": true}
+
+// Check 分析程序，返回按位置排序的诊断信息
+func Check(program *ast.Program, cfg *Config) []Diagnostic {
+	c := &checker{globals: map[string]bool{}}
+	if cfg != nil {
+		for _, name := range cfg.Globals {
+			c.globals[name] = true
+		}
+	}
+
+	c.openScope(nil)
+	c.hoist(program.Statements)
+	c.statements(program.Statements)
+	c.closeScope(false) //顶层绑定可能被后续程序使用，不报告未使用
+
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		return c.diags[i].Pos.Offset < c.diags[j].Pos.Offset
+	})
+	return c.diags
+}
+
+// HasErrors 诊断信息中是否有错误（不只是警告）
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+type bindingKind int
+
+const (
+	letBinding bindingKind = iota
+	paramBinding
+)
+
+type binding struct {
+	ident *ast.Identifier
+	kind  bindingKind
+	macro bool //let绑定的是宏字面量，调用时参数不求值
+	used  bool
+}
+
+type scope struct {
+	outer    *scope
+	bound    map[string]*binding //已执行到的绑定
+	declared map[string]*binding //该作用域中所有let绑定（含尚未执行到的），供内层函数查找
+	order    []*binding          //按定义顺序，用于报告未使用
+}
+
+type checker struct {
+	scope   *scope
+	globals map[string]bool
+	diags   []Diagnostic
+}
+
+func (c *checker) report(node ast.Node, sev Severity, format string, args ...interface{}) {
+	c.diags = append(c.diags, Diagnostic{
+		Pos:      node.Pos(),
+		End:      node.End(),
+		Severity: sev,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *checker) openScope(params []*ast.Identifier) {
+	c.scope = &scope{
+		outer:    c.scope,
+		bound:    map[string]*binding{},
+		declared: map[string]*binding{},
+	}
+	for _, p := range params {
+		if prev, ok := c.scope.bound[p.Value]; ok {
+			c.report(p, Error, "duplicate parameter %s (previous at %s)", p.Value, prev.ident.Pos())
+			continue
+		}
+		c.checkShadow(p)
+		b := &binding{ident: p, kind: paramBinding}
+		c.scope.bound[p.Value] = b
+		c.scope.order = append(c.scope.order, b)
+	}
+}
+
+func (c *checker) closeScope(reportUnused bool) {
+	if reportUnused {
+		for _, b := range c.scope.order {
+			if !b.used && b.kind == letBinding && b.ident.Value[0] != '_' {
+				c.report(b.ident, Warning, "%s declared and not used", b.ident.Value)
+			}
+		}
+	}
+	c.scope = c.scope.outer
+}
+
+// 预先收集作用域中所有let语句的名字（不进入内层函数）
+func (c *checker) hoist(stmts []ast.Statement) {
+	for _, s := range stmts {
+		ast.Inspect(s, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FunctionLiteral, *ast.MacroLiteral:
+				return false
+			case *ast.LetStatement:
+				if n.Name != nil {
+					if _, ok := c.scope.declared[n.Name.Value]; !ok {
+						_, isMacro := n.Value.(*ast.MacroLiteral)
+						c.scope.declared[n.Name.Value] = &binding{ident: n.Name, kind: letBinding, macro: isMacro}
+					}
+				}
+			case *ast.ImportStatement:
+				ident := importIdent(n)
+				if _, ok := c.scope.declared[ident.Value]; !ok {
+					c.scope.declared[ident.Value] = &binding{ident: ident, kind: letBinding}
+				}
+			}
+			return true
+		})
+	}
+}
+
+// 新绑定与外层函数作用域中的绑定同名时给出警告
+func (c *checker) checkShadow(ident *ast.Identifier) {
+	for s := c.scope.outer; s != nil; s = s.outer {
+		if prev := lookupIn(s, ident.Value); prev != nil {
+			c.report(ident, Warning, "%s shadows declaration at %s", ident.Value, prev.ident.Pos())
+			return
+		}
+	}
+}
+
+func lookupIn(s *scope, name string) *binding {
+	if b, ok := s.bound[name]; ok {
+		return b
+	}
+	return s.declared[name]
+}
+
+// 解析标识符引用：当前作用域中已执行到的绑定，或外层作用域中的任意绑定
+func (c *checker) resolve(ident *ast.Identifier) *binding {
+	if b, ok := c.scope.bound[ident.Value]; ok {
+		return b
+	}
+	for s := c.scope.outer; s != nil; s = s.outer {
+		if b := lookupIn(s, ident.Value); b != nil {
+			return b
+		}
+	}
+	return nil
+}
+
+func (c *checker) use(ident *ast.Identifier) {
+	if b := c.resolve(ident); b != nil {
+		b.used = true
+		return
+	}
+	if c.globals[ident.Value] || specialForms[ident.Value] {
+		return
+	}
+	if later, ok := c.scope.declared[ident.Value]; ok {
+		c.report(ident, Error, "%s used before its declaration at %s", ident.Value, later.ident.Pos())
+		return
+	}
+	c.report(ident, Error, "undefined: %s", ident.Value)
+}
+
+func (c *checker) statements(stmts []ast.Statement) {
+	for _, s := range stmts {
+		c.statement(s)
+	}
+}
+
+func (c *checker) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		c.expr(s.Value) //先对值求值，再绑定名字
+		if s.Name == nil {
+			return
+		}
+		if c.scope.outer != nil {
+			if _, rebound := c.scope.bound[s.Name.Value]; !rebound {
+				c.checkShadow(s.Name)
+			}
+		}
+		b := c.scope.declared[s.Name.Value]
+		if b == nil || b.ident != s.Name { //同一作用域中重复let，建立新的绑定
+			_, isMacro := s.Value.(*ast.MacroLiteral)
+			b = &binding{ident: s.Name, kind: letBinding, macro: isMacro}
+		}
+		c.scope.bound[s.Name.Value] = b
+		c.scope.order = append(c.scope.order, b)
+	case *ast.ImportStatement:
+		b := c.scope.declared[s.Binding()]
+		if b == nil || b.ident.Pos() != importIdent(s).Pos() {
+			b = &binding{ident: importIdent(s), kind: letBinding}
+		}
+		c.scope.bound[b.ident.Value] = b
+		c.scope.order = append(c.scope.order, b)
+	case *ast.ReturnStatement:
+		c.expr(s.ReturnValue)
+	case *ast.ExpressionStatement:
+		c.expr(s.Expression)
+	}
+}
+
+// import绑定的名字，没有别名时位置取路径的位置
+func importIdent(s *ast.ImportStatement) *ast.Identifier {
+	if s.Name != nil {
+		return s.Name
+	}
+	name := s.Binding()
+	return &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name, Pos: s.Path.Pos}, Value: name}
+}
+
+func (c *checker) block(b *ast.BlockStatement) {
+	if b != nil {
+		c.statements(b.Statements)
+	}
+}
+
+func (c *checker) function(params []*ast.Identifier, body *ast.BlockStatement) {
+	c.openScope(params)
+	if body != nil {
+		c.hoist(body.Statements)
+		c.block(body)
+	}
+	c.closeScope(true)
+}
+
+func (c *checker) expr(e ast.Expression) {
+	switch e := e.(type) {
+	case nil:
+	case *ast.Identifier:
+		c.use(e)
+	case *ast.PrefixExpression:
+		c.expr(e.Right)
+	case *ast.InfixExpression:
+		c.expr(e.Left)
+		c.expr(e.Right)
+	case *ast.IfExpression:
+		c.expr(e.Condition)
+		c.block(e.Consequence)
+		c.block(e.Alternative)
+	case *ast.FunctionLiteral:
+		c.function(e.Parameters, e.Body)
+	case *ast.MacroLiteral:
+		c.function(e.Parameters, e.Body)
+	case *ast.SelectorExpression:
+		c.expr(e.X) //成员在运行时按模块的导出查找
+	case *ast.ArrayLiteral:
+		for _, el := range e.Elements {
+			c.expr(el)
+		}
+	case *ast.IndexExpression:
+		c.expr(e.Left)
+		c.expr(e.Index)
+	case *ast.CallExpression:
+		c.call(e)
+	}
+}
+
+func (c *checker) call(e *ast.CallExpression) {
+	if ident, ok := e.Function.(*ast.Identifier); ok {
+		if ident.Value == "quote" { //与求值器一致，quote总是特殊处理
+			for _, a := range e.Arguments {
+				c.unquotes(a)
+			}
+			return
+		}
+		c.use(ident)
+		if b := c.resolve(ident); b != nil && b.macro {
+			return //宏的参数以ast传入，不求值
+		}
+	} else {
+		c.expr(e.Function)
+	}
+	for _, a := range e.Arguments {
+		c.expr(a)
+	}
+}
+
+// quote中的代码不求值，只检查其中unquote的参数
+func (c *checker) unquotes(node ast.Node) {
+	if node == nil {
+		return
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		if ident, ok := call.Function.(*ast.Identifier); ok && ident.Value == "unquote" {
+			for _, a := range call.Arguments {
+				c.expr(a)
+			}
+			return false
+		}
+		return true
+	})
+}
@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func check(t *testing.T, input string, cfg *Config) []string {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	var out []string
+	for _, d := range Check(program, cfg) {
+		out = append(out, d.String())
+	}
+	return out
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let a = 1; let b = a + 1; b;", nil},
+		{"foobar;", []string{"1:1: error: undefined: foobar"}},
+		{"let x = 1;\nif (x > 1) { typo }", []string{"2:14: error: undefined: typo"}},
+		{"x; let x = 1;", []string{"1:1: error: x used before its declaration at 1:8"}},
+		{"let x = x + 1;", []string{"1:9: error: x used before its declaration at 1:5"}},
+		//函数体在调用时才执行：递归和引用后面定义的函数都合法
+		{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10);", nil},
+		{"let f = fn() { g() }; let g = fn() { 1 }; f();", nil},
+		{"let add = fn(x, y, x) { x + y };", []string{"1:20: error: duplicate parameter x (previous at 1:14)"}},
+		{
+			"let x = 1; let f = fn(x) { let y = 2; x + y };",
+			[]string{"1:23: warning: x shadows declaration at 1:5"},
+		},
+		{
+			"let f = fn(a) { let unused = a; let _ignored = 1; a };",
+			[]string{"1:21: warning: unused declared and not used"},
+		},
+		//if中的let与求值器一致，绑定在所在函数的作用域
+		{"let f = fn(c) { if (c) { let v = 1; } v };", nil},
+		//闭包使用外层参数
+		{"let newAdder = fn(x) { fn(y) { x + y } }; newAdder(1)(2);", nil},
+		//import绑定模块名，成员在运行时检查
+		{`import "lib/math"; import m "lib/math"; math.sqrt(m.pow(2, 3));`, nil},
+		{`let f = fn() { math.sqrt(4) }; import "lib/math";`, nil},
+		{`math.sqrt(4); import "lib/math";`, []string{"1:1: error: math used before its declaration at 1:22"}},
+	}
+
+	for _, tt := range tests {
+		got := check(t, tt.input, nil)
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("Check(%q) wrong.\nwant=%q\ngot =%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestCheckMacros(t *testing.T) {
+	input := `
+let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) };
+unless(notDefinedButQuoted > 1, anything, goes);
+quote(foo + unquote(missing));
+`
+	got := check(t, input, nil)
+	if len(got) != 1 || got[0] != "4:21: error: undefined: missing" {
+		t.Fatalf("wrong diagnostics. got=%q", got)
+	}
+}
+
+func TestCheckGlobals(t *testing.T) {
+	if got := check(t, "puts(x)", &Config{Globals: []string{"puts", "x"}}); len(got) != 0 {
+		t.Fatalf("globals should be defined. got=%q", got)
+	}
+	diags := Check(parser.New(lexer.New("y")).ParseProgram(), nil)
+	if !HasErrors(diags) {
+		t.Fatalf("expected errors")
+	}
+	if diags[0].End.Column != 2 {
+		t.Fatalf("wrong diagnostic end. got=%s", diags[0].End)
+	}
+}
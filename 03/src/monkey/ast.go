@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/optimizer"
+	"monkey/parser"
+	"os"
+)
+
+// monkey ast [-json] [-O] file.mk 输出语法分析得到的ast
+func runAST(args []string) int {
+	fs := flag.NewFlagSet("ast", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "以JSON格式输出，包含节点类型和源码范围")
+	optimize := fs.Bool("O", false, "输出展开宏并经过optimizer优化后的ast")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey ast [-json] [-O] file.mk")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	program, ok := parseFile(fs.Arg(0))
+	if !ok {
+		return 1
+	}
+	if *optimize {
+		expanded, err := evaluator.New().ExpandMacros(program)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", fs.Arg(0), err)
+			return 1
+		}
+		program = optimizer.Optimize(expanded.(*ast.Program))
+	}
+
+	if *asJSON {
+		data, err := ast.EncodeIndent(program, "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(string(data))
+		return 0
+	}
+	for _, stmt := range program.Statements {
+		fmt.Println(stmt.String())
+	}
+	return 0
+}
+
+// 读取并解析源码文件，出错时把错误写到标准错误
+func parseFile(path string) (*ast.Program, bool) {
+	src, err := readSource(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, false
+	}
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+		}
+		return nil, false
+	}
+	return program, true
+}
@@ -9,7 +9,9 @@ import (
 // The base Node interfac
 type Node interface { //返回关联词法单元的字面量
 	TokenLiteral() string
-	String() string //调试时打印节点
+	String() string      //调试时打印节点
+	Pos() token.Position //节点第一个字符的位置
+	End() token.Position //节点最后一个字符之后的位置，见position.go
 }
 
 type Statement interface { //ast中一些实现语句接口
@@ -24,6 +26,7 @@ type Expression interface { //ast中一些实现表达式接口
 
 type Program struct {
 	Statements []Statement
+	File       string //源文件，import加载的模块为其路径，不是来自文件时为空
 }
 
 func (p *Program) TokenLiteral() string { //AST根节点
@@ -43,30 +46,82 @@ func (p *Program) String() string { //AST根节点
 
 /*LET 语句 AST结构：LET <标识符> = <表达式>*/
 type LetStatement struct {
-	Token token.Token //token.LET 词法单元
-	Name  *Identifier //标识符
-	Value Expression  //let语句产生值的表达式
+	Token  token.Token    //token.LET 词法单元
+	Export token.Position //export let 中export的位置，零值表示不导出（只能出现在顶层）
+	Name   *Identifier    //标识符
+	Type   TypeExpr       //可选的类型注解 let x: int = 5，没有时为nil
+	Value  Expression     //let语句产生值的表达式
 }
 
 // LetStatement句子节点需要实现的接口
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 func (ls *LetStatement) String() string {
-	var out bytes.Buffer                     //创建一个缓冲区
+	var out bytes.Buffer //创建一个缓冲区
+	if ls.Exported() {
+		out.WriteString("export ")
+	}
 	out.WriteString(ls.TokenLiteral() + " ") //let
 	out.WriteString(ls.Name.String())        //x
-	out.WriteString(" = ")                   //=
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String()) //: int
+	}
+	out.WriteString(" = ") //=
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String()) //5
 	}
+	out.WriteString(";")
+	return out.String()
+
+}
+
+// Exported 是否是 export let 导出的绑定
+func (ls *LetStatement) Exported() bool { return ls.Export.Line != 0 }
+
+/*import 语句 AST结构：import [<别名>] "<模块路径>"，只能出现在顶层*/
+type ImportStatement struct {
+	Token token.Token //token.IMPORT 词法单元
+	Name  *Identifier //可选的别名 import m "lib/math"，没有时为nil
+	Path  token.Token //token.STRING 路径的词法单元，Literal带引号
+	Value string      //去掉引号、转义后的路径 lib/math
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(is.TokenLiteral() + " ")
+	if is.Name != nil {
+		out.WriteString(is.Name.String() + " ")
+	}
+	out.WriteString(is.Path.Literal)
+	out.WriteString(";")
 	return out.String()
+}
 
+// Binding 模块绑定到的名字：别名，没有别名时为路径的最后一段，例："lib/math" -> math
+func (is *ImportStatement) Binding() string {
+	if is.Name != nil {
+		return is.Name.Value
+	}
+	name := is.Value
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, ".mk")
 }
 
 // 标识符
 type Identifier struct {
 	Token token.Token //token.IDENT 词法单元
 	Value string      //为了简单，有些标识符也有值
+	Type  TypeExpr    //函数参数可选的类型注解 fn(a: int)，其他位置为nil
+
+	//由resolver填写：Local为true时，标识符是函数的参数或局部变量，
+	//位于往外Depth层的帧的Slot号槽位；否则按名字在全局环境中查找
+	Local bool
+	Depth int
+	Slot  int
 }
 
 // Identifier 标识符实现的也是表达式节点的接口
@@ -91,6 +146,7 @@ func (rs *ReturnStatement) String() string {
 	if rs.ReturnValue != nil {
 		out.WriteString(rs.ReturnValue.String()) //5
 	}
+	out.WriteString(";")
 	return out.String()
 }
 
@@ -121,6 +177,47 @@ func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+// 字符串字面量 "hello"
+type StringLiteral struct {
+	Token token.Token //token.STRING，Literal是带引号的原文
+	Value string      //去掉引号、转义后的值
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+
+// 数组字面量 [1, 2 * 2, fn(x) { x }]
+type ArrayLiteral struct {
+	Token    token.Token //'['
+	Elements []Expression
+	Rbracket token.Position //']'的位置
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) String() string {
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// 索引表达式 <expression>[<expression>]
+type IndexExpression struct {
+	Token    token.Token //'['
+	Left     Expression
+	Index    Expression
+	Rbracket token.Position //']'的位置
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) String() string {
+	return "(" + ie.Left.String() + "[" + ie.Index.String() + "])"
+}
+
 // 解析表达式-前缀表达式 !-
 type PrefixExpression struct {
 	Token    token.Token //该表达式中第一个词法单元 !-
@@ -179,6 +276,7 @@ func (b *Boolean) String() string {
 type BlockStatement struct {
 	Token      token.Token //{ 大括号
 	Statements []Statement
+	Rbrace     token.Position //} 的位置，语法错误缺失时为零值
 }
 
 func (bs *BlockStatement) expressionNode()      {}
@@ -221,7 +319,9 @@ func (ie *IfExpression) String() string {
 type FunctionLiteral struct {
 	Token      token.Token     //'fn'
 	Parameters []*Identifier   //标识符作为参数
+	ReturnType TypeExpr        //可选的返回类型注解 fn(a: int) -> int，没有时为nil
 	Body       *BlockStatement //函数体
+	Locals     []string        //由resolver填写：帧中各槽位的名字（参数在前），nil表示未解析
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -231,12 +331,19 @@ func (fl *FunctionLiteral) String() string {
 
 	params := []string{} //保存所有参数
 	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+		if p.Type != nil {
+			params = append(params, p.String()+": "+p.Type.String())
+		} else {
+			params = append(params, p.String())
+		}
 	}
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", ")) //输出字符数组，第二个参数为间隔
 	out.WriteString("） ")
+	if fl.ReturnType != nil {
+		out.WriteString("-> " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String()) //输出函数体
 
 	return out.String()
@@ -244,9 +351,10 @@ func (fl *FunctionLiteral) String() string {
 
 // 调用函数 add() 表达式 <expression>(<comma separated expressions>)
 type CallExpression struct {
-	Token     token.Token  //'('词法单元
-	Function  Expression   //标识符或者函数字面量
-	Arguments []Expression //传入参数--表达式语句
+	Token     token.Token    //'('词法单元
+	Function  Expression     //标识符或者函数字面量
+	Arguments []Expression   //传入参数--表达式语句
+	Rparen    token.Position //')'的位置
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -265,3 +373,80 @@ func (ce *CallExpression) String() string {
 
 	return out.String()
 }
+
+// 选择表达式，访问模块导出的成员 math.sqrt
+type SelectorExpression struct {
+	Token token.Token //'.'词法单元
+	X     Expression  //模块
+	Sel   *Identifier //成员名
+}
+
+func (se *SelectorExpression) expressionNode()      {}
+func (se *SelectorExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SelectorExpression) String() string {
+	return se.X.String() + "." + se.Sel.String()
+}
+
+// 宏字面量 macro <parameters> <block statement>，参数在展开时绑定为未求值的ast（quote）
+type MacroLiteral struct {
+	Token      token.Token     //'macro'
+	Parameters []*Identifier   //标识符作为参数
+	Body       *BlockStatement //宏体
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(ml.Body.String())
+
+	return out.String()
+}
+
+/*类型注解，只用于静态类型检查，求值时忽略*/
+
+// 类型注解的表达式，例：int、fn(int, int) -> int
+type TypeExpr interface {
+	Node
+	typeNode()
+}
+
+// 类型名 int bool
+type NamedType struct {
+	Token token.Token //类型名的标识符
+	Name  string
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) String() string       { return nt.Name }
+
+// 函数类型 fn(<参数类型>, ...) -> <返回类型>
+type FunctionType struct {
+	Token      token.Token //'fn'
+	Parameters []TypeExpr
+	Result     TypeExpr
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) String() string {
+	params := []string{}
+	for _, p := range ft.Parameters {
+		params = append(params, p.String())
+	}
+	result := ""
+	if ft.Result != nil {
+		result = ft.Result.String()
+	}
+	return "fn(" + strings.Join(params, ", ") + ") -> " + result
+}
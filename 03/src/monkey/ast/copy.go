@@ -0,0 +1,174 @@
+package ast
+
+// Copy 深拷贝ast，返回的节点与原节点不共享任何子节点，可以用Modify改写而不影响原来的ast
+func Copy(node Node) Node {
+	switch node := node.(type) {
+	case *Program:
+		c := *node
+		c.Statements = copyStatements(node.Statements)
+		return &c
+	case *LetStatement:
+		c := *node
+		c.Name = copyIdentifier(node.Name)
+		c.Type = copyType(node.Type)
+		c.Value = copyExpression(node.Value)
+		return &c
+	case *ReturnStatement:
+		c := *node
+		c.ReturnValue = copyExpression(node.ReturnValue)
+		return &c
+	case *ImportStatement:
+		c := *node
+		c.Name = copyIdentifier(node.Name)
+		return &c
+	case *ExpressionStatement:
+		c := *node
+		c.Expression = copyExpression(node.Expression)
+		return &c
+	case *BlockStatement:
+		return copyBlock(node)
+	case *Identifier:
+		return copyIdentifier(node)
+	case *IntegerLiteral:
+		c := *node
+		return &c
+	case *StringLiteral:
+		c := *node
+		return &c
+	case *Boolean:
+		c := *node
+		return &c
+	case *ArrayLiteral:
+		c := *node
+		c.Elements = copyExpressions(node.Elements)
+		return &c
+	case *IndexExpression:
+		c := *node
+		c.Left = copyExpression(node.Left)
+		c.Index = copyExpression(node.Index)
+		return &c
+	case *PrefixExpression:
+		c := *node
+		c.Right = copyExpression(node.Right)
+		return &c
+	case *InfixExpression:
+		c := *node
+		c.Left = copyExpression(node.Left)
+		c.Right = copyExpression(node.Right)
+		return &c
+	case *IfExpression:
+		c := *node
+		c.Condition = copyExpression(node.Condition)
+		c.Consequence = copyBlock(node.Consequence)
+		c.Alternative = copyBlock(node.Alternative)
+		return &c
+	case *FunctionLiteral:
+		c := *node
+		c.Parameters = copyIdentifiers(node.Parameters)
+		c.ReturnType = copyType(node.ReturnType)
+		c.Body = copyBlock(node.Body)
+		if node.Locals != nil {
+			c.Locals = append([]string{}, node.Locals...)
+		}
+		return &c
+	case *MacroLiteral:
+		c := *node
+		c.Parameters = copyIdentifiers(node.Parameters)
+		c.Body = copyBlock(node.Body)
+		return &c
+	case *CallExpression:
+		c := *node
+		c.Function = copyExpression(node.Function)
+		c.Arguments = copyExpressions(node.Arguments)
+		return &c
+	case *SelectorExpression:
+		c := *node
+		c.X = copyExpression(node.X)
+		c.Sel = copyIdentifier(node.Sel)
+		return &c
+	case *NamedType:
+		c := *node
+		return &c
+	case *FunctionType:
+		c := *node
+		if node.Parameters != nil {
+			c.Parameters = make([]TypeExpr, len(node.Parameters))
+			for i, p := range node.Parameters {
+				c.Parameters[i] = copyType(p)
+			}
+		}
+		c.Result = copyType(node.Result)
+		return &c
+	}
+	return node
+}
+
+func copyStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+	c := make([]Statement, len(stmts))
+	for i, s := range stmts {
+		if s == nil {
+			continue
+		}
+		c[i], _ = Copy(s).(Statement)
+	}
+	return c
+}
+
+func copyExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+	c := make([]Expression, len(exps))
+	for i, e := range exps {
+		c[i] = copyExpression(e)
+	}
+	return c
+}
+
+func copyExpression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+	c, _ := Copy(exp).(Expression)
+	return c
+}
+
+func copyBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	c := *block
+	c.Statements = copyStatements(block.Statements)
+	return &c
+}
+
+func copyIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+	c := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		c[i] = copyIdentifier(ident)
+	}
+	return c
+}
+
+func copyIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	c := *ident
+	c.Type = copyType(ident.Type)
+	return &c
+}
+
+func copyType(typ TypeExpr) TypeExpr {
+	if typ == nil {
+		return nil
+	}
+	c, _ := Copy(typ).(TypeExpr)
+	return c
+}
@@ -0,0 +1,40 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: ident("f"), Value: &FunctionLiteral{
+			Parameters: []*Identifier{ident("x")},
+			ReturnType: &NamedType{Name: "int"},
+			Body: block(exprStmt(&IfExpression{
+				Condition:   &InfixExpression{Left: ident("x"), Operator: "<", Right: integer(1)},
+				Consequence: block(exprStmt(&PrefixExpression{Operator: "-", Right: integer(1)})),
+			})),
+		}},
+		exprStmt(&CallExpression{Function: ident("f"), Arguments: []Expression{integer(1)}}),
+	}}
+	original := program.String()
+
+	c, ok := Copy(program).(*Program)
+	if !ok || !reflect.DeepEqual(c, program) {
+		t.Fatalf("copy not equal: got %s, want %s", c, program)
+	}
+
+	//改写拷贝不影响原来的ast
+	Modify(c, func(node Node) Node {
+		if lit, ok := node.(*IntegerLiteral); ok {
+			return integer(lit.Value + 1)
+		}
+		return node
+	})
+	if program.String() != original {
+		t.Errorf("original modified: got %s, want %s", program.String(), original)
+	}
+	if c.String() == original {
+		t.Errorf("copy not modified: %s", c.String())
+	}
+}
@@ -0,0 +1,508 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"monkey/token"
+)
+
+// ast与JSON互相转换，用于缓存语法分析结果或交给外部工具。
+// 每个节点编码为一个JSON对象：
+//   {"type": "InfixExpression", "span": {"start": {...}, "end": {...}}, "token": {...}, "left": ..., "operator": "+", "right": ...}
+// type区分节点类型，span是源码范围（见position.go），token保留原始词法单元，nil子节点编码为null。
+// Decode(Encode(node)) 与原节点结构相同
+
+// Encode 把ast编码为JSON
+func Encode(node Node) ([]byte, error) {
+	return json.Marshal(encodeNode(node))
+}
+
+// EncodeIndent 同Encode，输出带缩进
+func EncodeIndent(node Node, indent string) ([]byte, error) {
+	return json.MarshalIndent(encodeNode(node), "", indent)
+}
+
+// 保持字段顺序的JSON对象，"type"总在最前
+type jsonObject []jsonField
+
+type jsonField struct {
+	key   string
+	value interface{}
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type jsonSpan struct {
+	Start token.Position `json:"start"`
+	End   token.Position `json:"end"`
+}
+
+func encodeNode(node Node) interface{} {
+	if node == nil {
+		return nil
+	}
+	obj := jsonObject{
+		{"type", nodeTypeName(node)},
+		{"span", jsonSpan{Start: node.Pos(), End: node.End()}},
+	}
+	add := func(key string, value interface{}) {
+		obj = append(obj, jsonField{key, value})
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		add("statements", encodeStatements(n.Statements))
+		if n.File != "" {
+			add("file", n.File)
+		}
+	case *LetStatement:
+		add("token", n.Token)
+		add("export", n.Export)
+		add("name", encodeIdentifier(n.Name))
+		add("annotation", encodeNode(n.Type))
+		add("value", encodeNode(n.Value))
+	case *ImportStatement:
+		add("token", n.Token)
+		add("name", encodeIdentifier(n.Name))
+		add("path", n.Path)
+		add("value", n.Value)
+	case *ReturnStatement:
+		add("token", n.Token)
+		add("returnValue", encodeNode(n.ReturnValue))
+	case *ExpressionStatement:
+		add("token", n.Token)
+		add("expression", encodeNode(n.Expression))
+	case *BlockStatement:
+		add("token", n.Token)
+		add("statements", encodeStatements(n.Statements))
+		add("rbrace", n.Rbrace)
+	case *Identifier:
+		add("token", n.Token)
+		add("value", n.Value)
+		add("annotation", encodeNode(n.Type))
+	case *IntegerLiteral:
+		add("token", n.Token)
+		add("value", n.Value)
+	case *Boolean:
+		add("token", n.Token)
+		add("value", n.Value)
+	case *StringLiteral:
+		add("token", n.Token)
+		add("value", n.Value)
+	case *ArrayLiteral:
+		add("token", n.Token)
+		add("elements", encodeExpressions(n.Elements))
+		add("rbracket", n.Rbracket)
+	case *IndexExpression:
+		add("token", n.Token)
+		add("left", encodeNode(n.Left))
+		add("index", encodeNode(n.Index))
+		add("rbracket", n.Rbracket)
+	case *PrefixExpression:
+		add("token", n.Token)
+		add("operator", n.Operator)
+		add("right", encodeNode(n.Right))
+	case *InfixExpression:
+		add("token", n.Token)
+		add("left", encodeNode(n.Left))
+		add("operator", n.Operator)
+		add("right", encodeNode(n.Right))
+	case *IfExpression:
+		add("token", n.Token)
+		add("condition", encodeNode(n.Condition))
+		add("consequence", encodeBlock(n.Consequence))
+		add("alternative", encodeBlock(n.Alternative))
+	case *FunctionLiteral:
+		add("token", n.Token)
+		add("parameters", encodeIdentifiers(n.Parameters))
+		add("returnType", encodeNode(n.ReturnType))
+		add("body", encodeBlock(n.Body))
+	case *MacroLiteral:
+		add("token", n.Token)
+		add("parameters", encodeIdentifiers(n.Parameters))
+		add("body", encodeBlock(n.Body))
+	case *CallExpression:
+		add("token", n.Token)
+		add("function", encodeNode(n.Function))
+		add("arguments", encodeExpressions(n.Arguments))
+		add("rparen", n.Rparen)
+	case *SelectorExpression:
+		add("token", n.Token)
+		add("x", encodeNode(n.X))
+		add("sel", encodeIdentifier(n.Sel))
+	case *NamedType:
+		add("token", n.Token)
+		add("name", n.Name)
+	case *FunctionType:
+		add("token", n.Token)
+		add("parameters", encodeTypes(n.Parameters))
+		add("result", encodeNode(n.Result))
+	default:
+		panic(fmt.Sprintf("ast.Encode: unexpected node type %T", n))
+	}
+	return obj
+}
+
+// 节点类型名，去掉包名和指针，例：*ast.LetStatement -> LetStatement
+func nodeTypeName(node Node) string {
+	name := fmt.Sprintf("%T", node)
+	return name[len("*ast."):]
+}
+
+// 指针类型的子节点为nil时要编码为null，不能直接转为Node接口
+func encodeBlock(b *BlockStatement) interface{} {
+	if b == nil {
+		return nil
+	}
+	return encodeNode(b)
+}
+
+func encodeIdentifier(i *Identifier) interface{} {
+	if i == nil {
+		return nil
+	}
+	return encodeNode(i)
+}
+
+func encodeStatements(stmts []Statement) []interface{} {
+	out := make([]interface{}, 0, len(stmts))
+	for _, s := range stmts {
+		if isNilStatement(s) { //语法错误时的nil语句编码为null
+			out = append(out, nil)
+			continue
+		}
+		out = append(out, encodeNode(s))
+	}
+	return out
+}
+
+func encodeExpressions(exps []Expression) interface{} {
+	if exps == nil { //语法错误时参数列表为nil，与空列表区分
+		return nil
+	}
+	out := make([]interface{}, 0, len(exps))
+	for _, e := range exps {
+		out = append(out, encodeNode(e))
+	}
+	return out
+}
+
+func encodeIdentifiers(idents []*Identifier) interface{} {
+	if idents == nil {
+		return nil
+	}
+	out := make([]interface{}, 0, len(idents))
+	for _, i := range idents {
+		out = append(out, encodeIdentifier(i))
+	}
+	return out
+}
+
+func encodeTypes(types []TypeExpr) interface{} {
+	if types == nil {
+		return nil
+	}
+	out := make([]interface{}, 0, len(types))
+	for _, t := range types {
+		out = append(out, encodeNode(t))
+	}
+	return out
+}
+
+// Decode 从Encode的输出重建ast
+func Decode(data []byte) (Node, error) {
+	return decodeNode(data)
+}
+
+// 解码用的JSON对象，字段按需再解码
+type rawObject map[string]json.RawMessage
+
+func isNull(data json.RawMessage) bool {
+	return len(data) == 0 || string(data) == "null"
+}
+
+func decodeNode(data json.RawMessage) (Node, error) {
+	if isNull(data) {
+		return nil, nil
+	}
+	var obj rawObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	var typ string
+	if err := json.Unmarshal(obj["type"], &typ); err != nil {
+		return nil, fmt.Errorf("ast.Decode: missing node type: %v", err)
+	}
+
+	d := &decoder{obj: obj, typ: typ}
+	var node Node
+	switch typ {
+	case "Program":
+		n := &Program{Statements: d.statements("statements")}
+		d.value("file", &n.File)
+		node = n
+	case "LetStatement":
+		node = &LetStatement{Token: d.token(), Export: d.position("export"), Name: d.identifier("name"), Type: d.typeExpr("annotation"), Value: d.expression("value")}
+	case "ImportStatement":
+		n := &ImportStatement{Token: d.token(), Name: d.identifier("name")}
+		d.value("path", &n.Path)
+		d.value("value", &n.Value)
+		node = n
+	case "ReturnStatement":
+		node = &ReturnStatement{Token: d.token(), ReturnValue: d.expression("returnValue")}
+	case "ExpressionStatement":
+		node = &ExpressionStatement{Token: d.token(), Expression: d.expression("expression")}
+	case "BlockStatement":
+		node = &BlockStatement{Token: d.token(), Statements: d.statements("statements"), Rbrace: d.position("rbrace")}
+	case "Identifier":
+		n := &Identifier{Token: d.token(), Type: d.typeExpr("annotation")}
+		d.value("value", &n.Value)
+		node = n
+	case "IntegerLiteral":
+		n := &IntegerLiteral{Token: d.token()}
+		d.value("value", &n.Value)
+		node = n
+	case "Boolean":
+		n := &Boolean{Token: d.token()}
+		d.value("value", &n.Value)
+		node = n
+	case "StringLiteral":
+		n := &StringLiteral{Token: d.token()}
+		d.value("value", &n.Value)
+		node = n
+	case "ArrayLiteral":
+		node = &ArrayLiteral{Token: d.token(), Elements: d.expressions("elements"), Rbracket: d.position("rbracket")}
+	case "IndexExpression":
+		node = &IndexExpression{
+			Token:    d.token(),
+			Left:     d.expression("left"),
+			Index:    d.expression("index"),
+			Rbracket: d.position("rbracket"),
+		}
+	case "PrefixExpression":
+		n := &PrefixExpression{Token: d.token(), Right: d.expression("right")}
+		d.value("operator", &n.Operator)
+		node = n
+	case "InfixExpression":
+		n := &InfixExpression{Token: d.token(), Left: d.expression("left"), Right: d.expression("right")}
+		d.value("operator", &n.Operator)
+		node = n
+	case "IfExpression":
+		node = &IfExpression{
+			Token:       d.token(),
+			Condition:   d.expression("condition"),
+			Consequence: d.block("consequence"),
+			Alternative: d.block("alternative"),
+		}
+	case "FunctionLiteral":
+		node = &FunctionLiteral{
+			Token:      d.token(),
+			Parameters: d.identifiers("parameters"),
+			ReturnType: d.typeExpr("returnType"),
+			Body:       d.block("body"),
+		}
+	case "MacroLiteral":
+		node = &MacroLiteral{Token: d.token(), Parameters: d.identifiers("parameters"), Body: d.block("body")}
+	case "CallExpression":
+		node = &CallExpression{
+			Token:     d.token(),
+			Function:  d.expression("function"),
+			Arguments: d.expressions("arguments"),
+			Rparen:    d.position("rparen"),
+		}
+	case "SelectorExpression":
+		node = &SelectorExpression{Token: d.token(), X: d.expression("x"), Sel: d.identifier("sel")}
+	case "NamedType":
+		n := &NamedType{Token: d.token()}
+		d.value("name", &n.Name)
+		node = n
+	case "FunctionType":
+		node = &FunctionType{Token: d.token(), Parameters: d.types("parameters"), Result: d.typeExpr("result")}
+	default:
+		return nil, fmt.Errorf("ast.Decode: unknown node type %q", typ)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return node, nil
+}
+
+// 解码一个节点的各字段，只记录第一个错误
+type decoder struct {
+	obj rawObject
+	typ string
+	err error
+}
+
+func (d *decoder) fail(key string, err error) {
+	if d.err == nil {
+		d.err = fmt.Errorf("ast.Decode: %s.%s: %v", d.typ, key, err)
+	}
+}
+
+// 缺少的字段保持零值
+func (d *decoder) value(key string, v interface{}) {
+	data, ok := d.obj[key]
+	if !ok {
+		return
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		d.fail(key, err)
+	}
+}
+
+func (d *decoder) token() token.Token {
+	var tok token.Token
+	d.value("token", &tok)
+	return tok
+}
+
+func (d *decoder) position(key string) token.Position {
+	var pos token.Position
+	d.value(key, &pos)
+	return pos
+}
+
+func (d *decoder) node(key string, data json.RawMessage) Node {
+	n, err := decodeNode(data)
+	if err != nil {
+		d.fail(key, err)
+	}
+	return n
+}
+
+func (d *decoder) list(key string) []json.RawMessage {
+	if isNull(d.obj[key]) {
+		return nil
+	}
+	items := []json.RawMessage{}
+	d.value(key, &items)
+	return items
+}
+
+func (d *decoder) expression(key string) Expression {
+	n := d.node(key, d.obj[key])
+	if n == nil {
+		return nil
+	}
+	e, ok := n.(Expression)
+	if !ok {
+		d.fail(key, fmt.Errorf("%T is not an expression", n))
+	}
+	return e
+}
+
+func (d *decoder) identifier(key string) *Identifier {
+	return d.asIdentifier(key, d.node(key, d.obj[key]))
+}
+
+func (d *decoder) asIdentifier(key string, n Node) *Identifier {
+	if n == nil {
+		return nil
+	}
+	i, ok := n.(*Identifier)
+	if !ok {
+		d.fail(key, fmt.Errorf("%T is not an identifier", n))
+	}
+	return i
+}
+
+func (d *decoder) block(key string) *BlockStatement {
+	n := d.node(key, d.obj[key])
+	if n == nil {
+		return nil
+	}
+	b, ok := n.(*BlockStatement)
+	if !ok {
+		d.fail(key, fmt.Errorf("%T is not a block statement", n))
+	}
+	return b
+}
+
+func (d *decoder) statements(key string) []Statement {
+	stmts := []Statement{}
+	for _, item := range d.list(key) {
+		n := d.node(key, item)
+		s, ok := n.(Statement)
+		if n != nil && !ok {
+			d.fail(key, fmt.Errorf("%T is not a statement", n))
+		}
+		stmts = append(stmts, s)
+	}
+	return stmts
+}
+
+func (d *decoder) expressions(key string) []Expression {
+	items := d.list(key)
+	if items == nil {
+		return nil
+	}
+	exps := []Expression{}
+	for _, item := range items {
+		n := d.node(key, item)
+		e, ok := n.(Expression)
+		if n != nil && !ok {
+			d.fail(key, fmt.Errorf("%T is not an expression", n))
+		}
+		exps = append(exps, e)
+	}
+	return exps
+}
+
+func (d *decoder) identifiers(key string) []*Identifier {
+	items := d.list(key)
+	if items == nil {
+		return nil
+	}
+	idents := []*Identifier{}
+	for _, item := range items {
+		idents = append(idents, d.asIdentifier(key, d.node(key, item)))
+	}
+	return idents
+}
+
+func (d *decoder) typeExpr(key string) TypeExpr {
+	return d.asType(key, d.node(key, d.obj[key]))
+}
+
+func (d *decoder) asType(key string, n Node) TypeExpr {
+	if n == nil {
+		return nil
+	}
+	t, ok := n.(TypeExpr)
+	if !ok {
+		d.fail(key, fmt.Errorf("%T is not a type", n))
+	}
+	return t
+}
+
+func (d *decoder) types(key string) []TypeExpr {
+	items := d.list(key)
+	if items == nil {
+		return nil
+	}
+	types := []TypeExpr{}
+	for _, item := range items {
+		types = append(types, d.asType(key, d.node(key, item)))
+	}
+	return types
+}
@@ -0,0 +1,159 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var roundTripInputs = []string{
+	"5; true; false; -a; !b;",
+	"let x = 1 + 2 * (3 + 4) / 5 - 6; x;",
+	"let add = fn(x, y) { return x + y; }; add(1, add(2, 3));",
+	"let max = fn(a, b) { if (a > b) { a } else { b } }; max(3, 7) == 7;",
+	"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(40);",
+	"fn(x) { x == 10 }(10); if (1 < 2) { 10 };",
+	"let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) }; unless(1 > 2, 3, 4);",
+	"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(15);",
+	"let twice: fn(fn(int) -> int, int) -> int = fn(f: fn(int) -> int, x: int) -> int { f(f(x)) }; twice(fn(y) { y * 2 }, 5);",
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func roundTrip(t *testing.T, node ast.Node) ast.Node {
+	t.Helper()
+	data, err := ast.Encode(node)
+	if err != nil {
+		t.Fatalf("Encode error: %s", err)
+	}
+	decoded, err := ast.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode error: %s\n%s", err, data)
+	}
+	return decoded
+}
+
+func run(program ast.Node) object.Object {
+	in := evaluator.New()
+	expanded, err := in.ExpandMacros(program.(*ast.Program))
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return in.Eval(expanded)
+}
+
+// Decode(Encode(p)) 结构与原程序相同，且求值结果相同
+func TestJSONRoundTrip(t *testing.T) {
+	for _, input := range roundTripInputs {
+		program := parse(t, input)
+		decoded := roundTrip(t, program)
+
+		if !reflect.DeepEqual(program, decoded) {
+			t.Errorf("round trip changed the ast for %q.\nwant=%s\ngot =%s", input, program, decoded)
+			continue
+		}
+
+		want := run(parse(t, input))
+		got := run(decoded)
+		if want.Inspect() != got.Inspect() {
+			t.Errorf("round trip changed the result of %q. want=%s, got=%s", input, want.Inspect(), got.Inspect())
+		}
+	}
+}
+
+// 语法错误产生的nil子节点编码为null并原样还原
+func TestJSONRoundTripNilChildren(t *testing.T) {
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.ExpressionStatement{},
+		&ast.LetStatement{Name: &ast.Identifier{Value: "x"}},
+		&ast.ExpressionStatement{Expression: &ast.CallExpression{Function: &ast.Identifier{Value: "f"}}},
+	}}
+	decoded := roundTrip(t, program)
+	if !reflect.DeepEqual(program, decoded) {
+		t.Fatalf("round trip changed the ast. want=%#v, got=%#v", program, decoded)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	program := parse(t, "let x = 1 + y;")
+	data, err := ast.Encode(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), `{"type":"Program","span":{"start":{"offset":0,"line":1,"column":1},"end":{"offset":13,"line":1,"column":14}}`) {
+		t.Fatalf("unexpected encoding prefix: %s", data)
+	}
+
+	var generic map[string]interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		t.Fatal(err)
+	}
+	let := generic["statements"].([]interface{})[0].(map[string]interface{})
+	if let["type"] != "LetStatement" {
+		t.Fatalf("wrong statement type. got=%v", let["type"])
+	}
+	value := let["value"].(map[string]interface{})
+	if value["type"] != "InfixExpression" || value["operator"] != "+" {
+		t.Fatalf("wrong value encoding. got=%v", value)
+	}
+	tok := value["token"].(map[string]interface{})
+	if tok["type"] != "+" || tok["literal"] != "+" {
+		t.Fatalf("wrong token encoding. got=%v", tok)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"type":"Nope"}`, `unknown node type "Nope"`},
+		{`{"statements":[]}`, "missing node type"},
+		{`{"type":"LetStatement","name":{"type":"IntegerLiteral","value":1},"value":null}`, "is not an identifier"},
+		{`{"type":"Identifier","token":{"type":"???"}}`, `unknown token type "???"`},
+	}
+	for _, tt := range tests {
+		_, err := ast.Decode([]byte(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("wrong error for %s. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+// 节点的源码范围
+func TestNodeSpans(t *testing.T) {
+	input := "let f = fn(x) {\n  x * 2\n};\nf(3)"
+	program := parse(t, input)
+
+	tests := []struct {
+		node ast.Node
+		text string
+	}{
+		{program.Statements[0], "let f = fn(x) {\n  x * 2\n}"},
+		{program.Statements[0].(*ast.LetStatement).Value, "fn(x) {\n  x * 2\n}"},
+		{program.Statements[1], "f(3)"},
+	}
+	for _, tt := range tests {
+		start, end := tt.node.Pos(), tt.node.End()
+		if got := input[start.Offset:end.Offset]; got != tt.text {
+			t.Errorf("wrong span for %T. want=%q, got=%q", tt.node, tt.text, got)
+		}
+	}
+	if end := program.End(); end.Line != 4 || end.Column != 5 {
+		t.Errorf("wrong program end. got=%s", end)
+	}
+}
@@ -0,0 +1,120 @@
+package ast
+
+// ModifierFunc 接收一个节点，返回替换它的节点（原样返回则不修改）
+type ModifierFunc func(Node) Node
+
+// Modify 自底向上改写ast：先改写子节点，再对节点本身调用modifier，返回改写后的节点。
+// 节点原地修改；若modifier在某个位置返回了不合适的节点类型（例：把语句换成表达式），该位置保持原样
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	case *Program:
+		modifyStatements(node.Statements, modifier)
+	case *LetStatement:
+		node.Name = modifyIdentifier(node.Name, modifier)
+		node.Type = modifyType(node.Type, modifier)
+		node.Value = modifyExpression(node.Value, modifier)
+	case *ImportStatement:
+		node.Name = modifyIdentifier(node.Name, modifier)
+	case *ReturnStatement:
+		node.ReturnValue = modifyExpression(node.ReturnValue, modifier)
+	case *ExpressionStatement:
+		node.Expression = modifyExpression(node.Expression, modifier)
+	case *BlockStatement:
+		modifyStatements(node.Statements, modifier)
+	case *PrefixExpression:
+		node.Right = modifyExpression(node.Right, modifier)
+	case *InfixExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Right = modifyExpression(node.Right, modifier)
+	case *IfExpression:
+		node.Condition = modifyExpression(node.Condition, modifier)
+		node.Consequence = modifyBlock(node.Consequence, modifier)
+		node.Alternative = modifyBlock(node.Alternative, modifier)
+	case *FunctionLiteral:
+		for i, p := range node.Parameters {
+			node.Parameters[i] = modifyIdentifier(p, modifier)
+		}
+		node.ReturnType = modifyType(node.ReturnType, modifier)
+		node.Body = modifyBlock(node.Body, modifier)
+	case *MacroLiteral:
+		for i, p := range node.Parameters {
+			node.Parameters[i] = modifyIdentifier(p, modifier)
+		}
+		node.Body = modifyBlock(node.Body, modifier)
+	case *CallExpression:
+		node.Function = modifyExpression(node.Function, modifier)
+		for i, a := range node.Arguments {
+			node.Arguments[i] = modifyExpression(a, modifier)
+		}
+	case *ArrayLiteral:
+		for i, el := range node.Elements {
+			node.Elements[i] = modifyExpression(el, modifier)
+		}
+	case *IndexExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Index = modifyExpression(node.Index, modifier)
+	case *SelectorExpression:
+		node.X = modifyExpression(node.X, modifier)
+		node.Sel = modifyIdentifier(node.Sel, modifier)
+	case *Identifier:
+		node.Type = modifyType(node.Type, modifier)
+	case *FunctionType:
+		for i, p := range node.Parameters {
+			node.Parameters[i] = modifyType(p, modifier)
+		}
+		node.Result = modifyType(node.Result, modifier)
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(stmts []Statement, modifier ModifierFunc) {
+	for i, s := range stmts {
+		if isNilStatement(s) {
+			continue
+		}
+		if m, ok := Modify(s, modifier).(Statement); ok {
+			stmts[i] = m
+		}
+	}
+}
+
+func modifyExpression(exp Expression, modifier ModifierFunc) Expression {
+	if exp == nil {
+		return nil
+	}
+	if m, ok := Modify(exp, modifier).(Expression); ok {
+		return m
+	}
+	return exp
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	if m, ok := Modify(block, modifier).(*BlockStatement); ok {
+		return m
+	}
+	return block
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	if m, ok := Modify(ident, modifier).(*Identifier); ok {
+		return m
+	}
+	return ident
+}
+
+func modifyType(typ TypeExpr, modifier ModifierFunc) TypeExpr {
+	if typ == nil {
+		return nil
+	}
+	if m, ok := Modify(typ, modifier).(TypeExpr); ok {
+		return m
+	}
+	return typ
+}
@@ -0,0 +1,120 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return integer(1) }
+	two := func() Expression { return integer(2) }
+
+	turnOneIntoTwo := func(node Node) Node {
+		lit, ok := node.(*IntegerLiteral)
+		if !ok || lit.Value != 1 {
+			return node
+		}
+		return two()
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{exprStmt(one())}},
+			&Program{Statements: []Statement{exprStmt(two())}},
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&InfixExpression{Left: two(), Operator: "+", Right: one()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&PrefixExpression{Operator: "-", Right: one()},
+			&PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&IfExpression{
+				Condition:   one(),
+				Consequence: block(exprStmt(one())),
+				Alternative: block(exprStmt(one())),
+			},
+			&IfExpression{
+				Condition:   two(),
+				Consequence: block(exprStmt(two())),
+				Alternative: block(exprStmt(two())),
+			},
+		},
+		{
+			&ReturnStatement{ReturnValue: one()},
+			&ReturnStatement{ReturnValue: two()},
+		},
+		{
+			&LetStatement{Name: ident("x"), Value: one()},
+			&LetStatement{Name: ident("x"), Value: two()},
+		},
+		{
+			&FunctionLiteral{Parameters: []*Identifier{}, Body: block(exprStmt(one()))},
+			&FunctionLiteral{Parameters: []*Identifier{}, Body: block(exprStmt(two()))},
+		},
+		{
+			&MacroLiteral{Parameters: []*Identifier{}, Body: block(exprStmt(one()))},
+			&MacroLiteral{Parameters: []*Identifier{}, Body: block(exprStmt(two()))},
+		},
+		{
+			&CallExpression{Function: ident("f"), Arguments: []Expression{one(), two()}},
+			&CallExpression{Function: ident("f"), Arguments: []Expression{two(), two()}},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, turnOneIntoTwo)
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal. got=%#v, want=%#v", modified, tt.expected)
+		}
+	}
+}
+
+// 改写标识符（例：重命名），以及替换成错误类型时保持原样
+func TestModifyIdentifiers(t *testing.T) {
+	program := sampleProgram()
+	rename := func(node Node) Node {
+		if id, ok := node.(*Identifier); ok && id.Value == "x" {
+			return ident("y")
+		}
+		return node
+	}
+	Modify(program, rename)
+
+	count := 0
+	Inspect(program, func(n Node) bool {
+		if id, ok := n.(*Identifier); ok {
+			if id.Value == "x" {
+				t.Errorf("identifier x not renamed")
+			}
+			if id.Value == "y" {
+				count++
+			}
+		}
+		return true
+	})
+	if count != 4 { //参数1个+函数体3个
+		t.Errorf("wrong number of renamed identifiers. got=%d", count)
+	}
+
+	stmt := &LetStatement{Name: ident("x"), Value: integer(1)}
+	Modify(stmt, func(node Node) Node {
+		if _, ok := node.(*Identifier); ok {
+			return integer(3) //名字位置不能放整数
+		}
+		return node
+	})
+	if stmt.Name == nil || stmt.Name.Value != "x" {
+		t.Errorf("let name should be kept. got=%v", stmt.Name)
+	}
+}
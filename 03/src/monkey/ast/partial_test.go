@@ -0,0 +1,64 @@
+package ast_test
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+// 语法错误的程序（例：编辑器中输入到一半）得到的不完整ast
+var partialInputs = []string{
+	"let",
+	"let f = fn(x) { let",
+	"let f = fn(x) {\n let",
+	"let f = fn(x) { let y = ; return",
+	"if (x) { let } else { let = 1 }",
+	"export let",
+}
+
+func parsePartial(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatalf("%q: expected parse errors", input)
+	}
+	return program
+}
+
+func TestWalkPartialProgram(t *testing.T) {
+	for _, input := range partialInputs {
+		program := parsePartial(t, input)
+		depth := 0
+		ast.Inspect(program, func(n ast.Node) bool {
+			if n == nil {
+				depth--
+			} else {
+				depth++
+			}
+			return true
+		})
+		if depth != 0 {
+			t.Errorf("%q: enter/leave not balanced. depth=%d", input, depth)
+		}
+		ast.Modify(program, func(n ast.Node) ast.Node { return n })
+	}
+}
+
+func TestEncodePartialProgram(t *testing.T) {
+	for _, input := range partialInputs {
+		program := parsePartial(t, input)
+		if end := program.End(); end.Offset > len(input) {
+			t.Errorf("%q: end %s beyond the input", input, end)
+		}
+		data, err := ast.Encode(program)
+		if err != nil {
+			t.Errorf("%q: %s", input, err)
+			continue
+		}
+		if _, err := ast.Decode(data); err != nil {
+			t.Errorf("%q: decode: %s", input, err)
+		}
+	}
+}
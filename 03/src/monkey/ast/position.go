@@ -0,0 +1,232 @@
+package ast
+
+import "monkey/token"
+
+// 各节点的源码范围 [Pos, End)。由节点中保存的词法单元推算，
+// 分组表达式的括号和语句结尾的分号不在ast中，因此不计入范围。
+// 没有位置信息的节点（例：宏展开时生成的节点）返回零值
+
+// 词法单元最后一个字符之后的位置，词法单元不跨行
+func tokenEnd(tok token.Token) token.Position {
+	if tok.Pos.Line == 0 {
+		return token.Position{}
+	}
+	n := len(tok.Literal)
+	return token.Position{Offset: tok.Pos.Offset + n, Line: tok.Pos.Line, Column: tok.Pos.Column + n}
+}
+
+// 单个字符的结尾位置，例：} )
+func charEnd(pos token.Position) token.Position {
+	if pos.Line == 0 {
+		return token.Position{}
+	}
+	return token.Position{Offset: pos.Offset + 1, Line: pos.Line, Column: pos.Column + 1}
+}
+
+// 表达式可能为nil（语法错误），为nil时使用fallback
+func exprEnd(e Expression, fallback token.Position) token.Position {
+	if e == nil {
+		return fallback
+	}
+	return e.End()
+}
+
+// 第一个和最后一个非nil的语句，语法错误时语句可能为nil，见isNilStatement
+func firstStatement(stmts []Statement) Statement {
+	for _, s := range stmts {
+		if !isNilStatement(s) {
+			return s
+		}
+	}
+	return nil
+}
+
+func lastStatement(stmts []Statement) Statement {
+	for i := len(stmts) - 1; i >= 0; i-- {
+		if !isNilStatement(stmts[i]) {
+			return stmts[i]
+		}
+	}
+	return nil
+}
+
+func (p *Program) Pos() token.Position {
+	if s := firstStatement(p.Statements); s != nil {
+		return s.Pos()
+	}
+	return token.Position{}
+}
+func (p *Program) End() token.Position {
+	if s := lastStatement(p.Statements); s != nil {
+		return s.End()
+	}
+	return token.Position{}
+}
+
+func (ls *LetStatement) Pos() token.Position {
+	if ls.Exported() {
+		return ls.Export
+	}
+	return ls.Token.Pos
+}
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	if ls.Type != nil {
+		return ls.Type.End()
+	}
+	if ls.Name != nil {
+		return ls.Name.End()
+	}
+	return tokenEnd(ls.Token)
+}
+
+func (is *ImportStatement) Pos() token.Position { return is.Token.Pos }
+func (is *ImportStatement) End() token.Position {
+	if is.Path.Pos.Line != 0 {
+		return tokenEnd(is.Path)
+	}
+	if is.Name != nil {
+		return is.Name.End()
+	}
+	return tokenEnd(is.Token)
+}
+
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
+func (i *Identifier) End() token.Position {
+	if i.Type != nil { //参数的类型注解 a: int
+		return i.Type.End()
+	}
+	return tokenEnd(i.Token)
+}
+
+func (rs *ReturnStatement) Pos() token.Position { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position {
+	return exprEnd(rs.ReturnValue, tokenEnd(rs.Token))
+}
+
+func (es *ExpressionStatement) Pos() token.Position { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Position {
+	return exprEnd(es.Expression, tokenEnd(es.Token))
+}
+
+func (il *IntegerLiteral) Pos() token.Position { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position { return tokenEnd(il.Token) }
+
+func (sl *StringLiteral) Pos() token.Position { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position { return tokenEnd(sl.Token) }
+
+func (al *ArrayLiteral) Pos() token.Position { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position {
+	if al.Rbracket.Line != 0 {
+		return charEnd(al.Rbracket)
+	}
+	return tokenEnd(al.Token)
+}
+
+func (ie *IndexExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+func (ie *IndexExpression) End() token.Position {
+	if ie.Rbracket.Line != 0 {
+		return charEnd(ie.Rbracket)
+	}
+	return exprEnd(ie.Index, tokenEnd(ie.Token))
+}
+
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position {
+	return exprEnd(pe.Right, tokenEnd(pe.Token))
+}
+
+func (ie *InfixExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+func (ie *InfixExpression) End() token.Position {
+	return exprEnd(ie.Right, tokenEnd(ie.Token))
+}
+
+func (b *Boolean) Pos() token.Position { return b.Token.Pos }
+func (b *Boolean) End() token.Position { return tokenEnd(b.Token) }
+
+func (bs *BlockStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position {
+	if bs.Rbrace.Line != 0 {
+		return charEnd(bs.Rbrace)
+	}
+	if s := lastStatement(bs.Statements); s != nil {
+		return s.End()
+	}
+	return tokenEnd(bs.Token)
+}
+
+func (ie *IfExpression) Pos() token.Position { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	switch {
+	case ie.Alternative != nil:
+		return ie.Alternative.End()
+	case ie.Consequence != nil:
+		return ie.Consequence.End()
+	}
+	return exprEnd(ie.Condition, tokenEnd(ie.Token))
+}
+
+func (fl *FunctionLiteral) Pos() token.Position { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position {
+	if fl.Body != nil {
+		return fl.Body.End()
+	}
+	return tokenEnd(fl.Token)
+}
+
+func (ml *MacroLiteral) Pos() token.Position { return ml.Token.Pos }
+func (ml *MacroLiteral) End() token.Position {
+	if ml.Body != nil {
+		return ml.Body.End()
+	}
+	return tokenEnd(ml.Token)
+}
+
+func (ce *CallExpression) Pos() token.Position {
+	if ce.Function != nil {
+		return ce.Function.Pos()
+	}
+	return ce.Token.Pos
+}
+func (ce *CallExpression) End() token.Position {
+	if ce.Rparen.Line != 0 {
+		return charEnd(ce.Rparen)
+	}
+	return tokenEnd(ce.Token)
+}
+
+func (se *SelectorExpression) Pos() token.Position {
+	if se.X != nil {
+		return se.X.Pos()
+	}
+	return se.Token.Pos
+}
+func (se *SelectorExpression) End() token.Position {
+	if se.Sel != nil {
+		return se.Sel.End()
+	}
+	return tokenEnd(se.Token)
+}
+
+func (nt *NamedType) Pos() token.Position { return nt.Token.Pos }
+func (nt *NamedType) End() token.Position { return tokenEnd(nt.Token) }
+
+func (ft *FunctionType) Pos() token.Position { return ft.Token.Pos }
+func (ft *FunctionType) End() token.Position {
+	if ft.Result != nil {
+		return ft.Result.End()
+	}
+	return tokenEnd(ft.Token)
+}
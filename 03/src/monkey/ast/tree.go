@@ -0,0 +1,67 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Fprint 以缩进的树形输出ast，每行一个节点：类型、主要属性和起始位置，例：
+//
+//	Program
+//	  LetStatement 1:1
+//	    Identifier x 1:5
+//	    InfixExpression + 1:9
+func Fprint(w io.Writer, node Node) error {
+	p := &treePrinter{w: w}
+	Walk(node, p)
+	return p.err
+}
+
+type treePrinter struct {
+	w     io.Writer
+	depth int
+	err   error
+}
+
+func (p *treePrinter) Visit(node Node) Visitor {
+	if node == nil {
+		p.depth--
+		return nil
+	}
+	line := strings.Repeat("  ", p.depth) + nodeTypeName(node)
+	if detail := treeDetail(node); detail != "" {
+		line += " " + detail
+	}
+	if pos := node.Pos(); pos.Line != 0 {
+		line += " " + pos.String()
+	}
+	if _, err := fmt.Fprintln(p.w, line); err != nil && p.err == nil {
+		p.err = err
+	}
+	p.depth++
+	return p
+}
+
+// 节点中不作为子节点输出的属性
+func treeDetail(node Node) string {
+	switch n := node.(type) {
+	case *Identifier:
+		return n.Value
+	case *IntegerLiteral:
+		return n.Token.Literal
+	case *StringLiteral:
+		return n.Token.Literal
+	case *Boolean:
+		return fmt.Sprint(n.Value)
+	case *PrefixExpression:
+		return n.Operator
+	case *InfixExpression:
+		return n.Operator
+	case *ImportStatement:
+		return n.Path.Literal
+	case *NamedType:
+		return n.Name
+	}
+	return ""
+}
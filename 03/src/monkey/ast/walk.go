@@ -0,0 +1,172 @@
+package ast
+
+import "fmt"
+
+// Visitor Walk遍历时对每个节点调用Visit。
+// 返回的Visitor非nil时，继续用它遍历该节点的子节点，遍历完后再调用一次w.Visit(nil)
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk 深度优先遍历ast，子节点按源码顺序访问，nil子节点（语法错误时可能出现）被跳过
+func Walk(node Node, v Visitor) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(n.Statements, v)
+	case *LetStatement:
+		if n.Name != nil {
+			Walk(n.Name, v)
+		}
+		if n.Type != nil {
+			Walk(n.Type, v)
+		}
+		if n.Value != nil {
+			Walk(n.Value, v)
+		}
+	case *ImportStatement:
+		if n.Name != nil {
+			Walk(n.Name, v)
+		}
+	case *ReturnStatement:
+		if n.ReturnValue != nil {
+			Walk(n.ReturnValue, v)
+		}
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			Walk(n.Expression, v)
+		}
+	case *BlockStatement:
+		walkStatements(n.Statements, v)
+	case *PrefixExpression:
+		if n.Right != nil {
+			Walk(n.Right, v)
+		}
+	case *InfixExpression:
+		if n.Left != nil {
+			Walk(n.Left, v)
+		}
+		if n.Right != nil {
+			Walk(n.Right, v)
+		}
+	case *IfExpression:
+		if n.Condition != nil {
+			Walk(n.Condition, v)
+		}
+		if n.Consequence != nil {
+			Walk(n.Consequence, v)
+		}
+		if n.Alternative != nil {
+			Walk(n.Alternative, v)
+		}
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			Walk(p, v)
+		}
+		if n.ReturnType != nil {
+			Walk(n.ReturnType, v)
+		}
+		if n.Body != nil {
+			Walk(n.Body, v)
+		}
+	case *MacroLiteral:
+		for _, p := range n.Parameters {
+			Walk(p, v)
+		}
+		if n.Body != nil {
+			Walk(n.Body, v)
+		}
+	case *CallExpression:
+		if n.Function != nil {
+			Walk(n.Function, v)
+		}
+		for _, a := range n.Arguments {
+			if a != nil {
+				Walk(a, v)
+			}
+		}
+	case *ArrayLiteral:
+		for _, el := range n.Elements {
+			if el != nil {
+				Walk(el, v)
+			}
+		}
+	case *IndexExpression:
+		if n.Left != nil {
+			Walk(n.Left, v)
+		}
+		if n.Index != nil {
+			Walk(n.Index, v)
+		}
+	case *SelectorExpression:
+		if n.X != nil {
+			Walk(n.X, v)
+		}
+		if n.Sel != nil {
+			Walk(n.Sel, v)
+		}
+	case *Identifier:
+		if n.Type != nil {
+			Walk(n.Type, v)
+		}
+	case *FunctionType:
+		for _, p := range n.Parameters {
+			if p != nil {
+				Walk(p, v)
+			}
+		}
+		if n.Result != nil {
+			Walk(n.Result, v)
+		}
+	case *IntegerLiteral, *Boolean, *StringLiteral, *NamedType:
+		//终端节点，没有子节点
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(stmts []Statement, v Visitor) {
+	for _, s := range stmts {
+		if !isNilStatement(s) {
+			Walk(s, v)
+		}
+	}
+}
+
+// 语句是nil，或者是值为nil的指针（例：语法错误时的(*LetStatement)(nil)，与nil比较不相等）
+func isNilStatement(s Statement) bool {
+	switch s := s.(type) {
+	case nil:
+		return true
+	case *LetStatement:
+		return s == nil
+	case *ImportStatement:
+		return s == nil
+	case *ReturnStatement:
+		return s == nil
+	case *ExpressionStatement:
+		return s == nil
+	}
+	return false
+}
+
+// 把函数适配为Visitor
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect 深度优先遍历ast，对每个节点调用f(node)，f返回false时不再进入该节点的子节点；
+// 每个节点的子节点遍历完后调用一次f(nil)
+func Inspect(node Node, f func(Node) bool) {
+	Walk(node, inspector(f))
+}
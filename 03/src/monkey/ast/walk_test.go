@@ -0,0 +1,153 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"monkey/token"
+	"testing"
+)
+
+// 测试用的节点构造函数
+func ident(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+func integer(v int64) *IntegerLiteral {
+	return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: fmt.Sprint(v)}, Value: v}
+}
+
+func exprStmt(e Expression) *ExpressionStatement {
+	return &ExpressionStatement{Expression: e}
+}
+
+func block(stmts ...Statement) *BlockStatement {
+	return &BlockStatement{Statements: stmts}
+}
+
+// let f = fn(x) { if (x < 1) { return -x; } else { f(x + 1) } };
+func sampleProgram() *Program {
+	return &Program{Statements: []Statement{
+		&LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let"},
+			Name:  ident("f"),
+			Value: &FunctionLiteral{
+				Parameters: []*Identifier{ident("x")},
+				Body: block(exprStmt(&IfExpression{
+					Condition:   &InfixExpression{Left: ident("x"), Operator: "<", Right: integer(1)},
+					Consequence: block(&ReturnStatement{ReturnValue: &PrefixExpression{Operator: "-", Right: ident("x")}}),
+					Alternative: block(exprStmt(&CallExpression{
+						Function:  ident("f"),
+						Arguments: []Expression{&InfixExpression{Left: ident("x"), Operator: "+", Right: integer(1)}},
+					})),
+				})),
+			},
+		},
+	}}
+}
+
+func TestInspect(t *testing.T) {
+	var visited []string
+	Inspect(sampleProgram(), func(n Node) bool {
+		if n != nil {
+			visited = append(visited, fmt.Sprintf("%T", n))
+		}
+		return true
+	})
+
+	expected := []string{
+		"*ast.Program", "*ast.LetStatement", "*ast.Identifier", "*ast.FunctionLiteral",
+		"*ast.Identifier", "*ast.BlockStatement", "*ast.ExpressionStatement", "*ast.IfExpression",
+		"*ast.InfixExpression", "*ast.Identifier", "*ast.IntegerLiteral",
+		"*ast.BlockStatement", "*ast.ReturnStatement", "*ast.PrefixExpression", "*ast.Identifier",
+		"*ast.BlockStatement", "*ast.ExpressionStatement", "*ast.CallExpression", "*ast.Identifier",
+		"*ast.InfixExpression", "*ast.Identifier", "*ast.IntegerLiteral",
+	}
+	if len(visited) != len(expected) {
+		t.Fatalf("wrong number of nodes visited. want=%d, got=%d (%v)", len(expected), len(visited), visited)
+	}
+	for i := range expected {
+		if visited[i] != expected[i] {
+			t.Errorf("node %d wrong. want=%s, got=%s", i, expected[i], visited[i])
+		}
+	}
+}
+
+// Inspect返回false时不进入子节点，且每个进入的节点都以f(nil)结束
+func TestInspectPrune(t *testing.T) {
+	idents := 0
+	depth, maxDepth := 0, 0
+	Inspect(sampleProgram(), func(n Node) bool {
+		if n == nil {
+			depth--
+			return false
+		}
+		if _, ok := n.(*Identifier); ok {
+			idents++
+		}
+		if _, ok := n.(*FunctionLiteral); ok {
+			return false //不进入函数体
+		}
+		depth++
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+		return true
+	})
+	if idents != 1 {
+		t.Errorf("expected only the let name to be visited. got=%d identifiers", idents)
+	}
+	if depth != 0 {
+		t.Errorf("enter/leave not balanced. depth=%d", depth)
+	}
+}
+
+func TestWalkSkipsNilChildren(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: ident("x")}, //语法错误时Value可能为nil
+		exprStmt(nil),
+		exprStmt(&IfExpression{Condition: ident("y"), Consequence: block()}),
+	}}
+	count := 0
+	Inspect(program, func(n Node) bool {
+		if n != nil {
+			count++
+		}
+		return true
+	})
+	if count != 8 {
+		t.Errorf("wrong number of nodes visited. want=8, got=%d", count)
+	}
+}
+
+func TestFprint(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let", Pos: token.Position{Line: 1, Column: 1}},
+			Name:  ident("x"),
+			Value: &InfixExpression{Left: integer(1), Operator: "+", Right: &PrefixExpression{Operator: "-", Right: ident("y")}},
+		},
+		exprStmt(&IfExpression{Condition: &Boolean{Value: true}, Consequence: block(exprStmt(integer(2)))}),
+	}}
+
+	var out bytes.Buffer
+	if err := Fprint(&out, program); err != nil {
+		t.Fatal(err)
+	}
+	expected := `Program 1:1
+  LetStatement 1:1
+    Identifier x
+    InfixExpression +
+      IntegerLiteral 1
+      PrefixExpression -
+        Identifier y
+  ExpressionStatement
+    IfExpression
+      Boolean true
+      BlockStatement
+        ExpressionStatement
+          IntegerLiteral 2
+`
+	if out.String() != expected {
+		t.Errorf("wrong tree.\nwant:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
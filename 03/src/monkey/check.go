@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/analysis"
+	"monkey/host"
+	"monkey/stdlib"
+	"monkey/typecheck"
+	"sort"
+)
+
+// monkey check files... 运行前检查未定义的标识符、类型错误等问题，有错误时返回非0
+func runCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	types := fs.Bool("types", true, "同时推导并检查类型（支持 let x: int 等可选注解）")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey check [-types=false] files...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, path := range fs.Args() {
+		program, ok := parseFile(path)
+		if !ok {
+			status = 1
+			continue
+		}
+		diags := analysis.Check(program, &analysis.Config{Globals: append(stdlib.Names(), host.Names()...)})
+		if *types {
+			diags = append(diags, typecheck.Check(program, nil)...)
+			sort.SliceStable(diags, func(i, j int) bool {
+				return diags[i].Pos.Offset < diags[j].Pos.Offset
+			})
+		}
+		for _, d := range diags {
+			fmt.Printf("%s:%s\n", path, d)
+		}
+		if analysis.HasErrors(diags) {
+			status = 1
+		}
+	}
+	return status
+}
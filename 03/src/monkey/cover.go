@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey/cover"
+	"os"
+)
+
+// 覆盖率相关的命令行参数
+type coverFlags struct {
+	cover   *bool
+	profile *string
+	format  *string
+}
+
+func addCoverFlags(fs *flag.FlagSet) *coverFlags {
+	return &coverFlags{
+		cover:   fs.Bool("cover", false, "统计语句和分支的覆盖率，输出摘要"),
+		profile: fs.String("coverprofile", "", "覆盖率报告写入该文件（同时打开-cover）"),
+		format:  fs.String("coverformat", "text", "覆盖率报告的格式：text、html或lcov"),
+	}
+}
+
+func (c *coverFlags) enabled() bool {
+	return *c.cover || *c.profile != ""
+}
+
+// 检查参数，格式不支持时返回错误
+func (c *coverFlags) check() error {
+	switch *c.format {
+	case "text", "html", "lcov":
+		return nil
+	}
+	return fmt.Errorf("unknown coverage format %q, want text, html or lcov", *c.format)
+}
+
+// 输出摘要到w，有-coverprofile时写入报告
+func (c *coverFlags) write(w io.Writer, profiles []*cover.Profile) error {
+	fmt.Fprintln(w, cover.Summary(profiles))
+	if *c.profile == "" {
+		return nil
+	}
+	f, err := os.Create(*c.profile)
+	if err != nil {
+		return err
+	}
+	switch *c.format {
+	case "html":
+		err = cover.WriteHTML(f, profiles)
+	case "lcov":
+		err = cover.WriteLCOV(f, profiles)
+	default:
+		err = cover.WriteText(f, profiles)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,221 @@
+package cover
+
+// 代码覆盖率：作为evaluator.Hooks安装到解释器上，统计每条语句、每个if分支和每个函数的执行次数。
+// 报告可以输出为文本、带源码标注的HTML和LCOV格式，见report.go
+
+import (
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/token"
+	"sort"
+)
+
+// Statement 一条语句及其执行次数
+type Statement struct {
+	Pos, End token.Position
+	Count    int64
+}
+
+// Branch if表达式的一个分支，Index为0是条件成立的分支，1是else分支。
+// 没有else时也有else分支，它的Pos、End为if表达式的结尾
+type Branch struct {
+	If       token.Position //所属if表达式的位置
+	Index    int
+	Pos, End token.Position
+	Count    int64
+}
+
+// Function 函数字面量及其被调用的次数
+type Function struct {
+	Name     string //let绑定的名字，没有时为anonymous
+	Pos, End token.Position
+	Count    int64
+}
+
+// Profile 一个源文件的覆盖率，用evaluator.WithHooks安装。
+// 求值ast.Program时登记其中全部的语句、分支和函数，之后的求值在此基础上累计次数。
+// 同一源码多次解析得到的不同ast按位置对应到同一条语句，可以用于多个解释器（例：monkey test的每个测试）
+type Profile struct {
+	evaluator.NopHooks
+
+	File   string
+	Source string //源码，用于HTML报告
+
+	Statements []*Statement //按位置排序
+	Branches   []*Branch    //按位置排序，每个if的两个分支相邻
+	Functions  []*Function  //按位置排序
+
+	programs map[*ast.Program]bool
+	stmts    map[ast.Statement]*Statement
+	conds    map[ast.Expression][2]*Branch //if的条件表达式，求值结果决定走哪个分支
+	funcs    map[*ast.BlockStatement]*Function
+
+	//按源码偏移登记过的语句、if和函数
+	stmtAt map[int]*Statement
+	ifAt   map[int][2]*Branch
+	funcAt map[int]*Function
+}
+
+// New 创建一个源文件的覆盖率统计
+func New(file, source string) *Profile {
+	return &Profile{
+		File:     file,
+		Source:   source,
+		programs: map[*ast.Program]bool{},
+		stmts:    map[ast.Statement]*Statement{},
+		conds:    map[ast.Expression][2]*Branch{},
+		funcs:    map[*ast.BlockStatement]*Function{},
+		stmtAt:   map[int]*Statement{},
+		ifAt:     map[int][2]*Branch{},
+		funcAt:   map[int]*Function{},
+	}
+}
+
+func (p *Profile) EnterNode(node ast.Node, env *object.Environment) {
+	switch node := node.(type) {
+	case *ast.Program:
+		if node.File != "" && node.File != p.File { //import的模块属于其他文件
+			return
+		}
+		if !p.programs[node] {
+			p.programs[node] = true
+			p.add(node)
+		}
+	case ast.Statement:
+		if s := p.stmts[node]; s != nil {
+			s.Count++
+		}
+	}
+}
+
+// 条件求值后按其真假计入分支，求值出错时两个分支都不执行
+func (p *Profile) ExitNode(node ast.Node, result object.Object) {
+	cond, ok := node.(ast.Expression)
+	if !ok {
+		return
+	}
+	branches, ok := p.conds[cond]
+	if !ok || result == nil || result.Type() == object.ERROR_OBJ {
+		return
+	}
+	if result == evaluator.NULL || result == evaluator.FALSE {
+		branches[1].Count++
+	} else {
+		branches[0].Count++
+	}
+}
+
+func (p *Profile) Call(call *ast.CallExpression, fn object.Object, args []object.Object) {
+	if f, ok := fn.(*object.Function); ok {
+		if info := p.funcs[f.Body]; info != nil {
+			info.Count++
+		}
+	}
+}
+
+// 登记程序中的语句、分支和函数
+func (p *Profile) add(program *ast.Program) {
+	names := map[*ast.FunctionLiteral]string{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if stmt, ok := node.(ast.Statement); ok && isNil(stmt) {
+			return false
+		}
+		if node == nil || node.Pos().Line == 0 { //宏展开时生成的节点没有位置，不统计
+			return true
+		}
+		switch node := node.(type) {
+		case ast.Statement:
+			s := p.stmtAt[node.Pos().Offset]
+			if s == nil {
+				s = &Statement{Pos: node.Pos(), End: node.End()}
+				p.stmtAt[s.Pos.Offset] = s
+				p.Statements = append(p.Statements, s)
+			}
+			p.stmts[node] = s
+			if let, ok := node.(*ast.LetStatement); ok {
+				if fl, ok := let.Value.(*ast.FunctionLiteral); ok {
+					names[fl] = let.Name.Value
+				}
+			}
+		case *ast.IfExpression:
+			branches, ok := p.ifAt[node.Pos().Offset]
+			if !ok {
+				then := &Branch{If: node.Pos(), Index: 0, Pos: node.Consequence.Pos(), End: node.Consequence.End()}
+				els := &Branch{If: node.Pos(), Index: 1, Pos: node.End(), End: node.End()}
+				if node.Alternative != nil {
+					els.Pos = node.Alternative.Pos()
+				}
+				branches = [2]*Branch{then, els}
+				p.ifAt[node.Pos().Offset] = branches
+				p.Branches = append(p.Branches, then, els)
+			}
+			p.conds[node.Condition] = branches
+		case *ast.FunctionLiteral:
+			f := p.funcAt[node.Pos().Offset]
+			if f == nil {
+				name := names[node]
+				if name == "" {
+					name = "anonymous"
+				}
+				f = &Function{Name: name, Pos: node.Pos(), End: node.End()}
+				p.funcAt[f.Pos.Offset] = f
+				p.Functions = append(p.Functions, f)
+			}
+			p.funcs[node.Body] = f
+		}
+		return true
+	})
+
+	sort.SliceStable(p.Statements, func(i, j int) bool { return p.Statements[i].Pos.Offset < p.Statements[j].Pos.Offset })
+	sort.SliceStable(p.Branches, func(i, j int) bool { return p.Branches[i].If.Offset < p.Branches[j].If.Offset })
+	sort.SliceStable(p.Functions, func(i, j int) bool { return p.Functions[i].Pos.Offset < p.Functions[j].Pos.Offset })
+}
+
+// 语法错误时parser可能留下类型非nil、值为nil的语句
+func isNil(stmt ast.Statement) bool {
+	switch s := stmt.(type) {
+	case *ast.LetStatement:
+		return s == nil
+	case *ast.ReturnStatement:
+		return s == nil
+	case *ast.ExpressionStatement:
+		return s == nil
+	case *ast.ImportStatement:
+		return s == nil
+	}
+	return stmt == nil
+}
+
+// Covered 执行过的语句数和语句总数
+func (p *Profile) Covered() (covered, total int) {
+	for _, s := range p.Statements {
+		if s.Count > 0 {
+			covered++
+		}
+	}
+	return covered, len(p.Statements)
+}
+
+// BranchesCovered 执行过的分支数和分支总数
+func (p *Profile) BranchesCovered() (covered, total int) {
+	for _, b := range p.Branches {
+		if b.Count > 0 {
+			covered++
+		}
+	}
+	return covered, len(p.Branches)
+}
+
+// 函数范围内的语句覆盖率（包括嵌套的函数）
+func (p *Profile) functionCovered(f *Function) (covered, total int) {
+	for _, s := range p.Statements {
+		if s.Pos.Offset >= f.Pos.Offset && s.Pos.Offset < f.End.Offset {
+			total++
+			if s.Count > 0 {
+				covered++
+			}
+		}
+	}
+	return covered, total
+}
@@ -0,0 +1,153 @@
+package cover
+
+import (
+	"bytes"
+	"monkey/evaluator"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testSource = `let abs = fn(n) {
+    if (n < 0) { -n } else { n }
+};
+let check = fn(n) {
+    if (n > 100) { return 0; }
+    n
+};
+let unused = fn() { 1 };
+abs(-3) + abs(2) + check(5)
+`
+
+func run(t *testing.T, src string) *Profile {
+	t.Helper()
+	p := New("test.mk", src)
+	result, err := evaluator.New(evaluator.WithHooks(p)).Run(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inspect() != "10" {
+		t.Fatalf("result = %s", result.Inspect())
+	}
+	return p
+}
+
+func TestCounts(t *testing.T) {
+	p := run(t, testSource)
+
+	var stmts []string
+	for _, s := range p.Statements {
+		stmts = append(stmts, strings.TrimSpace(testSource[s.Pos.Offset:s.End.Offset])+"="+itoa(s.Count))
+	}
+	expected := []string{
+		"let abs = fn(n) {\n    if (n < 0) { -n } else { n }\n}=1",
+		"if (n < 0) { -n } else { n }=2",
+		"-n=1",
+		"n=1",
+		"let check = fn(n) {\n    if (n > 100) { return 0; }\n    n\n}=1",
+		"if (n > 100) { return 0; }=1",
+		"return 0=0",
+		"n=1",
+		"let unused = fn() { 1 }=1",
+		"1=0",
+		"abs(-3) + abs(2) + check(5)=1",
+	}
+	if strings.Join(stmts, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong statement counts.\nwant:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(stmts, "\n"))
+	}
+
+	var branches []string
+	for _, b := range p.Branches {
+		branches = append(branches, itoa(int64(b.If.Line))+"/"+itoa(int64(b.Index))+"="+itoa(b.Count))
+	}
+	if got := strings.Join(branches, " "); got != "2/0=1 2/1=1 5/0=0 5/1=1" {
+		t.Errorf("branches = %s", got)
+	}
+
+	var funcs []string
+	for _, f := range p.Functions {
+		funcs = append(funcs, f.Name+"="+itoa(f.Count))
+	}
+	if got := strings.Join(funcs, " "); got != "abs=2 check=1 unused=0" {
+		t.Errorf("functions = %s", got)
+	}
+
+	if got := Summary([]*Profile{p}); got != "coverage: 81.8% of statements, 75.0% of branches" {
+		t.Errorf("summary = %q", got)
+	}
+}
+
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteText(&buf, []*Profile{run(t, testSource)}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `test.mk:1:	abs		100.0%
+test.mk:4:	check		66.7%
+test.mk:8:	unused		0.0%
+total:		(statements)	81.8%
+total:		(branches)	75.0%
+`
+	if buf.String() != expected {
+		t.Errorf("wrong text report.\nwant:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteLCOV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteLCOV(&buf, []*Profile{run(t, testSource)}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `TN:
+SF:test.mk
+FN:1,abs
+FN:4,check
+FN:8,unused
+FNDA:2,abs
+FNDA:1,check
+FNDA:0,unused
+FNF:3
+FNH:2
+BRDA:2,0,0,1
+BRDA:2,0,1,1
+BRDA:5,1,0,0
+BRDA:5,1,1,1
+BRF:4
+BRH:3
+DA:1,1
+DA:2,1
+DA:4,1
+DA:5,0
+DA:6,1
+DA:8,0
+DA:9,1
+LF:7
+LH:5
+end_of_record
+`
+	if buf.String() != expected {
+		t.Errorf("wrong LCOV output.\nwant:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, []*Profile{run(t, testSource)}); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, want := range []string{
+		`<option value="file0">test.mk (81.8%)</option>`,
+		`<span class="cov0" title="0">return 0</span>`,
+		`<span class="branch" title="then: 1, else: 1">if</span> (n &lt; 0)`,
+		`<span class="num">9</span><span class="cov1" title="1">abs(-3) + abs(2) + check(5)</span>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML report does not contain %q", want)
+		}
+	}
+}
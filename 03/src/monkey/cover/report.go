@@ -0,0 +1,240 @@
+package cover
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(covered) * 100 / float64(total)
+}
+
+// 多个文件的语句和分支覆盖率合计
+func totals(profiles []*Profile) (stmts, stmtTotal, branches, branchTotal int) {
+	for _, p := range profiles {
+		c, t := p.Covered()
+		stmts, stmtTotal = stmts+c, stmtTotal+t
+		c, t = p.BranchesCovered()
+		branches, branchTotal = branches+c, branchTotal+t
+	}
+	return
+}
+
+// Summary 一行的覆盖率摘要，例：coverage: 85.0% of statements, 50.0% of branches
+func Summary(profiles []*Profile) string {
+	stmts, stmtTotal, branches, branchTotal := totals(profiles)
+	return fmt.Sprintf("coverage: %.1f%% of statements, %.1f%% of branches",
+		percent(stmts, stmtTotal), percent(branches, branchTotal))
+}
+
+// WriteText 每个函数的语句覆盖率和合计，格式类似 go tool cover -func
+func WriteText(w io.Writer, profiles []*Profile) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, '\t', 0)
+	for _, p := range profiles {
+		for _, f := range p.Functions {
+			c, t := p.functionCovered(f)
+			fmt.Fprintf(tw, "%s:%d:\t%s\t%.1f%%\n", p.File, f.Pos.Line, f.Name, percent(c, t))
+		}
+	}
+	stmts, stmtTotal, branches, branchTotal := totals(profiles)
+	fmt.Fprintf(tw, "total:\t(statements)\t%.1f%%\n", percent(stmts, stmtTotal))
+	fmt.Fprintf(tw, "total:\t(branches)\t%.1f%%\n", percent(branches, branchTotal))
+	return tw.Flush()
+}
+
+// WriteLCOV 以LCOV的tracefile格式输出，供CI的覆盖率服务使用。
+// 一行有多条语句时，该行的执行次数取其中最少的，只要有语句没执行该行就算未覆盖
+func WriteLCOV(w io.Writer, profiles []*Profile) error {
+	bw := bufio.NewWriter(w)
+	for _, p := range profiles {
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", p.File)
+
+		hit := 0
+		names := functionNames(p.Functions)
+		for i, f := range p.Functions {
+			fmt.Fprintf(bw, "FN:%d,%s\n", f.Pos.Line, names[i])
+		}
+		for i, f := range p.Functions {
+			fmt.Fprintf(bw, "FNDA:%d,%s\n", f.Count, names[i])
+			if f.Count > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(bw, "FNF:%d\nFNH:%d\n", len(p.Functions), hit)
+
+		for i := 0; i+1 < len(p.Branches); i += 2 {
+			then, els := p.Branches[i], p.Branches[i+1]
+			for _, b := range []*Branch{then, els} {
+				taken := fmt.Sprint(b.Count)
+				if then.Count+els.Count == 0 { //条件没有求值过
+					taken = "-"
+				}
+				fmt.Fprintf(bw, "BRDA:%d,%d,%d,%s\n", b.If.Line, i/2, b.Index, taken)
+			}
+		}
+		c, t := p.BranchesCovered()
+		fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", t, c)
+
+		var lines []int
+		counts := map[int]int64{}
+		for _, s := range p.Statements {
+			line := s.Pos.Line
+			if n, ok := counts[line]; !ok || s.Count < n {
+				if !ok {
+					lines = append(lines, line)
+				}
+				counts[line] = s.Count
+			}
+		}
+		hit = 0
+		for _, line := range lines {
+			fmt.Fprintf(bw, "DA:%d,%d\n", line, counts[line])
+			if counts[line] > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(lines), hit)
+	}
+	return bw.Flush()
+}
+
+// LCOV中函数名要唯一，重名（例：匿名函数）的加上位置
+func functionNames(funcs []*Function) []string {
+	seen := map[string]int{}
+	for _, f := range funcs {
+		seen[f.Name]++
+	}
+	names := make([]string, len(funcs))
+	for i, f := range funcs {
+		names[i] = f.Name
+		if seen[f.Name] > 1 {
+			names[i] = fmt.Sprintf("%s@%s", f.Name, f.Pos)
+		}
+	}
+	return names
+}
+
+// WriteHTML 输出带覆盖率标注的源码：执行过的语句为绿色，没有执行过的为红色，
+// 鼠标停留在语句上显示执行次数，if上显示两个分支的执行次数
+func WriteHTML(w io.Writer, profiles []*Profile) error {
+	type file struct {
+		Name    string
+		Percent float64
+		Lines   []template.HTML
+	}
+	var files []file
+	for _, p := range profiles {
+		c, t := p.Covered()
+		files = append(files, file{Name: p.File, Percent: percent(c, t), Lines: p.annotate()})
+	}
+	return htmlTemplate.Execute(w, files)
+}
+
+// 源码中每个字节所属的最内层语句的执行次数，-1表示不属于任何语句
+func (p *Profile) byteCounts() []int64 {
+	counts := make([]int64, len(p.Source))
+	for i := range counts {
+		counts[i] = -1
+	}
+	for _, s := range p.Statements { //按起始位置排序，内层语句在外层之后，覆盖外层的次数
+		for i := s.Pos.Offset; i < s.End.Offset && i < len(counts); i++ {
+			counts[i] = s.Count
+		}
+	}
+	return counts
+}
+
+// 按行生成标注后的HTML
+func (p *Profile) annotate() []template.HTML {
+	counts := p.byteCounts()
+	ifs := map[int]string{} //if关键字的偏移 -> 分支次数的说明
+	for i := 0; i+1 < len(p.Branches); i += 2 {
+		ifs[p.Branches[i].If.Offset] = fmt.Sprintf("then: %d, else: %d", p.Branches[i].Count, p.Branches[i+1].Count)
+	}
+
+	var lines []template.HTML
+	var b strings.Builder
+	open := false
+	closeSpan := func() {
+		if open {
+			b.WriteString("</span>")
+			open = false
+		}
+	}
+	for i := 0; i <= len(p.Source); i++ {
+		if i == len(p.Source) || p.Source[i] == '\n' {
+			closeSpan()
+			lines = append(lines, template.HTML(b.String()))
+			b.Reset()
+			continue
+		}
+		if i == 0 || counts[i] != counts[i-1] || p.Source[i-1] == '\n' {
+			closeSpan()
+			if counts[i] >= 0 {
+				class := "cov0"
+				if counts[i] > 0 {
+					class = "cov1"
+				}
+				fmt.Fprintf(&b, `<span class="%s" title="%d">`, class, counts[i])
+				open = true
+			}
+		}
+		if note, ok := ifs[i]; ok {
+			fmt.Fprintf(&b, `<span class="branch" title="%s">if</span>`, note)
+			i += len("if") - 1
+			continue
+		}
+		b.WriteString(template.HTMLEscapeString(p.Source[i : i+1]))
+	}
+	if n := len(lines); n > 1 && lines[n-1] == "" { //源码以换行结尾
+		lines = lines[:n-1]
+	}
+	return lines
+}
+
+var htmlTemplate = template.Must(template.New("cover").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Monkey coverage</title>
+<style>
+body { background: #fff; color: #222; font-family: Menlo, Consolas, monospace; font-size: 14px; margin: 0; }
+#topbar { background: #eee; padding: 8px 12px; border-bottom: 1px solid #ccc; }
+#legend span { margin-left: 12px; }
+pre { margin: 0; padding: 8px 0; }
+.line { display: block; }
+.num { display: inline-block; width: 4em; padding-right: 1em; text-align: right; color: #999; user-select: none; }
+.cov0 { background: #fdd; color: #a00; }
+.cov1 { background: #dfd; color: #060; }
+.branch { text-decoration: underline dotted; }
+.file { display: none; }
+.file.selected { display: block; }
+</style>
+</head>
+<body>
+<div id="topbar">
+<select id="files" onchange="show(this.value)">
+{{range $i, $f := .}}<option value="file{{$i}}">{{$f.Name}} ({{printf "%.1f" $f.Percent}}%)</option>
+{{end}}</select>
+<span id="legend"><span class="cov0">not covered</span><span class="cov1">covered</span></span>
+</div>
+{{range $i, $f := .}}<pre class="file{{if eq $i 0}} selected{{end}}" id="file{{$i}}">{{range $n, $line := $f.Lines}}<span class="line"><span class="num">{{inc $n}}</span>{{$line}}</span>{{end}}</pre>
+{{end}}<script>
+function show(id) {
+	for (const el of document.querySelectorAll(".file")) {
+		el.classList.toggle("selected", el.id === id);
+	}
+}
+</script>
+</body>
+</html>
+`))
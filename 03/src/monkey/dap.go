@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/dap"
+	"os"
+)
+
+// monkey dap 通过标准输入输出运行调试适配器（Debug Adapter Protocol），由编辑器启动
+func runDAP(args []string) int {
+	fs := flag.NewFlagSet("dap", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey dap")
+		fmt.Fprintln(fs.Output(), "调试适配器通过标准输入输出通信，由编辑器启动，launch请求的program参数指定被调试的文件")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if err := dap.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey dap: %s\n", err)
+		return 1
+	}
+	return 0
+}
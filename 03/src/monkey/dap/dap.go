@@ -0,0 +1,404 @@
+package dap
+
+// 调试适配器：编辑器通过标准输入输出以Debug Adapter Protocol控制debugger包的调试器。
+// 请求在读取消息的goroutine中处理，被调试的程序在另一个goroutine中求值，
+// 暂停时求值goroutine阻塞在OnStop中，等待continue、next等请求给出继续方式
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/debugger"
+	"monkey/evaluator"
+	"monkey/framing"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const threadID = 1 //求值只在一个goroutine中进行，只有一个线程
+
+type session struct {
+	in  *bufio.Reader
+	dbg *debugger.Debugger
+
+	outMu sync.Mutex
+	out   io.Writer
+	seq   int
+
+	path        string //被调试的程序
+	source      string
+	stopOnEntry bool
+
+	mu       sync.Mutex
+	stop     *debugger.Stop        //暂停时的状态，运行中为nil
+	refs     []*object.Environment //本次暂停中variablesReference（从1开始）对应的环境
+	quitting bool                  //断开连接，下次暂停时中止求值
+	resume   chan debugger.Action
+	done     chan struct{} //程序结束后关闭，未开始运行时为nil
+}
+
+// Serve 在in和out上运行调试适配器，直到收到disconnect请求或输入结束
+func Serve(in io.Reader, out io.Writer) error {
+	s := &session{in: bufio.NewReader(in), out: out, resume: make(chan debugger.Action)}
+	s.dbg = debugger.New(s.onStop)
+	for {
+		body, err := framing.Read(s.in)
+		if err != nil {
+			s.quit()
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return err
+		}
+		result, err := s.handle(&req)
+		resp := &response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: result}
+		if err != nil {
+			resp.Message = err.Error()
+		}
+		if err := s.send(resp); err != nil {
+			return err
+		}
+		switch req.Command {
+		case "initialize":
+			s.event("initialized", nil)
+		case "disconnect":
+			return nil
+		}
+	}
+}
+
+// 写出响应或事件，分配序号
+func (s *session) send(msg interface{}) error {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.seq++
+	switch m := msg.(type) {
+	case *response:
+		m.Seq = s.seq
+	case *event:
+		m.Seq = s.seq
+	}
+	return framing.Write(s.out, msg)
+}
+
+func (s *session) event(name string, body interface{}) {
+	s.send(&event{Type: "event", Event: name, Body: body})
+}
+
+func (s *session) output(category, text string) {
+	s.event("output", map[string]string{"category": category, "output": text})
+}
+
+type handler func(s *session, args json.RawMessage) (interface{}, error)
+
+var handlers map[string]handler
+
+func init() {
+	handlers = map[string]handler{
+		"initialize":        (*session).initialize,
+		"launch":            (*session).launch,
+		"setBreakpoints":    (*session).setBreakpoints,
+		"configurationDone": (*session).configurationDone,
+		"threads":           (*session).threads,
+		"stackTrace":        (*session).stackTrace,
+		"scopes":            (*session).scopes,
+		"variables":         (*session).variables,
+		"evaluate":          (*session).evaluate,
+		"continue":          resumeWith(debugger.Continue),
+		"next":              resumeWith(debugger.StepOver),
+		"stepIn":            resumeWith(debugger.StepIn),
+		"stepOut":           resumeWith(debugger.StepOut),
+		"pause":             (*session).pause,
+		"terminate":         (*session).terminate,
+		"disconnect":        (*session).terminate,
+	}
+}
+
+func (s *session) handle(req *request) (interface{}, error) {
+	h, ok := handlers[req.Command]
+	if !ok {
+		return nil, fmt.Errorf("unsupported command %q", req.Command)
+	}
+	return h(s, req.Arguments)
+}
+
+func decode(args json.RawMessage, v interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %s", err)
+	}
+	return nil
+}
+
+func (s *session) initialize(json.RawMessage) (interface{}, error) {
+	return Capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsConditionalBreakpoints:   true,
+		SupportsEvaluateForHovers:        true,
+		SupportsTerminateRequest:         true,
+	}, nil
+}
+
+// launch 读取程序，configurationDone之后开始运行
+func (s *session) launch(args json.RawMessage) (interface{}, error) {
+	var a LaunchArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Program == "" {
+		return nil, errors.New("missing program")
+	}
+	src, err := os.ReadFile(a.Program)
+	if err != nil {
+		return nil, err
+	}
+	s.path, s.source, s.stopOnEntry = a.Program, string(src), a.StopOnEntry
+	return nil, nil
+}
+
+// setBreakpoints 替换程序中的全部断点
+func (s *session) setBreakpoints(args json.RawMessage) (interface{}, error) {
+	var a SetBreakpointsArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	s.dbg.ClearBreakpoint(0)
+	result := []Breakpoint{}
+	for _, sb := range a.Breakpoints {
+		bp := Breakpoint{Verified: true, Line: sb.Line}
+		if _, err := s.dbg.SetBreakpoint(sb.Line, sb.Condition); err != nil {
+			bp.Verified, bp.Message = false, err.Error()
+		}
+		result = append(result, bp)
+	}
+	return map[string]interface{}{"breakpoints": result}, nil
+}
+
+func (s *session) configurationDone(json.RawMessage) (interface{}, error) {
+	if s.path == "" {
+		return nil, errors.New("no program launched")
+	}
+	if s.done != nil {
+		return nil, errors.New("program already running")
+	}
+	if s.stopOnEntry {
+		s.dbg.Pause("entry")
+	}
+	s.done = make(chan struct{})
+	go s.run()
+	return nil, nil
+}
+
+// 在单独的goroutine中运行程序，结束后发送exited和terminated事件
+func (s *session) run() {
+	defer close(s.done)
+	result, err := evaluator.New(evaluator.WithDebugger(s.dbg)).Run(s.source)
+	s.dbg.Done()
+
+	exitCode := 0
+	switch {
+	case err != nil:
+		s.output("stderr", err.Error()+"\n")
+		exitCode = 1
+	case result != nil && result.Type() == object.ERROR_OBJ:
+		s.output("stderr", result.Inspect()+"\n")
+		exitCode = 1
+	case result != nil:
+		s.output("stdout", result.Inspect()+"\n")
+	}
+	s.event("exited", map[string]int{"exitCode": exitCode})
+	s.event("terminated", nil)
+}
+
+// 调试器暂停：通知编辑器，等待继续方式
+func (s *session) onStop(stop *debugger.Stop) debugger.Action {
+	s.mu.Lock()
+	if s.quitting {
+		s.mu.Unlock()
+		return debugger.Abort
+	}
+	s.stop, s.refs = stop, nil
+	s.mu.Unlock()
+
+	body := map[string]interface{}{"reason": stop.Reason, "threadId": threadID, "allThreadsStopped": true}
+	if stop.Err != nil {
+		body["reason"] = "exception"
+		body["text"] = stop.Err.Error()
+	}
+	s.event("stopped", body)
+	action := <-s.resume
+
+	s.mu.Lock()
+	s.stop, s.refs = nil, nil
+	s.mu.Unlock()
+	return action
+}
+
+// 当前的暂停状态，运行中返回错误
+func (s *session) stopped() (*debugger.Stop, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		return nil, errors.New("program is not paused")
+	}
+	return s.stop, nil
+}
+
+func resumeWith(action debugger.Action) handler {
+	return func(s *session, args json.RawMessage) (interface{}, error) {
+		if _, err := s.stopped(); err != nil {
+			return nil, err
+		}
+		s.resume <- action
+		return map[string]bool{"allThreadsContinued": true}, nil
+	}
+}
+
+func (s *session) pause(json.RawMessage) (interface{}, error) {
+	s.dbg.Pause("pause")
+	return nil, nil
+}
+
+// terminate、disconnect 中止正在运行的程序
+func (s *session) terminate(json.RawMessage) (interface{}, error) {
+	s.quit()
+	return nil, nil
+}
+
+func (s *session) quit() {
+	s.mu.Lock()
+	s.quitting = true
+	paused := s.stop != nil
+	s.mu.Unlock()
+	if s.done == nil {
+		return
+	}
+	if paused {
+		s.resume <- debugger.Abort
+	} else {
+		s.dbg.Pause("pause") //运行中的程序在下一条语句前中止
+	}
+	<-s.done
+}
+
+func (s *session) threads(json.RawMessage) (interface{}, error) {
+	return map[string][]Thread{"threads": {{ID: threadID, Name: "main"}}}, nil
+}
+
+// 调用栈，最内层在前；frameId是Stop.Frames的下标
+func (s *session) stackTrace(json.RawMessage) (interface{}, error) {
+	stop, err := s.stopped()
+	if err != nil {
+		return nil, err
+	}
+	source := Source{Name: filepath.Base(s.path), Path: s.path}
+	frames := []StackFrame{}
+	pos := stop.Pos
+	for i := len(stop.Frames) - 1; i >= 0; i-- {
+		f := stop.Frames[i]
+		frames = append(frames, StackFrame{ID: i, Name: f.Name, Source: source, Line: pos.Line, Column: pos.Column})
+		pos = f.Call //外一层停在调用处
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+func (s *session) frameEnv(stop *debugger.Stop, id int) (*object.Environment, error) {
+	if id < 0 || id >= len(stop.Frames) {
+		return nil, fmt.Errorf("invalid frame %d", id)
+	}
+	return stop.Frames[id].Env, nil
+}
+
+// 帧的环境链：函数自己的局部环境、外层闭包的环境，最后是全局环境
+func (s *session) scopes(args json.RawMessage) (interface{}, error) {
+	var a FrameArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	stop, err := s.stopped()
+	if err != nil {
+		return nil, err
+	}
+	env, err := s.frameEnv(stop, a.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scopes := []Scope{}
+	for level := 0; env != nil; level, env = level+1, env.Outer() {
+		name := "Locals"
+		switch {
+		case env.Outer() == nil:
+			name = "Globals"
+		case level > 0:
+			name = fmt.Sprintf("Closure #%d", level)
+		}
+		s.refs = append(s.refs, env)
+		scopes = append(scopes, Scope{Name: name, VariablesReference: len(s.refs), Expensive: env.Outer() == nil})
+	}
+	return map[string][]Scope{"scopes": scopes}, nil
+}
+
+func (s *session) variables(args json.RawMessage) (interface{}, error) {
+	var a VariablesArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if a.VariablesReference < 1 || a.VariablesReference > len(s.refs) {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("invalid variables reference %d", a.VariablesReference)
+	}
+	env := s.refs[a.VariablesReference-1]
+	s.mu.Unlock()
+
+	vars := []Variable{}
+	for _, v := range debugger.Variables(env) {
+		vars = append(vars, Variable{Name: v.Name, Value: oneLine(v.Value.Inspect()), Type: string(v.Value.Type())})
+	}
+	return map[string][]Variable{"variables": vars}, nil
+}
+
+// 在暂停处（或指定帧）的环境中求值，用于监视表达式、悬停和调试控制台
+func (s *session) evaluate(args json.RawMessage) (interface{}, error) {
+	var a EvaluateArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	stop, err := s.stopped()
+	if err != nil {
+		return nil, err
+	}
+	env := stop.Env
+	if a.FrameID != nil {
+		if env, err = s.frameEnv(stop, *a.FrameID); err != nil {
+			return nil, err
+		}
+	}
+	val, err := debugger.Eval(a.Expression, env)
+	if err != nil {
+		return nil, err
+	}
+	if errObj, ok := val.(*object.Error); ok {
+		return nil, errors.New(errObj.Message)
+	}
+	return map[string]interface{}{"result": oneLine(val.Inspect()), "type": string(val.Type()), "variablesReference": 0}, nil
+}
+
+// 多行的值（例：函数）压缩成一行
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
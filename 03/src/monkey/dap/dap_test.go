@@ -0,0 +1,291 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"monkey/framing"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testProgram = `let add = fn(a, b) {
+    let sum = a + b;
+    sum
+};
+let x = 1;
+let y = add(x, 2);
+y * 10
+`
+
+type message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// 模拟编辑器：通过管道与Serve交互，响应和事件异步到达
+type client struct {
+	t       *testing.T
+	w       *io.PipeWriter
+	seq     int
+	msgs    chan message
+	pending []message //等待响应时收到的事件
+	done    chan error
+}
+
+func startClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, w: inW, msgs: make(chan message, 100), done: make(chan error, 1)}
+	go func() {
+		err := Serve(inR, outW)
+		outW.Close()
+		c.done <- err
+	}()
+	go func() {
+		defer close(c.msgs)
+		r := bufio.NewReader(outR)
+		for {
+			body, err := framing.Read(r)
+			if err != nil {
+				return
+			}
+			var msg message
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Error(err)
+				return
+			}
+			c.msgs <- msg
+		}
+	}()
+	return c
+}
+
+func (c *client) next() message {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatal("adapter closed the connection")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for a message")
+	}
+	return message{}
+}
+
+// 发送请求并等待其响应，body解码到result中
+func (c *client) call(command string, args interface{}, result interface{}) message {
+	c.t.Helper()
+	c.seq++
+	req := map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args}
+	if err := framing.Write(c.w, req); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		msg := c.next()
+		if msg.Type == "event" {
+			c.pending = append(c.pending, msg)
+			continue
+		}
+		if msg.RequestSeq != c.seq || msg.Command != command {
+			c.t.Fatalf("unexpected response %+v to %s", msg, command)
+		}
+		if result != nil && msg.Success {
+			if err := json.Unmarshal(msg.Body, result); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return msg
+	}
+}
+
+func (c *client) mustCall(command string, args interface{}, result interface{}) {
+	c.t.Helper()
+	if msg := c.call(command, args, result); !msg.Success {
+		c.t.Fatalf("%s failed: %s", command, msg.Message)
+	}
+}
+
+// 等待指定的事件，body解码到result中
+func (c *client) wait(name string, result interface{}) {
+	c.t.Helper()
+	for {
+		var msg message
+		if len(c.pending) > 0 {
+			msg, c.pending = c.pending[0], c.pending[1:]
+		} else {
+			msg = c.next()
+		}
+		if msg.Type != "event" {
+			c.t.Fatalf("unexpected response %+v while waiting for %s", msg, name)
+		}
+		if msg.Event != name {
+			continue
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Body, result); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+}
+
+func (c *client) close() {
+	c.t.Helper()
+	c.mustCall("disconnect", nil, nil)
+	select {
+	case err := <-c.done:
+		if err != nil {
+			c.t.Fatalf("Serve: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("adapter did not exit after disconnect")
+	}
+}
+
+func writeProgram(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "test.mk")
+	if err := os.WriteFile(path, []byte(testProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type stopped struct {
+	Reason string `json:"reason"`
+	Text   string `json:"text"`
+}
+
+func (c *client) topLine() int {
+	c.t.Helper()
+	var trace struct{ StackFrames []StackFrame }
+	c.mustCall("stackTrace", map[string]int{"threadId": threadID}, &trace)
+	return trace.StackFrames[0].Line
+}
+
+func (c *client) evaluate(expr string) string {
+	c.t.Helper()
+	var result struct{ Result string }
+	c.mustCall("evaluate", map[string]string{"expression": expr}, &result)
+	return result.Result
+}
+
+func TestDebugSession(t *testing.T) {
+	c := startClient(t)
+	var caps Capabilities
+	c.mustCall("initialize", map[string]string{"adapterID": "monkey"}, &caps)
+	if !caps.SupportsConditionalBreakpoints {
+		t.Errorf("capabilities = %+v", caps)
+	}
+	c.wait("initialized", nil)
+	c.mustCall("launch", LaunchArguments{Program: writeProgram(t)}, nil)
+
+	var bps struct{ Breakpoints []Breakpoint }
+	c.mustCall("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: "test.mk"},
+		Breakpoints: []SourceBreakpoint{{Line: 2}, {Line: 7, Condition: "y > 100"}, {Line: 5, Condition: "x +"}},
+	}, &bps)
+	if len(bps.Breakpoints) != 3 || !bps.Breakpoints[0].Verified || !bps.Breakpoints[1].Verified || bps.Breakpoints[2].Verified {
+		t.Fatalf("breakpoints = %+v", bps.Breakpoints)
+	}
+	c.mustCall("configurationDone", nil, nil)
+
+	var stop stopped
+	c.wait("stopped", &stop)
+	if stop.Reason != "breakpoint" {
+		t.Errorf("stopped reason = %q, want breakpoint", stop.Reason)
+	}
+
+	var trace struct{ StackFrames []StackFrame }
+	c.mustCall("stackTrace", map[string]int{"threadId": threadID}, &trace)
+	if len(trace.StackFrames) != 2 {
+		t.Fatalf("stack = %+v", trace.StackFrames)
+	}
+	if f := trace.StackFrames[0]; f.Name != "add" || f.Line != 2 {
+		t.Errorf("frame 0 = %+v, want add at line 2", f)
+	}
+	if f := trace.StackFrames[1]; f.Name != "<program>" || f.Line != 6 {
+		t.Errorf("frame 1 = %+v, want <program> at line 6", f)
+	}
+
+	var scopes struct{ Scopes []Scope }
+	c.mustCall("scopes", FrameArguments{FrameID: trace.StackFrames[0].ID}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("scopes = %+v", scopes.Scopes)
+	}
+	var vars struct{ Variables []Variable }
+	c.mustCall("variables", VariablesArguments{VariablesReference: scopes.Scopes[0].VariablesReference}, &vars)
+	got := map[string]string{}
+	for _, v := range vars.Variables {
+		got[v.Name] = v.Value
+	}
+	if got["a"] != "1" || got["b"] != "2" {
+		t.Errorf("locals = %+v", vars.Variables)
+	}
+
+	if got := c.evaluate("a + b"); got != "3" {
+		t.Errorf("evaluate a + b = %q, want 3", got)
+	}
+	if msg := c.call("evaluate", map[string]interface{}{"expression": "nope", "frameId": 0}, nil); msg.Success {
+		t.Errorf("evaluate of an unknown identifier succeeded")
+	}
+
+	c.mustCall("next", map[string]int{"threadId": threadID}, nil)
+	c.wait("stopped", &stop)
+	if line := c.topLine(); stop.Reason != "step" || line != 3 {
+		t.Errorf("after next: %s at line %d, want step at line 3", stop.Reason, line)
+	}
+	c.mustCall("stepOut", map[string]int{"threadId": threadID}, nil)
+	c.wait("stopped", &stop)
+	if line := c.topLine(); line != 7 {
+		t.Errorf("after stepOut: line %d, want 7", line)
+	}
+	if got := c.evaluate("y"); got != "3" {
+		t.Errorf("y = %q, want 3", got)
+	}
+
+	c.mustCall("continue", map[string]int{"threadId": threadID}, nil)
+	var output struct{ Category, Output string }
+	c.wait("output", &output)
+	if output.Output != "30\n" {
+		t.Errorf("output = %+v", output)
+	}
+	var exited struct{ ExitCode int }
+	c.wait("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("exit code = %d", exited.ExitCode)
+	}
+	c.wait("terminated", nil)
+	c.close()
+}
+
+func TestDisconnectWhilePaused(t *testing.T) {
+	c := startClient(t)
+	c.mustCall("initialize", nil, nil)
+	if msg := c.call("stackTrace", nil, nil); msg.Success {
+		t.Errorf("stackTrace before launch succeeded")
+	}
+	if msg := c.call("bogus", nil, nil); msg.Success || msg.Message != `unsupported command "bogus"` {
+		t.Errorf("bogus command: %+v", msg)
+	}
+	c.mustCall("launch", LaunchArguments{Program: writeProgram(t), StopOnEntry: true}, nil)
+	c.mustCall("configurationDone", nil, nil)
+
+	var stop stopped
+	c.wait("stopped", &stop)
+	if line := c.topLine(); stop.Reason != "entry" || line != 1 {
+		t.Errorf("%s at line %d, want entry at line 1", stop.Reason, line)
+	}
+	c.close()
+}
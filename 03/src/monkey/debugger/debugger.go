@@ -0,0 +1,264 @@
+package debugger
+
+// 单步调试器：安装到解释器（evaluator.WithDebugger）后，在断点（行断点和条件断点）处
+// 或单步执行时于语句之前暂停，调用OnStop。OnStop在求值的goroutine中运行，
+// 可以在其中检查调用栈和环境、对表达式求值，返回后按其结果继续执行。
+// 同一时间只能调试一个求值过程
+
+import (
+	"errors"
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Action 暂停后如何继续执行
+type Action int
+
+const (
+	Continue Action = iota //运行到下一个断点
+	StepIn                 //执行到下一条语句，包括进入被调用的函数
+	StepOver               //执行到当前函数（或更外层）的下一条语句
+	StepOut                //执行到当前函数返回后的下一条语句
+	Abort                  //停止求值
+)
+
+// ErrAborted OnStop返回Abort时，求值以该错误结束
+var ErrAborted = errors.New("debugger: execution aborted")
+
+// Breakpoint 行断点，Condition非空时只有条件为真时才暂停
+type Breakpoint struct {
+	Line      int
+	Condition string
+	cond      ast.Node //解析后的条件
+	Hits      int      //暂停的次数
+}
+
+// Stop 暂停时的状态，只在OnStop执行期间有效
+type Stop struct {
+	Reason string //breakpoint、step、pause 或 entry
+	Stmt   ast.Statement
+	Pos    token.Position
+	Env    *object.Environment //当前语句所在的环境
+	Frames []evaluator.Frame   //调用栈，最内层在最后
+	Err    error               //条件断点的条件求值出错时非nil
+}
+
+// Debugger 实现evaluator.Debugger
+type Debugger struct {
+	OnStop func(stop *Stop) Action //暂停时调用
+
+	mu          sync.Mutex
+	breakpoints map[int]*Breakpoint
+	watches     []string
+	action      Action //上一次暂停后的继续方式
+	depth       int    //上一次暂停时的调用栈深度
+	pause       bool   //在下一条语句之前暂停
+	reason      string //pause为true时暂停的原因
+	lastLine    int    //上一条语句所在的行和栈深度，同一行的多条语句只在断点处暂停一次
+	lastDepth   int
+}
+
+// New 创建调试器，onStop在每次暂停时调用
+func New(onStop func(stop *Stop) Action) *Debugger {
+	return &Debugger{OnStop: onStop, breakpoints: map[int]*Breakpoint{}}
+}
+
+// SetBreakpoint 在line行设置断点，condition非空时是每次到达时求值的条件表达式
+func (d *Debugger) SetBreakpoint(line int, condition string) (*Breakpoint, error) {
+	if line <= 0 {
+		return nil, fmt.Errorf("invalid line %d", line)
+	}
+	bp := &Breakpoint{Line: line, Condition: strings.TrimSpace(condition)}
+	if bp.Condition != "" {
+		program, err := parse(bp.Condition)
+		if err != nil {
+			return nil, err
+		}
+		bp.cond = program
+	}
+	d.mu.Lock()
+	d.breakpoints[line] = bp
+	d.mu.Unlock()
+	return bp, nil
+}
+
+// ClearBreakpoint 删除line行的断点，line为0时删除全部断点
+func (d *Debugger) ClearBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if line == 0 {
+		d.breakpoints = map[int]*Breakpoint{}
+		return
+	}
+	delete(d.breakpoints, line)
+}
+
+// Breakpoints 按行号排列的全部断点
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := make([]*Breakpoint, 0, len(d.breakpoints))
+	for _, bp := range d.breakpoints {
+		list = append(list, bp)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Line < list[j].Line })
+	return list
+}
+
+// AddWatch 添加监视表达式，每次暂停时可以用Watch求值
+func (d *Debugger) AddWatch(expr string) error {
+	if _, err := parse(expr); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.watches = append(d.watches, strings.TrimSpace(expr))
+	d.mu.Unlock()
+	return nil
+}
+
+// Watches 全部监视表达式
+func (d *Debugger) Watches() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.watches...)
+}
+
+// Pause 在下一条语句之前暂停，可以在其他goroutine中调用。reason为Stop.Reason
+func (d *Debugger) Pause(reason string) {
+	d.mu.Lock()
+	d.pause = true
+	d.reason = reason
+	d.mu.Unlock()
+}
+
+// BeforeStatement 实现evaluator.Debugger
+func (d *Debugger) BeforeStatement(stmt ast.Statement, env *object.Environment, stack []evaluator.Frame) error {
+	pos := stmt.Pos()
+	depth := len(stack)
+
+	d.mu.Lock()
+	reason := ""
+	switch {
+	case d.pause:
+		reason = d.reason
+		d.pause = false
+	case d.action == StepIn && d.depth > 0:
+		reason = "step"
+	case d.action == StepOver && d.depth > 0 && depth <= d.depth:
+		reason = "step"
+	case d.action == StepOut && d.depth > 0 && depth < d.depth:
+		reason = "step"
+	}
+	bp := d.breakpoints[pos.Line]
+	newLine := pos.Line != d.lastLine || depth != d.lastDepth
+	d.lastLine, d.lastDepth = pos.Line, depth
+	d.mu.Unlock()
+
+	var condErr error
+	if reason == "" && bp != nil && newLine {
+		hit, err := bp.hit(env)
+		if hit || err != nil {
+			reason, condErr = "breakpoint", err
+			d.mu.Lock()
+			bp.Hits++
+			d.mu.Unlock()
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+
+	action := Continue
+	if d.OnStop != nil {
+		frames := append([]evaluator.Frame(nil), stack...)
+		action = d.OnStop(&Stop{Reason: reason, Stmt: stmt, Pos: pos, Env: env, Frames: frames, Err: condErr})
+	}
+	if action == Abort {
+		d.reset()
+		return ErrAborted
+	}
+	d.mu.Lock()
+	d.action, d.depth = action, depth
+	d.mu.Unlock()
+	return nil
+}
+
+// 一次求值结束后（或中止时）清除单步状态，断点和监视表达式保留
+func (d *Debugger) reset() {
+	d.mu.Lock()
+	d.action, d.depth, d.pause = Continue, 0, false
+	d.lastLine, d.lastDepth = 0, 0
+	d.mu.Unlock()
+}
+
+// Done 一次求值结束后调用，清除单步状态，使下一次求值只在断点处暂停
+func (d *Debugger) Done() {
+	d.reset()
+}
+
+func (bp *Breakpoint) hit(env *object.Environment) (bool, error) {
+	if bp.cond == nil {
+		return true, nil
+	}
+	result := evaluator.Eval(bp.cond, env)
+	if errObj, ok := result.(*object.Error); ok {
+		return false, fmt.Errorf("breakpoint condition %q: %s", bp.Condition, errObj.Message)
+	}
+	return isTruthy(result), nil
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case nil, evaluator.NULL, evaluator.FALSE:
+		return false
+	}
+	return true
+}
+
+// Eval 在暂停处的环境中对表达式求值（例：监视表达式），表达式可以读取局部变量
+func Eval(expr string, env *object.Environment) (object.Object, error) {
+	program, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	result := evaluator.Eval(program, env)
+	if result == nil {
+		result = evaluator.NULL
+	}
+	return result, nil
+}
+
+// 表达式不经过resolver解析，标识符按名字在环境中查找，帧中的局部变量也能找到
+func parse(expr string) (*ast.Program, error) {
+	p := parser.New(lexer.New(expr))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("syntax error in %q: %s", expr, strings.Join(p.Errors(), "; "))
+	}
+	return program, nil
+}
+
+// Variable 环境中的一个绑定
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+// Variables 只在本层环境中的绑定，按名字排列；用env.Outer()查看外层
+func Variables(env *object.Environment) []Variable {
+	var vars []Variable
+	for _, name := range env.LocalNames() {
+		if val, ok := env.Get(name); ok {
+			vars = append(vars, Variable{Name: name, Value: val})
+		}
+	}
+	return vars
+}
@@ -0,0 +1,149 @@
+package debugger
+
+import (
+	"fmt"
+	"monkey/evaluator"
+	"monkey/object"
+	"strings"
+	"testing"
+)
+
+const program = `let add = fn(a, b) {
+    let sum = a + b;
+    sum
+};
+let twice = fn(x) {
+    let y = add(x, x);
+    y
+};
+let result = twice(3);
+result + 1`
+
+// 按顺序执行actions，记录每次暂停的位置
+func run(t *testing.T, d *Debugger, actions ...Action) ([]string, object.Object) {
+	t.Helper()
+	var stops []string
+	d.OnStop = func(stop *Stop) Action {
+		frame := stop.Frames[len(stop.Frames)-1]
+		stops = append(stops, fmt.Sprintf("%s %d %s/%d", stop.Reason, stop.Pos.Line, frame.Name, len(stop.Frames)))
+		if len(actions) == 0 {
+			return Continue
+		}
+		a := actions[0]
+		actions = actions[1:]
+		return a
+	}
+	result, err := evaluator.New(evaluator.WithDebugger(d)).Run(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Done()
+	return stops, result
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		name     string
+		actions  []Action
+		expected []string
+	}{
+		{"step in", []Action{StepIn, StepIn, StepIn, StepIn, StepIn, StepIn, Continue},
+			[]string{"entry 1 <program>/1", "step 5 <program>/1", "step 9 <program>/1", "step 6 twice/2", "step 2 add/3", "step 3 add/3", "step 7 twice/2"}},
+		{"step over", []Action{StepOver, StepOver, StepOver, StepOver},
+			[]string{"entry 1 <program>/1", "step 5 <program>/1", "step 9 <program>/1", "step 10 <program>/1"}},
+		{"step out", []Action{StepIn, StepIn, StepIn, StepIn, StepOut, StepOut},
+			[]string{"entry 1 <program>/1", "step 5 <program>/1", "step 9 <program>/1", "step 6 twice/2", "step 2 add/3", "step 7 twice/2", "step 10 <program>/1"}},
+	}
+
+	for _, tt := range tests {
+		d := New(nil)
+		d.Pause("entry")
+		stops, result := run(t, d, tt.actions...)
+		if strings.Join(stops, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: wrong stops.\nwant=%q\ngot =%q", tt.name, tt.expected, stops)
+		}
+		if result.Inspect() != "7" {
+			t.Errorf("%s: wrong result %s", tt.name, result.Inspect())
+		}
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	d := New(nil)
+	if _, err := d.SetBreakpoint(2, "a == 3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.SetBreakpoint(10, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.SetBreakpoint(3, "a +"); err == nil {
+		t.Errorf("expected syntax error in condition")
+	}
+	stops, _ := run(t, d)
+	expected := []string{"breakpoint 2 add/3", "breakpoint 10 <program>/1"}
+	if strings.Join(stops, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong stops. want=%q, got=%q", expected, stops)
+	}
+	if bps := d.Breakpoints(); len(bps) != 2 || bps[0].Hits != 1 || bps[1].Hits != 1 {
+		t.Errorf("wrong breakpoint hits: %+v", bps)
+	}
+
+	//条件求值出错时也暂停，并给出错误
+	d.ClearBreakpoint(0)
+	d.SetBreakpoint(6, "missing > 1")
+	var condErr error
+	d.OnStop = func(stop *Stop) Action {
+		condErr = stop.Err
+		return Continue
+	}
+	evaluator.New(evaluator.WithDebugger(d)).Run(program)
+	if condErr == nil || !strings.Contains(condErr.Error(), "identifier not found: missing") {
+		t.Errorf("expected condition error, got %v", condErr)
+	}
+}
+
+func TestInspectAndAbort(t *testing.T) {
+	d := New(nil)
+	d.SetBreakpoint(3, "")
+	if err := d.AddWatch("sum * 10"); err != nil {
+		t.Fatal(err)
+	}
+	var watched, locals, globals []string
+	d.OnStop = func(stop *Stop) Action {
+		for _, w := range d.Watches() {
+			val, err := Eval(w, stop.Env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			watched = append(watched, w+" = "+val.Inspect())
+		}
+		for _, v := range Variables(stop.Env) {
+			locals = append(locals, v.Name+"="+v.Value.Inspect())
+		}
+		for env := stop.Env; env != nil; env = env.Outer() {
+			if env.Outer() == nil {
+				for _, v := range Variables(env) {
+					globals = append(globals, v.Name)
+				}
+			}
+		}
+		return Abort
+	}
+
+	result, err := evaluator.New(evaluator.WithDebugger(d)).Run(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errObj, ok := result.(*object.Error); !ok || errObj.Message != ErrAborted.Error() {
+		t.Errorf("expected aborted error, got %s", result.Inspect())
+	}
+	if strings.Join(watched, ",") != "sum * 10 = 60" {
+		t.Errorf("wrong watches %q", watched)
+	}
+	if strings.Join(locals, ",") != "a=3,b=3,sum=6" {
+		t.Errorf("wrong locals %q", locals)
+	}
+	if strings.Join(globals, ",") != "add,twice" {
+		t.Errorf("wrong globals %q", globals)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"monkey/stdlib"
+	"os"
+)
+
+// monkey doc [-o file] 输出内置函数和标准库的文档（Markdown），stdlib/README.md由它生成
+func runDoc(args []string) int {
+	fs := flag.NewFlagSet("doc", flag.ExitOnError)
+	out := fs.String("o", "", "写入该文件，而不是输出到标准输出")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey doc [-o file]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var buf bytes.Buffer
+	if err := stdlib.WriteMarkdown(&buf); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *out == "" {
+		os.Stdout.Write(buf.Bytes())
+		return 0
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
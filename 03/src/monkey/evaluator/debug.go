@@ -0,0 +1,46 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+)
+
+// Debugger 调试器，求值每条语句之前被调用。
+// 可以在BeforeStatement中阻塞以暂停程序，返回非nil的错误时停止求值，错误作为*object.Error返回
+type Debugger interface {
+	BeforeStatement(stmt ast.Statement, env *object.Environment, stack []Frame) error
+}
+
+// Frame 调用栈中的一层，stack[0]是顶层程序，最后一个是当前正在执行的函数
+type Frame struct {
+	Name string              //被调用的函数，标识符以外的被调用表达式为其源码
+	Call token.Position      //调用处的位置，顶层程序为零值
+	Env  *object.Environment //该层最近执行的语句所在的环境
+}
+
+// WithDebugger 每次求值时调用调试器，见debugger包
+func WithDebugger(d Debugger) Option {
+	return func(in *Interpreter) { in.debugger = d }
+}
+
+// 语句之前调用调试器，stack的最后一层记录当前的环境
+func (s *state) beforeStatement(stmt ast.Statement, env *object.Environment) *object.Error {
+	s.stack[len(s.stack)-1].Env = env
+	if err := s.debugger.BeforeStatement(stmt, env, s.stack); err != nil {
+		return s.fail(newError("%s", err))
+	}
+	return nil
+}
+
+// 有调试器时调用函数，调用期间在栈中压入一层
+func (s *state) debugCall(node *ast.CallExpression, function object.Object, args []object.Object) object.Object {
+	name := node.Function.String()
+	if ident, ok := node.Function.(*ast.Identifier); ok {
+		name = ident.Value
+	}
+	s.stack = append(s.stack, Frame{Name: name, Call: node.Pos()})
+	result := s.applyFunction(function, args)
+	s.stack = s.stack[:len(s.stack)-1]
+	return result
+}
@@ -6,6 +6,7 @@ import (
 	"monkey/object"
 )
 
+// 只读的共享实例，可被所有goroutine同时使用，不能修改（见interpreter.go的并发模型）
 var (
 	NULL = &object.Null{} //空值类型的实例
 	//bool AST求值优化，用引用避免每次求值都要新建实例
@@ -14,59 +15,109 @@ var (
 )
 
 // 对ast语法树进行遍历求值,*object.Environment 求值对应的环境 -全局域和局部域
+// 不限制资源，需要限制时用Interpreter（见WithLimits）
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return (&state{}).eval(node, env)
+}
+
+func (s *state) eval(node ast.Node, env *object.Environment) object.Object {
+	if s.hooks != nil { //有回调时先经过evalHooked，它再调用eval求值本节点
+		if !s.entered {
+			return s.evalHooked(node, env)
+		}
+		s.entered = false
+	}
+	if s.limited {
+		if err := s.step(); err != nil {
+			return err
+		}
+	}
 	switch node := node.(type) { //传入ast语法树的类型
 	case *ast.Program: //开始都是Program节点
-		return evalProgram(node, env) //开始都是Program节点，传入Statements，逐句解析
+		return s.evalProgram(node, env) //开始都是Program节点，传入Statements，逐句解析
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env) //表达式节点：进一步解析表达式，ast往下
+		return s.eval(node.Expression, env) //表达式节点：进一步解析表达式，ast往下
 	case *ast.PrefixExpression: //前缀节点
-		right := Eval(node.Right, env)
+		right := s.eval(node.Right, env)
 		if isError(right) { //如果Eval解析错误，返回Error节点，及时抛出
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right) //表达式节点：进一步解析表达式，ast往下
 	case *ast.InfixExpression: //中缀节点
-		left := Eval(node.Left, env)
+		left := s.eval(node.Left, env)
 		if isError(left) { //如果Eval解析错误，返回Error节点，及时抛出
 			return left
 		}
-		right := Eval(node.Right, env)
+		right := s.eval(node.Right, env)
 		if isError(right) { //如果Eval解析错误，返回Error节点，及时抛出
 			return right
 		}
+		if s.limited && s.limits.MaxSize > 0 { //限制字符串大小，避免反复拼接耗尽内存
+			if err := s.checkConcat(node.Operator, left, right); err != nil {
+				return err
+			}
+		}
 		return evalInfixExpression(node.Operator, left, right) //表达式节点：进一步解析表达式，ast往下
 	case *ast.BlockStatement: //表达式-区块节点{}
-		return evalBlockStatement(node, env)
+		return s.evalBlockStatement(node, env)
 	case *ast.IfExpression: //表达式节点 -if-esle节点
-		return evalIfExpression(node, env)
+		return s.evalIfExpression(node, env)
 	case *ast.ReturnStatement: //return节点
-		val := Eval(node.ReturnValue, env)
+		val := s.eval(node.ReturnValue, env)
 		if isError(val) { //如果Eval解析错误，返回Error节点，及时抛出
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
-		val := Eval(node.Value, env) //解析letAST的value指向的表达式节点
+		val := s.eval(node.Value, env) //解析letAST的value指向的表达式节点
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if node.Name.Local { //resolver解析过的局部变量，直接写入帧的槽位
+			env.SetAt(node.Name.Slot, val)
+		} else {
+			env.Set(node.Name.Value, val)
+		}
+		if s.hooks != nil {
+			s.hooks.Bind(node.Name, val, env)
+		}
+	case *ast.ImportStatement:
+		return s.evalImport(node, env)
 	case *ast.FunctionLiteral: //定义函数——函数字面量'fn' AST
 		params := node.Parameters
 		body := node.Body
 		//封装 形参，函数体，局部域
-		return &object.Function{Parameters: params, Env: env, Body: body} //仅是声明，返回封装的函数
+		return &object.Function{Parameters: params, Env: env, Body: body, Locals: node.Locals} //仅是声明，返回封装的函数
+	case *ast.SelectorExpression: //模块成员 math.sqrt
+		x := s.eval(node.X, env)
+		if isError(x) {
+			return x
+		}
+		return evalSelector(node, x)
+	case *ast.MacroLiteral: //宏只能在顶层用let定义，由DefineMacros在求值前取走
+		return newError("macro literal must be bound by a top-level let statement")
 	case *ast.CallExpression: //调用函数 AST
-		function := Eval(node.Function, env) //函数字面量(fn)和函数名的标识符，封装为FUNCTION类型，函数名的标识符的value（也是*ast.FunctionLiteral）会被解析返回FUNCTION
+		if node.Function.TokenLiteral() == "quote" { //quote(expr)不对参数求值，返回ast
+			if len(node.Arguments) != 1 {
+				return newError("wrong number of arguments to quote: want=1, got=%d", len(node.Arguments))
+			}
+			return s.quote(node.Arguments[0], env)
+		}
+		function := s.eval(node.Function, env) //函数字面量(fn)和函数名的标识符，封装为FUNCTION类型，函数名的标识符的value（也是*ast.FunctionLiteral）会被解析返回FUNCTION
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env) //1. 对参数求值，node.Arguments函数的参数
-		if len(args) == 1 && isError(args[0]) {      //遇到错误，停止求值
+		args := s.evalExpressions(node.Arguments, env) //1. 对参数求值，node.Arguments函数的参数
+		if len(args) == 1 && isError(args[0]) {        //遇到错误，停止求值
 			return args[0]
 		}
-		return applyFunction(function, args) //调用函数，给入函数名（封装的FUNCTION类型）和参数集
+		if s.hooks != nil {
+			return s.hookedCall(node, function, args)
+		}
+		if s.debugger != nil {
+			return s.debugCall(node, function, args)
+		}
+		return s.applyFunction(function, args) //调用函数，给入函数名（封装的FUNCTION类型）和参数集
 
 	//终端节点
 	case *ast.IntegerLiteral: //终端节点整数，返回值，以对象系统-原始数据类型 封装返回
@@ -74,6 +125,24 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.Boolean: //终端节点布尔，返回值，以对象系统-原始数据类型 封装返回
 		//return &object.Boolean{Value: node.Value}
 		return nativeboolToBooleanObject(node.Value) //bool AST求值返回，共用本地实例
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.ArrayLiteral: //数组字面量，按顺序对元素求值
+		elements := s.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.IndexExpression: //索引 array[0]
+		left := s.eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := s.eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
 	//从标识符获取对应的值
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
@@ -83,11 +152,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 }
 
 // 顶层程序语句集合
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (s *state) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range program.Statements {
-		result = Eval(statement, env) //目前只给一句
+		if s.debugger != nil {
+			if err := s.beforeStatement(statement, env); err != nil {
+				return err
+			}
+		}
+		result = s.eval(statement, env) //目前只给一句
 
 		switch result := result.(type) {
 		case *object.ReturnValue: //解析到return语句，停止求值，返回return 表达式的解析结果
@@ -100,11 +174,16 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 }
 
 // 嵌套语句集合{}
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (s *state) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env) //目前只给一句
+		if s.debugger != nil {
+			if err := s.beforeStatement(statement, env); err != nil {
+				return err
+			}
+		}
+		result = s.eval(statement, env) //目前只给一句
 
 		if result != nil { //嵌套语句解析到return || error 语句，停止求值，返回return 表达式的解析结果 ||error信息
 			rt := result.Type()
@@ -142,10 +221,12 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ: //两边都是数字
 		return evalIntergerInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ: //两边都是字符串
+		return evalStringInfixExpression(operator, left, right)
 	case operator == "==": //两边不全是数字，现在情况是都是布尔值的 ==运算支持
 		return nativeboolToBooleanObject(left == right) //布尔值相同，指针指向同一个 ==运算为真
 	case operator == "!=":
-		return nativeboolToBooleanObject(left == right)
+		return nativeboolToBooleanObject(left != right)
 	//错误处理
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s",
@@ -192,6 +273,9 @@ func evalIntergerInfixExpression(operator string, left object.Object, right obje
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %d / %d", leftVal, rightVal)
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case ">":
 		return nativeboolToBooleanObject(leftVal > rightVal)
@@ -207,17 +291,57 @@ func evalIntergerInfixExpression(operator string, left object.Object, right obje
 	}
 }
 
+// 中缀节点AST 求值 字符串支持 + 拼接和 ==、!= 按值比较
+func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeboolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeboolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+// 索引表达式求值：数组取元素，字符串取一个字节的子串，越界时返回null
+func evalIndexExpression(left, index object.Object) object.Object {
+	i, ok := index.(*object.Integer)
+	if !ok {
+		return newError("index must be INTEGER, got %s", index.Type())
+	}
+	switch left := left.(type) {
+	case *object.Array:
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return NULL
+		}
+		return left.Elements[i.Value]
+	case *object.String:
+		if i.Value < 0 || i.Value >= int64(len(left.Value)) {
+			return NULL
+		}
+		return &object.String{Value: left.Value[i.Value : i.Value+1]}
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
 // if节点AST 求值
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+func (s *state) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := s.eval(ie.Condition, env)
 	if isError(condition) { //如果Eval解析错误，返回Error节点，及时抛出
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env) //执行解析真值对应语句集
+		return s.eval(ie.Consequence, env) //执行解析真值对应语句集
 	} else if ie.Alternative != nil { //flase 且else存在
-		return Eval(ie.Alternative, env)
+		return s.eval(ie.Alternative, env)
 	} else { //else不存在
 		return NULL
 	}
@@ -252,19 +376,25 @@ func isError(obj object.Object) bool {
 
 // 从环境中查找标识符对应的值 map{标识符,值}
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	val, ok := env.Get(node.Value) //node.Value存标识符string
+	var val object.Object
+	var ok bool
+	if node.Local { //resolver解析过的局部变量，按 (层数,槽位) 直接取
+		val, ok = env.GetAt(node.Depth, node.Slot, node.Value)
+	} else {
+		val, ok = env.Get(node.Value) //node.Value存标识符string
+	}
 	if !ok {
-		return newError("identifier notfound: " + node.Value)
+		return newError("identifier not found: " + node.Value)
 	}
 	return val
 }
 
 // 调用函数，对参数求值 参数是表达式集合
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (s *state) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
 	for _, e := range exps { //对调用函数的各个参数求值
-		evaluated := Eval(e, env)
+		evaluated := s.eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated} //error类
 		}
@@ -275,19 +405,49 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 
 // 调用函数*ast.CallExpression处理返回，给入函数名（封装的FUNCTION类型或？？）和参数集
 // 求值函数体
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func (s *state) applyFunction(fn object.Object, args []object.Object) object.Object {
+	if builtin, ok := fn.(*object.Builtin); ok {
+		return builtin.Fn(s, args...)
+	}
 	function, ok := fn.(*object.Function) //??标识符
 	if !ok {
 		return newError("not a function: %s", fn.Type())
 	}
+	if len(args) != len(function.Parameters) {
+		return newError("wrong number of arguments: want=%d, got=%d", len(function.Parameters), len(args))
+	}
+
+	if s.depth >= s.maxDepth() { //总是限制调用深度，避免无限递归耗尽栈
+		return s.fail(newError("maximum call depth exceeded: %d", s.maxDepth()))
+	}
+	s.depth++
+	defer func() { s.depth-- }()
 
 	extendedEnv := extendFunctionEnv(function, args) //参数绑定，形参和实参，并扩展域
-	evaluated := Eval(function.Body, extendedEnv)    //函数体求值
-	return unwrapReturnValue(evaluated)              //有无return语句的处理
+	if s.hooks != nil {
+		for i, param := range function.Parameters {
+			s.hooks.Bind(param, args[i], extendedEnv)
+		}
+	}
+	evaluated := s.eval(function.Body, extendedEnv) //函数体求值
+	return unwrapReturnValue(evaluated)             //有无return语句的处理
+}
+
+// Call 内置函数调用函数参数，实现object.Caller
+func (s *state) Call(fn object.Object, args ...object.Object) object.Object {
+	return s.applyFunction(fn, args)
 }
 
 // 参数绑定，形参和实参，并扩展域
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+	if fn.Locals != nil { //resolver解析过的函数，参数按槽位存放在帧中
+		frame := object.NewFrame(fn.Env, fn.Locals)
+		for paramIdx, param := range fn.Parameters {
+			frame.SetAt(param.Slot, args[paramIdx])
+		}
+		return frame
+	}
+
 	env := object.NewEnclodedEnvironment(fn.Env) //创建基于外部域的新内部域
 	//*object.Function 内部的形参，args[]外部的实参(已经被求值过)
 	for paramIdx, param := range fn.Parameters {
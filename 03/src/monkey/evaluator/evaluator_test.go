@@ -4,6 +4,7 @@ import (
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/resolver"
 	"testing"
 )
 
@@ -41,6 +42,7 @@ func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
+	resolver.Resolve(program)
 	env := object.NewEnviroment() // 每次测试求值前，建一个新的环境
 
 	return Eval(program, env) //Eval对ast求值
@@ -245,6 +247,14 @@ if (10 > 1) {
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			"10 / (5 - 5)",
+			"division by zero: 10 / 0",
+		},
+		{
+			"let f = fn(a, b) { a + b }; f(1)",
+			"wrong number of arguments: want=2, got=1",
+		},
 	}
 
 	for _, tt := range tests {
@@ -343,3 +353,88 @@ ourFunction(20) + first + second;`
 
 	testIntegerObject(t, testEval(input), 70)
 }
+
+func TestStringsAndArrays(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`"a" == "a"`, "true"},
+		{`"a" != "a"`, "false"},
+		{`"a" - "b"`, "ERRORunknown operator: STRING - STRING"},
+		{`"a" + 1`, "ERRORtype mismatch: STRING + INTEGER"},
+		{"[1, 2 * 2, 3 + 3]", "[1, 4, 6]"},
+		{"let xs = [1, 2, 3]; xs[0] + xs[1] + xs[2]", "6"},
+		{"let i = 0; [1][i]", "1"},
+		{"[1, 2, 3][3]", "null"},
+		{"[1, 2, 3][-1]", "null"},
+		{`"abc"[1]`, "b"},
+		{"[1][true]", "ERRORindex must be INTEGER, got BOOLEAN"},
+		{"5[0]", "ERRORindex operator not supported: INTEGER"},
+		{"let f = fn(x) { [x, x * 2] }; f(2)[1]", "4"},
+		{"[1, foo]", "ERRORidentifier not found: foo"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// 解析过槽位与未解析（map环境）的求值结果一致
+func TestResolvedEvaluation(t *testing.T) {
+	tests := []string{
+		"let f = fn(x) { let y = x * 2; y + x }; f(3)",
+		"let x = 1; let f = fn() { let g = fn() { x }; let x = 2; g() }; f()",
+		"let f = fn(x) { if (x > 1) { let y = 10; } y }; f(0)",
+		"let f = fn(x) { if (x > 1) { let y = 10; } y }; f(2)",
+		"let adder = fn(a) { fn(b) { fn(c) { a + b + c } } }; adder(1)(2)(3)",
+		"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(10)",
+		"let f = fn(a, a) { a }; f(1, 2)",
+		"let f = fn(a) { let a = a + 1; a }; f(1)",
+		"let f = fn(a) { a }; f(1, 2)",
+	}
+
+	for _, input := range tests {
+		unresolved := Eval(testParseProgram(input), object.NewEnviroment())
+		resolved := testEval(input)
+		if unresolved.Inspect() != resolved.Inspect() {
+			t.Errorf("%s: resolved=%s, unresolved=%s", input, resolved.Inspect(), unresolved.Inspect())
+		}
+	}
+}
+
+const (
+	fibSource = `
+let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) };
+fib(20);`
+	closureSource = `
+let makeCounter = fn(start) {
+    let step = fn(n, acc) { if (n == 0) { return acc; } step(n - 1, acc + start) };
+    fn(n) { step(n, 0) }
+};
+let loop = fn(i, total) { if (i == 0) { return total; } loop(i - 1, total + makeCounter(i)(50)) };
+loop(200, 0);`
+)
+
+func benchmarkEval(b *testing.B, input string) {
+	for _, mode := range []string{"map", "slots"} {
+		b.Run(mode, func(b *testing.B) {
+			program := testParseProgram(input)
+			if mode == "slots" {
+				resolver.Resolve(program)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if result := Eval(program, object.NewEnviroment()); isError(result) {
+					b.Fatal(result.Inspect())
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFib(b *testing.B)      { benchmarkEval(b, fibSource) }
+func BenchmarkClosures(b *testing.B) { benchmarkEval(b, closureSource) }
@@ -0,0 +1,107 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// Hooks 求值过程的事件回调，用于性能分析、覆盖率统计、记录脚本的行为等。
+// 回调在求值的goroutine中同步调用，不能修改传入的ast和对象。
+// 只关心部分事件时可以嵌入NopHooks
+type Hooks interface {
+	EnterNode(node ast.Node, env *object.Environment) //开始求值节点
+	ExitNode(node ast.Node, result object.Object)     //节点求值结束，result可能是nil、*object.Error等
+	//调用函数之前，args是已求值的实参
+	Call(call *ast.CallExpression, fn object.Object, args []object.Object)
+	//函数调用结束，result是返回值或错误
+	Return(call *ast.CallExpression, fn object.Object, result object.Object)
+	//产生新的错误，node是最先返回该错误的节点；同一个错误向外传递时不再重复通知
+	Error(node ast.Node, err *object.Error)
+	//let语句或函数参数在env中绑定了名字
+	Bind(name *ast.Identifier, value object.Object, env *object.Environment)
+}
+
+// NopHooks 所有回调都什么也不做
+type NopHooks struct{}
+
+func (NopHooks) EnterNode(ast.Node, *object.Environment)                  {}
+func (NopHooks) ExitNode(ast.Node, object.Object)                         {}
+func (NopHooks) Call(*ast.CallExpression, object.Object, []object.Object) {}
+func (NopHooks) Return(*ast.CallExpression, object.Object, object.Object) {}
+func (NopHooks) Error(ast.Node, *object.Error)                            {}
+func (NopHooks) Bind(*ast.Identifier, object.Object, *object.Environment) {}
+
+// WithHooks 求值时调用h的回调。多次使用时按顺序调用每一个。
+// 没有设置时求值每个节点只多一次判断
+func WithHooks(h Hooks) Option {
+	return func(in *Interpreter) {
+		if in.hooks != nil {
+			h = multiHooks{in.hooks, h}
+		}
+		in.hooks = h
+	}
+}
+
+// 有回调时的节点求值
+func (s *state) evalHooked(node ast.Node, env *object.Environment) object.Object {
+	s.hooks.EnterNode(node, env)
+	s.entered = true
+	result := s.eval(node, env)
+	if err, ok := result.(*object.Error); ok && err != s.lastErr {
+		s.lastErr = err
+		s.hooks.Error(node, err)
+	}
+	s.hooks.ExitNode(node, result)
+	return result
+}
+
+// 有回调时调用函数
+func (s *state) hookedCall(node *ast.CallExpression, function object.Object, args []object.Object) object.Object {
+	s.hooks.Call(node, function, args)
+	var result object.Object
+	if s.debugger != nil {
+		result = s.debugCall(node, function, args)
+	} else {
+		result = s.applyFunction(function, args)
+	}
+	s.hooks.Return(node, function, result)
+	return result
+}
+
+type multiHooks []Hooks
+
+func (m multiHooks) EnterNode(node ast.Node, env *object.Environment) {
+	for _, h := range m {
+		h.EnterNode(node, env)
+	}
+}
+
+func (m multiHooks) ExitNode(node ast.Node, result object.Object) {
+	for _, h := range m {
+		h.ExitNode(node, result)
+	}
+}
+
+func (m multiHooks) Call(call *ast.CallExpression, fn object.Object, args []object.Object) {
+	for _, h := range m {
+		h.Call(call, fn, args)
+	}
+}
+
+func (m multiHooks) Return(call *ast.CallExpression, fn object.Object, result object.Object) {
+	for _, h := range m {
+		h.Return(call, fn, result)
+	}
+}
+
+func (m multiHooks) Error(node ast.Node, err *object.Error) {
+	for _, h := range m {
+		h.Error(node, err)
+	}
+}
+
+func (m multiHooks) Bind(name *ast.Identifier, value object.Object, env *object.Environment) {
+	for _, h := range m {
+		h.Bind(name, value, env)
+	}
+}
@@ -0,0 +1,128 @@
+package evaluator
+
+import (
+	"context"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/optimizer"
+	"monkey/parser"
+	"monkey/resolver"
+	"strings"
+)
+
+// 并发模型：
+//   - 求值过程没有包级可变状态。NULL、TRUE、FALSE是只读的共享实例，任何代码都不能修改它们；
+//     Integer、Boolean等对象创建后也不再修改，可以在goroutine之间自由传递。
+//   - 每个Interpreter拥有自己的全局环境，不同Interpreter之间互不影响，可以在不同goroutine中并行运行。
+//   - 同一个Interpreter同一时间只能被一个goroutine使用；
+//     若多个Interpreter需要共享全局绑定，用WithEnvironment传入object.NewSyncEnvironment()创建的环境。
+
+// Interpreter 一个独立的解释器实例
+type Interpreter struct {
+	env      *object.Environment //全局环境
+	macroEnv *object.Environment //宏定义所在的环境，宏展开阶段使用
+	optimize bool                //Run在求值前是否运行optimizer
+	limits   Limits              //每次求值的资源限制，见limits.go
+	ctx      context.Context     //结束时停止求值，nil表示不会被取消
+	debugger Debugger            //见debug.go
+	hooks    Hooks               //求值事件的回调，见hooks.go
+	modules  *modules            //加载过的模块，nil表示不允许import，见modules.go
+	file     string              //RunFile运行的主程序文件
+	builtins []*object.Builtin   //WithBuiltins给出的内置函数
+}
+
+// Option 解释器的可选配置，传给New
+type Option func(*Interpreter)
+
+// WithEnvironment 使用给定的全局环境，而不是新建一个
+func WithEnvironment(env *object.Environment) Option {
+	return func(in *Interpreter) { in.env = env }
+}
+
+// WithBuiltins 在全局环境和每个import的模块的环境中绑定内置函数，可以多次使用
+func WithBuiltins(builtins ...*object.Builtin) Option {
+	return func(in *Interpreter) { in.builtins = append(in.builtins, builtins...) }
+}
+
+// WithOptimizer Run在求值前对程序做常量折叠等优化，见optimizer包
+func WithOptimizer() Option {
+	return func(in *Interpreter) { in.optimize = true }
+}
+
+// New 创建解释器，默认使用新建的全局环境
+func New(opts ...Option) *Interpreter {
+	in := &Interpreter{}
+	for _, opt := range opts {
+		opt(in)
+	}
+	if in.env == nil {
+		in.env = object.NewEnviroment()
+	}
+	for _, b := range in.builtins {
+		in.env.Set(b.Name, b)
+	}
+	in.macroEnv = object.NewEnviroment()
+	return in
+}
+
+// Env 返回解释器的全局环境
+func (in *Interpreter) Env() *object.Environment {
+	return in.env
+}
+
+// Eval 在解释器的全局环境中对ast求值，受WithLimits、WithContext的限制
+func (in *Interpreter) Eval(node ast.Node) object.Object {
+	return in.newState().eval(node, in.env)
+}
+
+// Call 调用函数（例：从Env()中取出的Monkey函数或内置函数），受WithLimits、WithContext的限制
+func (in *Interpreter) Call(fn object.Object, args ...object.Object) object.Object {
+	return in.newState().Call(fn, args...)
+}
+
+// ExpandMacros 取出程序中的宏定义并展开宏调用，宏定义在同一解释器的后续程序中仍然有效
+func (in *Interpreter) ExpandMacros(program *ast.Program) (ast.Node, error) {
+	DefineMacros(program, in.macroEnv)
+	return ExpandMacros(program, in.macroEnv)
+}
+
+// ParseError 源码的语法分析错误
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return "parse errors:\n\t" + strings.Join(e.Errors, "\n\t")
+}
+
+// Run 解析、展开宏、（可选的）优化、解析变量槽位并求值一段源码，语法错误以*ParseError返回
+func (in *Interpreter) Run(input string) (object.Object, error) {
+	return in.run(input, "")
+}
+
+// RunFile 同Run，input是文件file的内容，其中相对路径的import从file所在目录查找，
+// 其他路径先在该目录中查找（见WithImports）
+func (in *Interpreter) RunFile(file, input string) (object.Object, error) {
+	in.file = file
+	return in.run(input, file)
+}
+
+func (in *Interpreter) run(input, file string) (object.Object, error) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	program.File = file
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+	expanded, err := in.ExpandMacros(program)
+	if err != nil {
+		return nil, err
+	}
+	program = expanded.(*ast.Program)
+	if in.optimize {
+		optimizer.Optimize(program)
+	}
+	resolver.Resolve(program)
+	return in.Eval(program), nil
+}
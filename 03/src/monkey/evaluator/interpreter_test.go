@@ -0,0 +1,262 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 多个解释器并行求值，结果互不影响，用 go test -race 检查数据竞争
+func TestConcurrentInterpreters(t *testing.T) {
+	const n = 300
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			in := New()
+			input := fmt.Sprintf(`
+let x = %d;
+let newAdder = fn(a) { fn(b) { a + b } };
+let addX = newAdder(x);
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+if (!(x == x) != false) { 0 } else { addX(fib(10)) }`, i)
+			result, err := in.Run(input)
+			if err != nil {
+				errs <- err
+				return
+			}
+			integer, ok := result.(*object.Integer)
+			if !ok || integer.Value != int64(i+55) {
+				errs <- fmt.Errorf("program %d: wrong result %v", i, result)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// 多个解释器共享一个并发安全的全局环境
+func TestSharedSyncEnvironment(t *testing.T) {
+	shared := object.NewSyncEnvironment()
+	if _, err := New(WithEnvironment(shared)).Run("let base = 100; let inc = fn(x) { x + 1 };"); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 200
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			in := New(WithEnvironment(shared))
+			result, err := in.Run(fmt.Sprintf("let v = inc(base + %d); v", i))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if integer, ok := result.(*object.Integer); !ok || integer.Value < 101 {
+				t.Errorf("wrong result %v", result)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if _, ok := shared.Get("v"); !ok {
+		t.Fatalf("shared binding v not set")
+	}
+}
+
+func TestRunParseError(t *testing.T) {
+	_, err := New().Run("let = 5;")
+	if _, ok := err.(*ParseError); !ok {
+		t.Fatalf("expected *ParseError. got=%T (%v)", err, err)
+	}
+}
+
+func TestRunWithOptimizer(t *testing.T) {
+	input := "let add = fn(x, y) { return x + y }; add(1 + 2 * (3 + 4) / 5 - 6, add(6, 7 * 8));"
+	for _, in := range []*Interpreter{New(), New(WithOptimizer())} {
+		result, err := in.Run(input)
+		if err != nil {
+			t.Fatalf("Run error: %s", err)
+		}
+		testIntegerObject(t, result, 59)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	loop := "let loop = fn(n) { loop(n + 1) }; loop(0)"
+	tests := []struct {
+		limits   Limits
+		input    string
+		expected string
+	}{
+		{Limits{MaxSteps: 100}, loop, "execution budget exceeded: more than 100 steps"},
+		{Limits{MaxDepth: 50}, loop, "maximum call depth exceeded: 50"},
+		{Limits{}, loop, fmt.Sprintf("maximum call depth exceeded: %d", DefaultMaxDepth)}, //没有设置时也限制调用深度，不会耗尽Go的栈
+		{Limits{MaxDepth: 1000, Timeout: time.Millisecond}, "let spin = fn(n) { if (n == 0) { 0 } else { spin(n - 1) + spin(n - 1) } }; spin(30)", "execution timed out after 1ms"},
+		{Limits{MaxSteps: 1000, MaxDepth: 10}, "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(9)", ""},
+		{Limits{MaxSize: 8}, `let s = "abcd"; s + s + s`, "size limit exceeded: 12 > 8"},
+		{Limits{MaxSize: 8}, `let s = "abcd"; s + s`, ""},
+	}
+
+	for _, tt := range tests {
+		in := New(WithLimits(tt.limits))
+		result, err := in.Run(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		errObj, isErr := result.(*object.Error)
+		switch {
+		case tt.expected == "" && isErr:
+			t.Errorf("%+v: unexpected error %s", tt.limits, errObj.Message)
+		case tt.expected != "" && !isErr:
+			t.Errorf("%+v: expected error %q, got %s", tt.limits, tt.expected, result.Inspect())
+		case isErr && errObj.Message != tt.expected:
+			t.Errorf("%+v: wrong error. want=%q, got=%q", tt.limits, tt.expected, errObj.Message)
+		}
+
+		//限制对每次求值分别计算
+		if result, _ := in.Run("1 + 1"); result.Inspect() != "2" {
+			t.Errorf("%+v: limits not reset between runs. got=%s", tt.limits, result.Inspect())
+		}
+	}
+}
+
+func TestContextInterrupt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := New(WithContext(ctx), WithLimits(Limits{MaxDepth: 1000}))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	result, err := in.Run("let spin = fn(n) { if (n == 0) { 0 } else { spin(n - 1) + spin(n - 1) } }; spin(40)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errObj, ok := result.(*object.Error)
+	if !ok || errObj.Message != "execution interrupted: context canceled" {
+		t.Errorf("expected interrupted error. got=%s", result.Inspect())
+	}
+}
+
+// 记录求值事件
+type recorder struct {
+	NopHooks
+	events []string
+	depth  int
+}
+
+func (r *recorder) EnterNode(node ast.Node, env *object.Environment) { r.depth++ }
+func (r *recorder) ExitNode(node ast.Node, result object.Object)     { r.depth-- }
+
+func (r *recorder) Call(call *ast.CallExpression, fn object.Object, args []object.Object) {
+	r.events = append(r.events, fmt.Sprintf("call %s %v", call.Function, inspectAll(args)))
+}
+
+func (r *recorder) Return(call *ast.CallExpression, fn object.Object, result object.Object) {
+	r.events = append(r.events, fmt.Sprintf("return %s %s", call.Function, result.Inspect()))
+}
+
+func (r *recorder) Error(node ast.Node, err *object.Error) {
+	r.events = append(r.events, fmt.Sprintf("error %s at %s: %s", node, node.Pos(), err.Message))
+}
+
+func (r *recorder) Bind(name *ast.Identifier, value object.Object, env *object.Environment) {
+	if _, ok := value.(*object.Function); ok {
+		r.events = append(r.events, "bind "+name.Value+" fn")
+		return
+	}
+	r.events = append(r.events, "bind "+name.Value+" "+value.Inspect())
+}
+
+func inspectAll(objs []object.Object) []string {
+	var out []string
+	for _, o := range objs {
+		out = append(out, o.Inspect())
+	}
+	return out
+}
+
+func TestHooks(t *testing.T) {
+	input := `let add = fn(a, b) { let sum = a + b; sum };
+let x = add(1, 2);
+add(x, 0) / 0`
+	expected := []string{
+		"bind add fn",
+		"call add [1 2]",
+		"bind a 1",
+		"bind b 2",
+		"bind sum 3",
+		"return add 3",
+		"bind x 3",
+		"call add [3 0]",
+		"bind a 3",
+		"bind b 0",
+		"bind sum 3",
+		"return add 3",
+		"error (add(x, 0) / 0) at 3:1: division by zero: 3 / 0",
+	}
+
+	for _, resolve := range []bool{false, true} {
+		r := &recorder{}
+		in := New(WithHooks(r))
+		var result object.Object
+		if resolve {
+			result, _ = in.Run(input)
+		} else {
+			result = in.Eval(testParseProgram(input))
+		}
+		if !isError(result) {
+			t.Fatalf("expected an error, got %s", result.Inspect())
+		}
+		if strings.Join(r.events, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("resolve=%t: wrong events.\nwant:\n%s\ngot:\n%s", resolve, strings.Join(expected, "\n"), strings.Join(r.events, "\n"))
+		}
+		if r.depth != 0 {
+			t.Errorf("resolve=%t: EnterNode and ExitNode unbalanced: %d", resolve, r.depth)
+		}
+	}
+
+	//多个回调按顺序都被调用
+	first, second := &recorder{}, &recorder{}
+	New(WithHooks(first), WithHooks(second)).Run("let y = 1")
+	if len(first.events) != 1 || len(second.events) != 1 {
+		t.Errorf("events: first=%v, second=%v", first.events, second.events)
+	}
+}
+
+// 内置函数通过Caller调用Monkey函数，Interpreter.Call从外部调用函数
+func TestBuiltins(t *testing.T) {
+	twice := &object.Builtin{Name: "twice", Fn: func(c object.Caller, args ...object.Object) object.Object {
+		return c.Call(args[0], c.Call(args[0], args[1]))
+	}}
+	in := New()
+	in.Env().Set("twice", twice)
+	result, err := in.Run("let inc = fn(x) { x + 1 }; twice(inc, 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testIntegerObject(t, result, 3)
+
+	inc, _ := in.Env().Get("inc")
+	testIntegerObject(t, in.Call(inc, &object.Integer{Value: 41}), 42)
+	if result := in.Call(twice, inc, &object.Integer{Value: 1}); result.Inspect() != "3" {
+		t.Errorf("calling a builtin: got %s", result.Inspect())
+	}
+	if result, _ := in.Run("twice(1, 1)"); !isError(result) || result.(*object.Error).Message != "not a function: INTEGER" {
+		t.Errorf("expected an error, got %s", result.Inspect())
+	}
+}
@@ -0,0 +1,130 @@
+package evaluator
+
+import (
+	"context"
+	"monkey/object"
+	"time"
+)
+
+// Limits 每次求值（Interpreter.Eval、Run）的资源限制，零值字段表示不限制（MaxDepth除外）。
+// 超出限制时求值停止，返回*object.Error
+type Limits struct {
+	MaxSteps int64         //最多求值的ast节点数
+	MaxDepth int           //函数调用的最大嵌套层数，0表示DefaultMaxDepth
+	Timeout  time.Duration //最长求值时间
+	MaxSize  int           //字符串的最大长度（字节数）和数组的最大元素个数，字符串拼接和内置函数创建结果前检查
+}
+
+// DefaultMaxDepth 没有设置Limits.MaxDepth时函数调用的最大嵌套层数，
+// 使无限递归返回错误，而不是耗尽Go的栈使整个进程崩溃
+const DefaultMaxDepth = 50000
+
+// WithLimits 限制每次求值使用的资源，例：网络REPL中限制客户端的程序
+func WithLimits(limits Limits) Option {
+	return func(in *Interpreter) { in.limits = limits }
+}
+
+// WithContext ctx结束（取消或超时）时停止正在进行的求值
+func WithContext(ctx context.Context) Option {
+	return func(in *Interpreter) { in.ctx = ctx }
+}
+
+// 每求值这么多个节点检查一次超时和ctx，避免每步都读时钟
+const checkInterval = 1024
+
+// 一次求值的状态。没有任何限制时limited为false，求值每一步只多一次判断；
+// 没有调试器时每条语句只多一次判断，没有回调时每个节点只多一次判断
+type state struct {
+	limited  bool
+	limits   Limits
+	ctx      context.Context
+	deadline time.Time
+	steps    int64
+	depth    int
+	err      *object.Error //超出限制的错误，之后的每一步都返回它，使求值尽快结束
+
+	debugger Debugger //nil表示不调试
+	stack    []Frame  //有调试器时维护的调用栈
+
+	hooks   Hooks         //nil表示没有回调，见hooks.go
+	entered bool          //evalHooked已经通知了EnterNode，eval直接求值
+	lastErr *object.Error //最近通知过的错误，向外传递时不再重复通知
+
+	modules  *modules          //nil表示不允许import，见modules.go
+	optimize bool              //加载的模块是否优化
+	root     string            //主程序所在的目录
+	dir      string            //正在求值的文件所在的目录，相对路径的import从这里查找
+	library  *Library          //正在求值的库模块所在的库，nil表示在文件中
+	builtins []*object.Builtin //每个模块的环境中绑定的内置函数
+}
+
+func (in *Interpreter) newState() *state {
+	s := &state{limits: in.limits, ctx: in.ctx, debugger: in.debugger, hooks: in.hooks,
+		modules: in.modules, optimize: in.optimize, root: in.mainDir(), dir: in.mainDir(), builtins: in.builtins}
+	if s.debugger != nil {
+		s.stack = []Frame{{Name: "<program>"}}
+	}
+	s.limited = in.limits != (Limits{}) || in.ctx != nil
+	if in.limits.Timeout > 0 {
+		s.deadline = time.Now().Add(in.limits.Timeout)
+	}
+	return s
+}
+
+// 计一步，超出限制时返回错误
+func (s *state) step() *object.Error {
+	if s.err != nil {
+		return s.err
+	}
+	s.steps++
+	if s.limits.MaxSteps > 0 && s.steps > s.limits.MaxSteps {
+		return s.fail(newError("execution budget exceeded: more than %d steps", s.limits.MaxSteps))
+	}
+	if s.steps%checkInterval != 0 {
+		return nil
+	}
+	if !s.deadline.IsZero() && time.Now().After(s.deadline) {
+		return s.fail(newError("execution timed out after %s", s.limits.Timeout))
+	}
+	if s.ctx != nil && s.ctx.Err() != nil {
+		return s.fail(newError("execution interrupted: %s", s.ctx.Err()))
+	}
+	return nil
+}
+
+// 函数调用的最大嵌套层数
+func (s *state) maxDepth() int {
+	if s.limits.MaxDepth > 0 {
+		return s.limits.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+// CheckSize 创建长度为n的字符串或数组前检查大小限制，实现object.SizeChecker
+func (s *state) CheckSize(n int) *object.Error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.limits.MaxSize > 0 && n > s.limits.MaxSize {
+		return s.fail(newError("size limit exceeded: %d > %d", n, s.limits.MaxSize))
+	}
+	return nil
+}
+
+// 字符串拼接left + right之前检查大小限制
+func (s *state) checkConcat(operator string, left, right object.Object) *object.Error {
+	l, ok := left.(*object.String)
+	if !ok || operator != "+" {
+		return nil
+	}
+	r, ok := right.(*object.String)
+	if !ok {
+		return nil
+	}
+	return s.CheckSize(len(l.Value) + len(r.Value))
+}
+
+func (s *state) fail(err *object.Error) *object.Error {
+	s.err = err
+	return err
+}
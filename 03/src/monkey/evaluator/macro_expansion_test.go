@@ -0,0 +1,191 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnviroment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	_, ok := env.Get("number")
+	if ok {
+		t.Fatalf("number should not be defined")
+	}
+	_, ok = env.Get("function")
+	if ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+
+	if macro.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", macro.Parameters[0])
+	}
+	if macro.Parameters[1].String() != "y" {
+		t.Fatalf("parameter is not 'y'. got=%q", macro.Parameters[1])
+	}
+
+	expectedBody := "(x + y)"
+
+	if macro.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, macro.Body.String())
+	}
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`
+			let infixExpression = macro() { quote(1 + 2); };
+
+			infixExpression();
+			`,
+			`(1 + 2)`,
+		},
+		{
+			`
+			let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+
+			reverse(2 + 2, 10 - 5);
+			`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+			let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+
+			unless(10 > 5, notGreater(), greater());
+			`,
+			`if (!(10 > 5)) { notGreater() } else { greater() }`,
+		},
+		{
+			`
+			let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+
+			reverse(1, 10) + reverse(100, 1000);
+			`,
+			`(10 - 1) + (1000 - 100)`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnviroment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("ExpandMacros error: %s", err)
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. want=%q, got=%q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let m = macro(x) { 5 }; m(1);`,
+			"macro m must return a quote, got INTEGER",
+		},
+		{
+			`let m = macro(x, y) { quote(x) }; m(1);`,
+			"wrong number of arguments to macro m: want=2, got=1",
+		},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+		env := object.NewEnviroment()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, err)
+		}
+	}
+}
+
+// 用宏实现unless和assert，经Interpreter.Run展开后求值
+func TestMacrosThroughInterpreter(t *testing.T) {
+	in := New()
+	_, err := in.Run(`
+	let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) };
+	let assert = macro(c) { quote(if (unquote(c)) { true } else { assertionFailed }) };
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := in.Run("unless(1 > 2, 10, 20)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testIntegerObject(t, result, 10)
+
+	//每次展开使用各自的参数
+	result, err = in.Run("unless(2 > 1, 10, 20) + unless(1 > 2, 1, 2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testIntegerObject(t, result, 21)
+
+	result, err = in.Run("assert(1 == 2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errObj, ok := result.(*object.Error)
+	if !ok || errObj.Message != "identifier not found: assertionFailed" {
+		t.Fatalf("expected assertion failure. got=%v", result)
+	}
+}
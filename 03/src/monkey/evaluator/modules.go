@@ -0,0 +1,346 @@
+package evaluator
+
+// 模块：import "lib/math" 加载另一个源文件（或一个目录中的全部源文件），在它自己的全局环境中求值，
+// export let 导出的绑定是模块的成员，用 math.sqrt 访问。
+//
+// 路径以 ./ 或 ../ 开头时相对于import语句所在文件的目录；否则依次在主程序所在的目录和
+// WithImports给出的搜索路径中查找。"lib/math" 对应文件 lib/math.mk，
+// 或者目录 lib/math 中除 *_test.mk 以外的全部 .mk 文件（按文件名顺序在同一个环境中求值）。
+// 以库的前缀开头的路径（例 "std/strings"）从WithLibrary给出的库中加载，不读取文件系统。
+// 每个模块在一个解释器中只求值一次，之后的import得到同一个模块；加载失败的模块不缓存。
+// 模块在求值过程中直接或间接地import自己时报告 import cycle not allowed，
+// 错误沿import链逐层加上 import "路径": 前缀
+
+import (
+	"fmt"
+	"io/fs"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/optimizer"
+	"monkey/parser"
+	"monkey/resolver"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 源文件的扩展名
+const sourceExt = ".mk"
+
+// WithImports 允许import语句从文件系统加载模块，searchPath是在主程序所在目录之后查找模块的目录。
+// 没有设置时import文件返回错误，例：网络REPL不允许客户端读取服务器上的文件
+func WithImports(searchPath ...string) Option {
+	return func(in *Interpreter) {
+		m := in.useModules()
+		m.files = true
+		m.searchPath = searchPath
+	}
+}
+
+// Library 随程序发布的模块库，例：stdlib包提供的标准库
+type Library struct {
+	Prefix  string                       //import路径的第一段，例："std"，import "std/strings"
+	FS      fs.FS                        //模块的源文件，模块strings对应strings.mk
+	Natives map[string][]*object.Builtin //按模块名：Go实现的成员，在模块的源文件之前绑定并导出
+}
+
+// WithLibrary 允许import加载库中的模块。库不读取文件系统，没有WithImports时也可以使用
+func WithLibrary(lib *Library) Option {
+	return func(in *Interpreter) {
+		m := in.useModules()
+		m.libraries = append(m.libraries, lib)
+	}
+}
+
+func (in *Interpreter) useModules() *modules {
+	if in.modules == nil {
+		in.modules = &modules{cache: map[string]*object.Module{}}
+	}
+	return in.modules
+}
+
+// 一个解释器加载过的模块
+type modules struct {
+	files      bool //是否允许从文件系统加载
+	searchPath []string
+	libraries  []*Library
+	cache      map[string]*object.Module //按绝对路径，库模块按 前缀/模块名
+	loading    []loading                 //正在求值的模块，用于检测循环import
+}
+
+type loading struct {
+	abs  string //绝对路径，库模块见modules.cache
+	file string //查找到的路径，用于错误信息
+}
+
+// 要求值的模块
+type source struct {
+	key     string   //缓存和检测循环import的键，见modules.cache
+	file    string   //查找到的路径，用于错误信息
+	files   []string //按顺序求值的源文件
+	read    func(string) ([]byte, error)
+	library *Library          //库模块所在的库
+	natives []*object.Builtin //Go实现的成员
+}
+
+// 主程序所在的目录，为空表示当前目录（见Interpreter.RunFile）
+func (in *Interpreter) mainDir() string {
+	if in.file == "" {
+		return ""
+	}
+	return filepath.Dir(in.file)
+}
+
+// import语句：加载模块，绑定到别名或路径的最后一段
+func (s *state) evalImport(node *ast.ImportStatement, env *object.Environment) object.Object {
+	if s.modules == nil {
+		return newError("import %q: imports are not enabled", node.Value)
+	}
+	mod := s.importModule(node.Value)
+	if errObj, ok := mod.(*object.Error); ok {
+		if errObj == s.err { //超出资源限制或被调试器停止，原样返回
+			return errObj
+		}
+		return newError("import %q: %s", node.Value, errObj.Message)
+	}
+	env.Set(node.Binding(), mod)
+	return nil
+}
+
+func (s *state) importModule(path string) object.Object {
+	src, err := s.findSource(path)
+	if err != nil {
+		return newError("%s", err)
+	}
+	if mod, ok := s.modules.cache[src.key]; ok {
+		return mod
+	}
+	for i, l := range s.modules.loading {
+		if l.abs == src.key {
+			chain := []string{}
+			for _, l := range s.modules.loading[i:] {
+				chain = append(chain, l.file)
+			}
+			return newError("import cycle not allowed: %s -> %s", strings.Join(chain, " -> "), src.file)
+		}
+	}
+
+	s.modules.loading = append(s.modules.loading, loading{abs: src.key, file: src.file})
+	dir, library := s.dir, s.library
+	s.dir, s.library = "", src.library
+	if src.library == nil {
+		s.dir = filepath.Dir(src.files[0])
+	}
+	defer func() {
+		s.modules.loading = s.modules.loading[:len(s.modules.loading)-1]
+		s.dir, s.library = dir, library
+	}()
+
+	mod := &object.Module{Name: path, Path: src.file, Env: object.NewEnviroment(), Exports: map[string]object.Object{}}
+	for _, b := range s.builtins {
+		mod.Env.Set(b.Name, b)
+	}
+	var exports []string
+	for _, b := range src.natives {
+		mod.Env.Set(b.Name, b)
+		exports = append(exports, b.Name)
+	}
+	for _, f := range src.files {
+		data, err := src.read(f)
+		if err != nil {
+			return newError("%s", err)
+		}
+		program, errObj := s.loadProgram(src.displayName(f), data)
+		if errObj != nil {
+			return errObj
+		}
+		if result := s.eval(program, mod.Env); isError(result) {
+			return result
+		}
+		for _, stmt := range program.Statements {
+			if let, ok := stmt.(*ast.LetStatement); ok && let != nil && let.Exported() {
+				exports = append(exports, let.Name.Value)
+			}
+		}
+	}
+	for _, name := range exports { //导出的是求值结束时绑定的值
+		mod.Exports[name], _ = mod.Env.Get(name)
+	}
+	s.modules.cache[src.key] = mod
+	return mod
+}
+
+// 源文件在错误信息和Program.File中的名字，库模块是 前缀/文件名
+func (src *source) displayName(file string) string {
+	if src.library != nil {
+		return src.library.Prefix + "/" + file
+	}
+	return file
+}
+
+// 按import路径查找模块：库中的模块，或者文件系统中的文件或目录
+func (s *state) findSource(importPath string) (*source, error) {
+	relative := strings.HasPrefix(importPath, "./") || strings.HasPrefix(importPath, "../")
+	if relative && s.library != nil {
+		return nil, fmt.Errorf("relative import %s in library %s", importPath, s.library.Prefix)
+	}
+	for _, lib := range s.modules.libraries {
+		if strings.HasPrefix(importPath, lib.Prefix+"/") {
+			return findInLibrary(lib, strings.TrimPrefix(importPath, lib.Prefix+"/"))
+		}
+	}
+	if !s.modules.files {
+		return nil, fmt.Errorf("cannot find module %s: importing files is not enabled", importPath)
+	}
+
+	file, err := s.findModule(importPath)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	files, err := moduleFiles(file)
+	if err != nil {
+		return nil, err
+	}
+	return &source{key: abs, file: file, files: files, read: os.ReadFile}, nil
+}
+
+// 库中的模块name：源文件name.mk和Go实现的成员，至少有一个
+func findInLibrary(lib *Library, name string) (*source, error) {
+	src := &source{
+		key:     lib.Prefix + "/" + name,
+		file:    lib.Prefix + "/" + name,
+		library: lib,
+		natives: lib.Natives[name],
+		read:    func(file string) ([]byte, error) { return fs.ReadFile(lib.FS, file) },
+	}
+	file := name + sourceExt
+	if lib.FS != nil && path.Base(name) == name && !strings.HasSuffix(name, "_test") {
+		if info, err := fs.Stat(lib.FS, file); err == nil && info.Mode().IsRegular() {
+			src.files = []string{file}
+		}
+	}
+	if src.files == nil && src.natives == nil {
+		return nil, fmt.Errorf("cannot find module %s in library %s", name, lib.Prefix)
+	}
+	return src, nil
+}
+
+// 按import路径查找模块的文件或目录
+func (s *state) findModule(path string) (string, error) {
+	var dirs []string
+	switch {
+	case strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../"):
+		dirs = []string{s.dir}
+	case filepath.IsAbs(path):
+		dirs = []string{""}
+	default:
+		dirs = append([]string{s.root}, s.modules.searchPath...)
+	}
+
+	for _, dir := range dirs {
+		name := filepath.Join(dir, filepath.FromSlash(path))
+		if strings.HasSuffix(name, sourceExt) {
+			if isFile(name) {
+				return name, nil
+			}
+			continue
+		}
+		if isFile(name + sourceExt) {
+			return name + sourceExt, nil
+		}
+		if files, err := moduleFiles(name); err == nil && len(files) > 0 {
+			return name, nil
+		}
+	}
+
+	searched := make([]string, len(dirs))
+	for i, dir := range dirs {
+		if dir == "" {
+			dir = "."
+		}
+		searched[i] = dir
+	}
+	return "", fmt.Errorf("cannot find module %s (searched %s)", path, strings.Join(searched, ", "))
+}
+
+func isFile(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// 模块的源文件：文件本身，或目录中除测试文件以外的.mk文件，按文件名排序
+func moduleFiles(name string) ([]string, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{name}, nil
+	}
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		n := e.Name()
+		if !e.IsDir() && strings.HasSuffix(n, sourceExt) && !strings.HasSuffix(n, "_test"+sourceExt) {
+			files = append(files, filepath.Join(name, n))
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s files in %s", sourceExt, name)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// 解析模块的一个源文件，与Interpreter.Run一样展开宏、优化、解析变量槽位。
+// 模块的宏只在该文件中有效
+func (s *state) loadProgram(file string, src []byte) (*ast.Program, *object.Error) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) != 0 {
+		msgs := make([]string, len(errs))
+		for i, e := range errs {
+			msgs[i] = file + ":" + e.String()
+		}
+		return nil, newError("%s", strings.Join(msgs, "; "))
+	}
+	macroEnv := object.NewEnviroment()
+	DefineMacros(program, macroEnv)
+	expanded, err := ExpandMacros(program, macroEnv)
+	if err != nil {
+		return nil, newError("%s: %s", file, err)
+	}
+	program = expanded.(*ast.Program)
+	program.File = file
+	if s.optimize {
+		optimizer.Optimize(program)
+	}
+	resolver.Resolve(program)
+	return program, nil
+}
+
+// 选择表达式：模块导出的成员
+func evalSelector(node *ast.SelectorExpression, x object.Object) object.Object {
+	name := node.Sel.Value
+	mod, ok := x.(*object.Module)
+	if !ok {
+		return newError("cannot select %s from %s: not a module", name, x.Type())
+	}
+	if val, ok := mod.Exports[name]; ok {
+		return val
+	}
+	if _, ok := mod.Env.Get(name); ok {
+		return newError("%s is not exported by module %s", name, mod.Name)
+	}
+	return newError("module %s has no member %s", mod.Name, name)
+}
@@ -0,0 +1,133 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// 在临时目录中写入源文件，files的键是相对路径
+func writeModules(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"lib/math/sqrt.mk": `
+let loop = fn(n, i) { if (i * i > n) { return i - 1; } loop(n, i + 1) };
+export let sqrt = fn(n) { loop(n, 0) };`,
+		"lib/math/pow.mk": `
+import "./mul"
+export let pow = fn(b, e) { if (e == 0) { return 1; } mul.mul(b, pow(b, e - 1)) };`,
+		"lib/math/sqrt_test.mk": `let = broken`,
+		"lib/math/mul.mk":       `export let mul = fn(a, b) { a * b }; let hidden = 1;`,
+		"vendor/answer.mk":      `export let answer = 42;`,
+		"cycle/a.mk":            `import "./b"`,
+		"cycle/b.mk":            `import "../cycle/a"`,
+	})
+	vendor := filepath.Join(dir, "vendor")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/math"; math.sqrt(50)`, "7"},
+		{`import m "lib/math"; m.pow(2, 10)`, "1024"},
+		{`import "lib/math/mul"; mul.mul(6, 7)`, "42"},
+		{`import "answer"; answer.answer`, "42"},
+		{`import "./lib/math.mk"`, `ERRORimport "./lib/math.mk": cannot find module ./lib/math.mk (searched ` + dir + `)`},
+		{`import "lib/math/mul"; mul.hidden`, "ERRORhidden is not exported by module lib/math/mul"},
+		{`import "lib/math"; math.cbrt`, "ERRORmodule lib/math has no member cbrt"},
+		{`let x = 1; x.y`, "ERRORcannot select y from INTEGER: not a module"},
+		{`import "cycle/a"`, `ERRORimport "cycle/a": import "./b": import "../cycle/a": import cycle not allowed: ` +
+			filepath.Join(dir, "cycle/a.mk") + " -> " + filepath.Join(dir, "cycle/b.mk") + " -> " + filepath.Join(dir, "cycle/a.mk")},
+	}
+
+	for _, tt := range tests {
+		in := New(WithImports(vendor))
+		result, err := in.RunFile(filepath.Join(dir, "main.mk"), tt.input)
+		if err != nil {
+			t.Errorf("%s: %s", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s: got %s, want %s", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+
+	//同一个解释器中模块只求值一次
+	in := New(WithImports())
+	if _, err := in.RunFile(filepath.Join(dir, "main.mk"), `import "lib/math"; import m "lib/math";`); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := in.Env().Get("math")
+	b, _ := in.Env().Get("m")
+	if _, ok := a.(*object.Module); !ok || a != b {
+		t.Errorf("expected the same module, got %v and %v", a, b)
+	}
+
+	result, _ := New().Run(`import "lib/math"`)
+	if result.Inspect() != `ERRORimport "lib/math": imports are not enabled` {
+		t.Errorf("imports without WithImports: got %s", result.Inspect())
+	}
+}
+
+func TestLibrary(t *testing.T) {
+	double := &object.Builtin{Name: "double", Fn: func(c object.Caller, args ...object.Object) object.Object {
+		return &object.Integer{Value: 2 * args[0].(*object.Integer).Value}
+	}}
+	answer := &object.Builtin{Name: "answer", Fn: func(c object.Caller, args ...object.Object) object.Object {
+		return &object.Integer{Value: 42}
+	}}
+	lib := &Library{
+		Prefix: "lib",
+		FS: fstest.MapFS{
+			"num.mk":      {Data: []byte(`export let quad = fn(x) { double(double(x)) }; export let inc = fn(x) { x + answer() - 41 };`)},
+			"a.mk":        {Data: []byte(`import "lib/b"`)},
+			"b.mk":        {Data: []byte(`import "lib/a"`)},
+			"rel.mk":      {Data: []byte(`import "./num"`)},
+			"sub/x.mk":    {Data: []byte(`export let x = 1;`)},
+			"num_test.mk": {Data: []byte(`let test_x = fn() {};`)},
+		},
+		Natives: map[string][]*object.Builtin{"num": {double}, "go": {double}},
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/num"; num.quad(3) + num.double(1)`, "14"},
+		{`import "lib/num"; num.inc(1)`, "2"},
+		{`import "lib/go"; go.double(4) + answer()`, "50"},
+		{`import "lib/num"; num.answer`, "ERRORanswer is not exported by module lib/num"},
+		{`import "lib/a"`, `ERRORimport "lib/a": import "lib/b": import "lib/a": import cycle not allowed: lib/a -> lib/b -> lib/a`},
+		{`import "lib/rel"`, `ERRORimport "lib/rel": import "./num": relative import ./num in library lib`},
+		{`import "lib/sub/x"`, `ERRORimport "lib/sub/x": cannot find module sub/x in library lib`},
+		{`import "lib/num_test"`, `ERRORimport "lib/num_test": cannot find module num_test in library lib`},
+		{`import "other"`, `ERRORimport "other": cannot find module other: importing files is not enabled`},
+	}
+
+	for _, tt := range tests {
+		result, err := New(WithLibrary(lib), WithBuiltins(answer)).Run(tt.input)
+		if err != nil {
+			t.Errorf("%s: %s", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s: got %s, want %s", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+}
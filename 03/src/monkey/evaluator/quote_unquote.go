@@ -0,0 +1,64 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/token"
+)
+
+// quote(expr) 返回未求值的ast，其中的unquote(x)在此时求值并替换为结果对应的ast
+func (s *state) quote(node ast.Node, env *object.Environment) object.Object {
+	//Modify原地改写，先拷贝，否则宏体或函数体中的unquote被第一次调用的结果替换，之后的调用都得到同样的结果
+	node = s.evalUnquoteCalls(ast.Copy(node), env)
+	return &object.Quote{Node: node}
+}
+
+func (s *state) evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
+	return ast.Modify(quoted, func(node ast.Node) ast.Node {
+		if !isUnquoteCall(node) {
+			return node
+		}
+
+		call, ok := node.(*ast.CallExpression)
+		if !ok || len(call.Arguments) != 1 {
+			return node
+		}
+
+		unquoted := s.eval(call.Arguments[0], env)
+		return convertObjectToASTNode(unquoted, call.Token.Pos, node)
+	})
+}
+
+func isUnquoteCall(node ast.Node) bool {
+	callExpression, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+	return callExpression.Function.TokenLiteral() == "unquote"
+}
+
+// 把unquote求值结果转回ast节点，不能表示为ast的对象（例：函数、错误）保留原来的unquote调用
+func convertObjectToASTNode(obj object.Object, pos token.Position, orig ast.Node) ast.Node {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: fmt.Sprintf("%d", obj.Value), Pos: pos}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+	case *object.Boolean:
+		var t token.Token
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true", Pos: pos}
+		} else {
+			t = token.Token{Type: token.FALSE, Literal: "false", Pos: pos}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: lexer.Quote(obj.Value), Pos: pos}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}
+	case *object.Quote:
+		return obj.Node
+	default:
+		return orig
+	}
+}
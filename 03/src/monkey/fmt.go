@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"monkey/format"
+	"os"
+)
+
+// monkey fmt [-w] files... 格式化源码，不给文件时从标准输入读取
+func runFmt(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "把结果写回源文件，而不是输出到标准输出")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey fmt [-w] [files...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "cannot use -w with standard input")
+			return 2
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return formatOne("<stdin>", src, false)
+	}
+
+	status := 0
+	for _, path := range fs.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		if rc := formatOne(path, src, *write); rc != 0 {
+			status = rc
+		}
+	}
+	return status
+}
+
+func formatOne(path string, src []byte, write bool) int {
+	out, err := format.Source(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		return 1
+	}
+	if !write {
+		os.Stdout.Write(out)
+		return 0
+	}
+	if bytes.Equal(src, out) {
+		return 0
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
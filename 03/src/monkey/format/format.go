@@ -0,0 +1,365 @@
+package format
+
+// 规范化的源码格式化：统一缩进和空格，按优先级只保留必要的括号，
+// 过长的调用参数列表每个参数一行，保留注释和语句间的单个空行。对自身的输出再次格式化结果不变
+
+import (
+	"bytes"
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	indentUnit = "    " //每级缩进4个空格
+	maxWidth   = 80     //调用表达式超过该宽度时参数换行
+)
+
+// Error 源码有语法错误，无法格式化
+type Error struct {
+	Errors []string
+}
+
+func (e *Error) Error() string {
+	return "syntax errors:\n\t" + strings.Join(e.Errors, "\n\t")
+}
+
+// Source 解析并格式化源码，保留注释
+func Source(src []byte) ([]byte, error) {
+	input := string(src)
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &Error{Errors: p.Errors()}
+	}
+
+	var comments []token.Token
+	for _, tok := range lexer.TokenizeWithTrivia(input) {
+		if tok.Type == token.COMMENT {
+			comments = append(comments, tok)
+		}
+	}
+
+	pr := &printer{comments: comments}
+	pr.statements(program.Statements, 0, len(input)+1)
+	return pr.buf.Bytes(), nil
+}
+
+// Node 格式化单个节点（不含注释），语句不带结尾换行
+func Node(node ast.Node) string {
+	pr := &printer{}
+	switch n := node.(type) {
+	case *ast.Program:
+		pr.statements(n.Statements, 0, -1)
+		return strings.TrimSuffix(pr.buf.String(), "\n")
+	case *ast.BlockStatement:
+		pr.block(n, 0)
+	case ast.Statement:
+		pr.statementText(n, 0)
+	case ast.Expression:
+		pr.expr(n, 0)
+	}
+	return pr.buf.String()
+}
+
+type printer struct {
+	buf      bytes.Buffer
+	col      int           //当前行已输出的宽度，fork出的printer从父printer的列开始
+	comments []token.Token //源码中全部注释，按位置排序
+	next     int           //下一个尚未输出的注释
+	lastLine int           //上一个输出内容在源码中的行号，用于保留空行
+	newBlock bool          //刚进入块，块内第一项前不留空行
+}
+
+func (p *printer) write(s string) {
+	p.buf.WriteString(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		p.col = utf8.RuneCountInString(s[i+1:])
+	} else {
+		p.col += utf8.RuneCountInString(s)
+	}
+}
+
+func (p *printer) indent(level int) {
+	p.write(strings.Repeat(indentUnit, level))
+}
+
+// 试排版用的子printer，结果满意时用absorb并入
+func (p *printer) fork() *printer {
+	return &printer{col: p.col, comments: p.comments, next: p.next, lastLine: p.lastLine, newBlock: p.newBlock}
+}
+
+func (p *printer) absorb(q *printer) {
+	p.write(q.buf.String())
+	p.next = q.next
+	p.lastLine = q.lastLine
+	p.newBlock = q.newBlock
+}
+
+// 输出源码位置在limit之前的注释，每个注释单独一行
+func (p *printer) flushComments(limit int, level int) {
+	for p.next < len(p.comments) && p.comments[p.next].Pos.Offset < limit {
+		c := p.comments[p.next]
+		p.blankLine(c.Pos.Line)
+		p.indent(level)
+		p.write(c.Literal + "\n")
+		p.lastLine = c.Pos.Line
+		p.next++
+	}
+}
+
+// 源码中与上一项之间有空行时保留一个空行
+func (p *printer) blankLine(line int) {
+	if p.lastLine > 0 && line > p.lastLine+1 && !p.newBlock {
+		p.write("\n")
+	}
+	p.newBlock = false
+}
+
+// 输出语句序列，end是所在块结尾的源码偏移，块内剩余注释在此之前输出
+func (p *printer) statements(stmts []ast.Statement, level int, end int) {
+	for _, s := range stmts {
+		pos := s.Pos()
+		p.flushComments(pos.Offset, level)
+		if pos.Line > 0 {
+			p.blankLine(pos.Line)
+		}
+		p.indent(level)
+		p.statementText(s, level)
+
+		stmtEnd := s.End()
+		//语句内部未能输出的注释和同一行的行尾注释，放在语句末尾
+		var trailing []string
+		for p.next < len(p.comments) {
+			c := p.comments[p.next]
+			if c.Pos.Offset >= end || (c.Pos.Offset >= stmtEnd.Offset && c.Pos.Line != stmtEnd.Line) {
+				break
+			}
+			trailing = append(trailing, c.Literal)
+			p.next++
+		}
+		if len(trailing) > 0 {
+			p.write(" " + strings.Join(trailing, " "))
+		}
+		p.write("\n")
+		p.newBlock = false
+		if stmtEnd.Line > 0 {
+			p.lastLine = stmtEnd.Line
+		}
+	}
+	p.flushComments(end, level)
+}
+
+// 输出语句本身，不含缩进和换行
+func (p *printer) statementText(s ast.Statement, level int) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		if s.Exported() {
+			p.write("export ")
+		}
+		p.write("let " + s.Name.Value)
+		if s.Type != nil {
+			p.write(": " + s.Type.String())
+		}
+		p.write(" = ")
+		p.expr(s.Value, level)
+		p.write(";")
+	case *ast.ImportStatement:
+		p.write("import ")
+		if s.Name != nil {
+			p.write(s.Name.Value + " ")
+		}
+		p.write(s.Path.Literal + ";")
+	case *ast.ReturnStatement:
+		p.write("return")
+		if s.ReturnValue != nil {
+			p.write(" ")
+			p.expr(s.ReturnValue, level)
+		}
+		p.write(";")
+	case *ast.ExpressionStatement:
+		p.expr(s.Expression, level)
+		if _, ok := s.Expression.(*ast.IfExpression); !ok { //if以}结尾，不加分号
+			p.write(";")
+		}
+	default:
+		panic(fmt.Sprintf("format: unexpected statement type %T", s))
+	}
+}
+
+// 输出 { 语句... }，空块输出 {}
+func (p *printer) block(b *ast.BlockStatement, level int) {
+	end := b.Rbrace.Offset
+	if b.Rbrace.Line == 0 { //没有位置信息（例：宏生成的节点），不在块内输出注释
+		end = -1
+	}
+	hasComments := p.next < len(p.comments) && p.comments[p.next].Pos.Offset < end
+	if len(b.Statements) == 0 && !hasComments {
+		p.write("{}")
+		return
+	}
+
+	p.write("{\n")
+	p.newBlock = true
+	p.statements(b.Statements, level+1, end)
+	p.indent(level)
+	p.write("}")
+	if b.Rbrace.Line > 0 {
+		p.lastLine = b.Rbrace.Line
+	}
+}
+
+// 不是运算表达式的节点（字面量、标识符、if、fn等）优先级最高，不需要括号
+const atomPrecedence = parser.SELECTOR + 1
+
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return operatorPrecedence(e.Operator)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	case *ast.IndexExpression:
+		return parser.INDEX
+	case *ast.SelectorExpression:
+		return parser.SELECTOR
+	}
+	return atomPrecedence
+}
+
+// 运算符的优先级，由词法分析器识别运算符后查parser的优先级表
+func operatorPrecedence(op string) int {
+	return parser.Precedence(lexer.New(op).NextToken().Type)
+}
+
+// 按需要加括号输出子表达式，right表示左结合运算的右操作数
+func (p *printer) operand(e ast.Expression, parent int, right bool, level int) {
+	prec := precedence(e)
+	if prec < parent || (right && prec == parent) {
+		p.write("(")
+		p.expr(e, level)
+		p.write(")")
+		return
+	}
+	p.expr(e, level)
+}
+
+func (p *printer) expr(e ast.Expression, level int) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntegerLiteral:
+		if e.Token.Literal != "" {
+			p.write(e.Token.Literal)
+		} else {
+			p.write(fmt.Sprint(e.Value))
+		}
+	case *ast.Boolean:
+		p.write(fmt.Sprint(e.Value))
+	case *ast.StringLiteral:
+		p.write(e.Token.Literal) //保留源码中的转义写法
+	case *ast.ArrayLiteral:
+		p.write("[")
+		for i, el := range e.Elements {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expr(el, level)
+		}
+		p.write("]")
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.operand(e.Right, parser.PREFIX, false, level)
+	case *ast.InfixExpression:
+		prec := operatorPrecedence(e.Operator)
+		p.operand(e.Left, prec, false, level)
+		p.write(" " + e.Operator + " ")
+		p.operand(e.Right, prec, true, level)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expr(e.Condition, level)
+		p.write(") ")
+		p.block(e.Consequence, level)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative, level)
+		}
+	case *ast.FunctionLiteral:
+		p.write("fn")
+		p.params(e.Parameters)
+		if e.ReturnType != nil {
+			p.write(" -> " + e.ReturnType.String())
+		}
+		p.write(" ")
+		p.block(e.Body, level)
+	case *ast.MacroLiteral:
+		p.write("macro")
+		p.params(e.Parameters)
+		p.write(" ")
+		p.block(e.Body, level)
+	case *ast.CallExpression:
+		p.operand(e.Function, parser.CALL, false, level)
+		p.callArguments(e.Arguments, level)
+	case *ast.IndexExpression:
+		p.operand(e.Left, parser.INDEX, false, level)
+		p.write("[")
+		p.expr(e.Index, level)
+		p.write("]")
+	case *ast.SelectorExpression:
+		p.operand(e.X, parser.SELECTOR, false, level)
+		p.write("." + e.Sel.Value)
+	default:
+		panic(fmt.Sprintf("format: unexpected expression type %T", e))
+	}
+}
+
+func (p *printer) params(params []*ast.Identifier) {
+	names := make([]string, 0, len(params))
+	for _, param := range params {
+		if param.Type != nil {
+			names = append(names, param.Value+": "+param.Type.String())
+		} else {
+			names = append(names, param.Value)
+		}
+	}
+	p.write("(" + strings.Join(names, ", ") + ")")
+}
+
+// 调用参数先尝试排在一行，第一行超过maxWidth时每个参数单独一行
+func (p *printer) callArguments(args []ast.Expression, level int) {
+	flat := p.fork()
+	flat.write("(")
+	for i, a := range args {
+		if i > 0 {
+			flat.write(", ")
+		}
+		flat.expr(a, level)
+	}
+	flat.write(")")
+
+	firstLine := flat.buf.String()
+	if i := strings.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	if len(args) == 0 || p.col+utf8.RuneCountInString(firstLine) <= maxWidth {
+		p.absorb(flat)
+		return
+	}
+
+	p.write("(\n")
+	for i, a := range args {
+		p.indent(level + 1)
+		p.expr(a, level+1)
+		if i < len(args)-1 {
+			p.write(",")
+		}
+		p.write("\n")
+	}
+	p.indent(level)
+	p.write(")")
+}
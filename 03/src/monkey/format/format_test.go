@@ -0,0 +1,177 @@
+package format
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func testFormat(t *testing.T, input string) string {
+	t.Helper()
+	out, err := Source([]byte(input))
+	if err != nil {
+		t.Fatalf("Source(%q) error: %s", input, err)
+	}
+	return string(out)
+}
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5", "let x = 5;\n"},
+		{"return   x", "return x;\n"},
+		{"add(1,2)", "add(1, 2);\n"},
+		{"-a*b; !(-a)", "-a * b;\n!-a;\n"},
+		{"if(x<y){x}else{y}", "if (x < y) {\n    x;\n} else {\n    y;\n}\n"},
+		{"let f = fn(){}", "let f = fn() {};\n"},
+		{"let x:int=5", "let x: int = 5;\n"},
+		{"let f = fn(a:int,g:fn(int,bool)->int)->int{g(a,true)}", "let f = fn(a: int, g: fn(int, bool) -> int) -> int {\n    g(a, true);\n};\n"},
+		{"let m = macro(a,b){quote(unquote(a)+unquote(b))}", "let m = macro(a, b) {\n    quote(unquote(a) + unquote(b));\n};\n"},
+		{"import m \"lib/math\"\nexport let r=m.sqrt( 4 )", "import m \"lib/math\";\nexport let r = m.sqrt(4);\n"},
+		{`let xs=[1,"a\tb",[ ]];xs[0 ]`, "let xs = [1, \"a\\tb\", []];\nxs[0];\n"},
+		{
+			"let f = fn(x) { if (x) { return fn(y) { y } } }",
+			"let f = fn(x) {\n    if (x) {\n        return fn(y) {\n            y;\n        };\n    }\n};\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := testFormat(t, tt.input); got != tt.expected {
+			t.Errorf("format(%q) wrong.\nwant=%q\ngot =%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// 只保留按优先级必需的括号
+func TestMinimalParentheses(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(1 + 2) + 3", "1 + 2 + 3;\n"},
+		{"1 + (2 + 3)", "1 + (2 + 3);\n"},
+		{"1 - (2 - 3)", "1 - (2 - 3);\n"},
+		{"(1 * 2) + (3 / 4)", "1 * 2 + 3 / 4;\n"},
+		{"(1 + 2) * 3", "(1 + 2) * 3;\n"},
+		{"1 + 2 * (3 + 4) / 5 - 6", "1 + 2 * (3 + 4) / 5 - 6;\n"},
+		{"-(5 + 5)", "-(5 + 5);\n"},
+		{"(-a) * b", "-a * b;\n"},
+		{"((5 > 4) == (3 < 4))", "5 > 4 == 3 < 4;\n"},
+		{"a + (add(b * c)) + d", "a + add(b * c) + d;\n"},
+		{"(fn(x) { x })(5)", "fn(x) {\n    x;\n}(5);\n"},
+		{"(a + b)(5)", "(a + b)(5);\n"},
+		{"(xs[0])[1] + (-xs)[0]", "xs[0][1] + (-xs)[0];\n"},
+	}
+
+	for _, tt := range tests {
+		got := testFormat(t, tt.input)
+		if got != tt.expected {
+			t.Errorf("format(%q) wrong.\nwant=%q\ngot =%q", tt.input, tt.expected, got)
+		}
+		//去掉括号后语义不变：两者的ast完全相同
+		if want, have := parseString(t, tt.input), parseString(t, got); want != have {
+			t.Errorf("formatting changed the meaning of %q: %s != %s", tt.input, want, have)
+		}
+	}
+}
+
+func parseString(t *testing.T, input string) string {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program.String()
+}
+
+func TestComments(t *testing.T) {
+	input := `// 头部注释
+let add = fn(x,y) { return x + y };   // 加法
+
+
+let applyFunc = fn(a,b,func) {
+
+  // 调用
+  func(a ,b)
+
+  // 结尾
+};
+// 末尾
+`
+	expected := `// 头部注释
+let add = fn(x, y) {
+    return x + y;
+}; // 加法
+
+let applyFunc = fn(a, b, func) {
+    // 调用
+    func(a, b);
+
+    // 结尾
+};
+// 末尾
+`
+	if got := testFormat(t, input); got != expected {
+		t.Errorf("comments not preserved.\nwant=%s\ngot =%s", expected, got)
+	}
+}
+
+func TestLongCallArguments(t *testing.T) {
+	input := "let result = someFunction(argumentNumberOne, argumentNumberTwo, argumentNumberThree + 1, other(a, b));"
+	expected := `let result = someFunction(
+    argumentNumberOne,
+    argumentNumberTwo,
+    argumentNumberThree + 1,
+    other(a, b)
+);
+`
+	if got := testFormat(t, input); got != expected {
+		t.Errorf("long call not broken.\nwant=%s\ngot =%s", expected, got)
+	}
+
+	short := "let result = f(a, b);\n"
+	if got := testFormat(t, short); got != short {
+		t.Errorf("short call should stay on one line. got=%q", got)
+	}
+}
+
+// 格式化自身的输出结果不变
+func TestIdempotent(t *testing.T) {
+	inputs := []string{
+		"let add = fn(x,y) { return x + y }; add(1 + 2 * (3 + 4) / 5 - 6, add(6, 7 * 8));",
+		"let newAddr = fn(x) {fn(y) { x+y }}; // 闭包\nlet addTwo = newAddr(2);\n\n\naddTwo(2);",
+		"if ((1 < 2) == true) { 10 } else { if (a) { -(5 + 5) } }",
+		"applyFunc(veryLongArgumentNumberOne, veryLongArgumentNumberTwo, fn(x, y) { x - (y - 1) }, veryLongArgumentNumberThree);",
+		"let f = fn() {\n  // 只有注释\n};",
+	}
+	for _, input := range inputs {
+		once := testFormat(t, input)
+		twice := testFormat(t, once)
+		if once != twice {
+			t.Errorf("format is not idempotent for %q.\nonce =%q\ntwice=%q", input, once, twice)
+		}
+		if parseString(t, input) != parseString(t, once) {
+			t.Errorf("formatting changed the meaning of %q", input)
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	_, err := Source([]byte("let = 5;"))
+	if err == nil || !strings.Contains(err.Error(), "syntax errors") {
+		t.Fatalf("expected syntax error. got=%v", err)
+	}
+}
+
+func TestNode(t *testing.T) {
+	p := parser.New(lexer.New("let f = fn(a, b) { (a + b) * 2 };"))
+	program := p.ParseProgram()
+	expected := "let f = fn(a, b) {\n    (a + b) * 2;\n};"
+	if got := Node(program.Statements[0]); got != expected {
+		t.Errorf("Node wrong.\nwant=%q\ngot =%q", expected, got)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"monkey/evaluator"
+	"monkey/host"
+	"strings"
+)
+
+// 可以重复的参数，例：-env HOME -env USER
+type patternList []string
+
+func (p *patternList) String() string { return strings.Join(*p, ",") }
+
+func (p *patternList) Set(pattern string) error {
+	*p = append(*p, pattern)
+	return nil
+}
+
+// 授予脚本访问宿主环境的能力的参数，默认什么都不授予
+type grantFlags struct {
+	root  *string
+	write *bool
+	allow *patternList
+	env   *patternList
+}
+
+func addGrantFlags(fs *flag.FlagSet) *grantFlags {
+	g := &grantFlags{
+		root:  fs.String("root", "", "允许脚本用readFile、listDir访问该目录之下的文件"),
+		write: fs.Bool("write", false, "和-root一起使用，还允许用writeFile写入文件"),
+		allow: &patternList{},
+		env:   &patternList{},
+	}
+	fs.Var(g.allow, "allow", "和-root一起使用，只允许访问匹配该模式的路径（相对于-root），可以重复")
+	fs.Var(g.env, "env", "允许脚本用getenv读取名字匹配该模式的环境变量，可以重复")
+	return g
+}
+
+func (g *grantFlags) options() []evaluator.Option {
+	var opts []evaluator.Option
+	if *g.root != "" {
+		opts = append(opts, host.WithFiles(host.Files{Root: *g.root, Allow: *g.allow, Write: *g.write}))
+	}
+	if len(*g.env) > 0 {
+		opts = append(opts, host.WithEnv(*g.env...))
+	}
+	return opts
+}
@@ -0,0 +1,245 @@
+package host
+
+// 访问宿主环境的内置函数：readFile、writeFile、listDir、getenv。
+// 脚本可能不可信，这些函数默认不存在，只有宿主用WithFiles、WithEnv授权后才注册到解释器中
+// （见evaluator.WithBuiltins），授权之外的访问返回*object.Error，不会终止宿主程序。
+//
+// 文件只能在授权的根目录之下访问：脚本中的路径是相对于根目录的路径（用/分隔），
+// 绝对路径、用..跳出根目录的路径、以及实际位置（解析符号链接后）在根目录之外的路径都被拒绝。
+// 检查和访问之间文件系统被其他进程修改（例：替换为符号链接）的情况不在防护范围内。
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Files 文件系统的授权
+type Files struct {
+	Root  string   //脚本只能访问该目录之下的文件，脚本中的路径相对于它
+	Allow []string //允许访问的路径，path.Match模式（例："config/*.json"），匹配的目录下的全部文件都允许；空表示Root下的全部
+	Write bool     //是否注册writeFile
+}
+
+// WithFiles 授予访问文件系统的能力：注册readFile、listDir，f.Write为true时还注册writeFile
+func WithFiles(f Files) evaluator.Option {
+	j := newJail(f)
+	builtins := []*object.Builtin{
+		{Name: "readFile", Fn: j.readFile},
+		{Name: "listDir", Fn: j.listDir},
+	}
+	if f.Write {
+		builtins = append(builtins, &object.Builtin{Name: "writeFile", Fn: j.writeFile})
+	}
+	return evaluator.WithBuiltins(builtins...)
+}
+
+// WithEnv 授予读取环境变量的能力：注册getenv，只能读取名字匹配allow中某个path.Match模式的变量，
+// 例："HOME"、"MYAPP_*"；"*"表示全部
+func WithEnv(allow ...string) evaluator.Option {
+	return evaluator.WithBuiltins(&object.Builtin{Name: "getenv", Fn: func(c object.Caller, args ...object.Object) object.Object {
+		name, errObj := stringArg("getenv", args, 0, 1)
+		if errObj != nil {
+			return errObj
+		}
+		if !matchAny(allow, name) {
+			return newError("getenv %s: permission denied", lexer.Quote(name))
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return evaluator.NULL
+		}
+		return &object.String{Value: value}
+	}})
+}
+
+// Names 本包可能注册的内置函数的名字，供analysis检查时作为预先定义的名字
+func Names() []string {
+	return []string{"getenv", "listDir", "readFile", "writeFile"}
+}
+
+// 限制在根目录之下的文件访问
+type jail struct {
+	root  string //根目录的绝对路径
+	real  string //根目录解析符号链接后的路径
+	allow []string
+}
+
+func newJail(f Files) *jail {
+	root, err := filepath.Abs(f.Root)
+	if err != nil {
+		root = filepath.Clean(f.Root)
+	}
+	real, err := filepath.EvalSymlinks(root)
+	if err != nil { //根目录不存在时每次访问都会失败
+		real = root
+	}
+	return &jail{root: root, real: real, allow: f.Allow}
+}
+
+func (j *jail) readFile(c object.Caller, args ...object.Object) object.Object {
+	name, errObj := stringArg("readFile", args, 0, 1)
+	if errObj != nil {
+		return errObj
+	}
+	file, errObj := j.resolve("readFile", name)
+	if errObj != nil {
+		return errObj
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return osError("readFile", name, err)
+	}
+	return &object.String{Value: string(data)}
+}
+
+func (j *jail) writeFile(c object.Caller, args ...object.Object) object.Object {
+	name, errObj := stringArg("writeFile", args, 0, 2)
+	if errObj != nil {
+		return errObj
+	}
+	content, errObj := stringArg("writeFile", args, 1, 2)
+	if errObj != nil {
+		return errObj
+	}
+	file, errObj := j.resolve("writeFile", name)
+	if errObj != nil {
+		return errObj
+	}
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		return osError("writeFile", name, err)
+	}
+	return evaluator.NULL
+}
+
+// 目录中的名字，按名字排序，子目录的名字以/结尾（符号链接不解析）
+func (j *jail) listDir(c object.Caller, args ...object.Object) object.Object {
+	name, errObj := stringArg("listDir", args, 0, 1)
+	if errObj != nil {
+		return errObj
+	}
+	dir, errObj := j.resolve("listDir", name)
+	if errObj != nil {
+		return errObj
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return osError("listDir", name, err)
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+		if e.IsDir() {
+			names[i] += "/"
+		}
+	}
+	sort.Strings(names)
+	elements := make([]object.Object, len(names))
+	for i, n := range names {
+		elements[i] = &object.String{Value: n}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// 把脚本中的路径转换为根目录之下的实际路径，不允许访问时返回错误
+func (j *jail) resolve(fn, name string) (string, *object.Error) {
+	quoted := lexer.Quote(name)
+	slashed := filepath.ToSlash(name)
+	switch {
+	case name == "":
+		return "", newError("%s: empty path", fn)
+	case strings.ContainsRune(name, 0):
+		return "", newError("%s %s: invalid path", fn, quoted)
+	case path.IsAbs(slashed) || filepath.IsAbs(name) || filepath.VolumeName(name) != "":
+		return "", newError("%s %s: absolute paths are not allowed", fn, quoted)
+	}
+	clean := path.Clean(slashed)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", newError("%s %s: path escapes the root directory", fn, quoted)
+	}
+	if !j.allowed(clean) {
+		return "", newError("%s %s: permission denied", fn, quoted)
+	}
+
+	file := filepath.Join(j.root, filepath.FromSlash(clean))
+	real, err := filepath.EvalSymlinks(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		//文件不存在（例：writeFile新建文件）时检查所在的目录；指向不存在的文件的符号链接也拒绝，
+		//否则写入时会在链接指向的位置创建文件
+		if _, lerr := os.Lstat(file); lerr == nil {
+			return "", newError("%s %s: path escapes the root directory", fn, quoted)
+		}
+		dir, derr := filepath.EvalSymlinks(filepath.Dir(file))
+		if derr != nil {
+			return "", osError(fn, name, derr)
+		}
+		real = filepath.Join(dir, filepath.Base(file))
+	} else if err != nil {
+		return "", osError(fn, name, err)
+	}
+	if !within(j.real, real) {
+		return "", newError("%s %s: path escapes the root directory", fn, quoted)
+	}
+	return file, nil
+}
+
+// clean（相对于根目录）或它所在的某个目录是否匹配允许的模式
+func (j *jail) allowed(clean string) bool {
+	if len(j.allow) == 0 {
+		return true
+	}
+	for p := clean; p != "." && p != "/"; p = path.Dir(p) {
+		if matchAny(j.allow, p) {
+			return true
+		}
+	}
+	return matchAny(j.allow, ".")
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// p是否是root或在root之下
+func within(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// 操作系统的错误，只给出脚本中的路径，不暴露根目录在宿主上的位置
+func osError(fn, name string, err error) *object.Error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return newError("%s %s: %s", fn, lexer.Quote(name), err)
+}
+
+// 第i个参数，必须是字符串，参数共n个
+func stringArg(fn string, args []object.Object, i, n int) (string, *object.Error) {
+	if len(args) != n {
+		return "", newError("wrong number of arguments to %s: want=%d, got=%d", fn, n, len(args))
+	}
+	s, ok := args[i].(*object.String)
+	if !ok {
+		return "", newError("argument %d to %s must be STRING, got %s", i+1, fn, args[i].Type())
+	}
+	return s.Value, nil
+}
@@ -0,0 +1,132 @@
+package host
+
+import (
+	"monkey/evaluator"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 根目录root中的文件，root旁边有一个不允许访问的secret.txt
+func setupRoot(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	files := map[string]string{
+		"root/a.txt":             "hello",
+		"root/config/app.json":   `{"debug": true}`,
+		"root/config/local.json": "{}",
+		"root/data/x.txt":        "x",
+		"secret.txt":             "secret",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//指向根目录之外的符号链接
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	if err := os.Symlink(dir, filepath.Join(root, "up")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "created.txt"), filepath.Join(root, "dangling.txt")); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestFiles(t *testing.T) {
+	root := setupRoot(t)
+
+	tests := []struct {
+		files    Files
+		input    string
+		expected string
+	}{
+		{Files{Root: root}, `readFile("a.txt")`, "hello"},
+		{Files{Root: root}, `readFile("./config/../a.txt")`, "hello"},
+		{Files{Root: root}, `listDir(".")`, "[a.txt, config/, dangling.txt, data/, link.txt, up]"},
+		{Files{Root: root}, `listDir("config")`, "[app.json, local.json]"},
+		{Files{Root: root}, `readFile("missing.txt")`, `ERRORreadFile "missing.txt": no such file or directory`},
+		{Files{Root: root}, `readFile("")`, "ERRORreadFile: empty path"},
+		{Files{Root: root}, `readFile(1)`, "ERRORargument 1 to readFile must be STRING, got INTEGER"},
+		{Files{Root: root}, `readFile()`, "ERRORwrong number of arguments to readFile: want=1, got=0"},
+		{Files{Root: root}, `readFile("../secret.txt")`, `ERRORreadFile "../secret.txt": path escapes the root directory`},
+		{Files{Root: root}, `readFile("data/../../secret.txt")`, `ERRORreadFile "data/../../secret.txt": path escapes the root directory`},
+		{Files{Root: root}, `listDir("..")`, `ERRORlistDir "..": path escapes the root directory`},
+		{Files{Root: root}, `readFile("/etc/passwd")`, `ERRORreadFile "/etc/passwd": absolute paths are not allowed`},
+		{Files{Root: root}, `readFile("link.txt")`, `ERRORreadFile "link.txt": path escapes the root directory`},
+		{Files{Root: root}, `readFile("up/secret.txt")`, `ERRORreadFile "up/secret.txt": path escapes the root directory`},
+		{Files{Root: root}, `writeFile("a.txt", "x")`, "ERRORidentifier not found: writeFile"},
+		{Files{Root: root, Allow: []string{"config/*.json"}}, `readFile("config/app.json")`, `{"debug": true}`},
+		{Files{Root: root, Allow: []string{"config"}}, `listDir("config")`, "[app.json, local.json]"},
+		{Files{Root: root, Allow: []string{"config"}}, `readFile("config/local.json")`, "{}"},
+		{Files{Root: root, Allow: []string{"config/*.json"}}, `readFile("a.txt")`, `ERRORreadFile "a.txt": permission denied`},
+		{Files{Root: root, Allow: []string{"config/*.json"}}, `listDir(".")`, `ERRORlistDir ".": permission denied`},
+		{Files{Root: root, Write: true}, `writeFile("out.txt", "1" + "2"); readFile("out.txt")`, "12"},
+		{Files{Root: root, Write: true}, `writeFile("data/x.txt", "y"); readFile("data/x.txt")`, "y"},
+		{Files{Root: root, Write: true}, `writeFile("../created.txt", "x")`, `ERRORwriteFile "../created.txt": path escapes the root directory`},
+		{Files{Root: root, Write: true}, `writeFile("dangling.txt", "x")`, `ERRORwriteFile "dangling.txt": path escapes the root directory`},
+		{Files{Root: root, Write: true}, `writeFile("up/created.txt", "x")`, `ERRORwriteFile "up/created.txt": path escapes the root directory`},
+		{Files{Root: root, Write: true}, `writeFile("none/a.txt", "x")`, `ERRORwriteFile "none/a.txt": no such file or directory`},
+		{Files{Root: root, Write: true}, `writeFile("a.txt")`, "ERRORwrong number of arguments to writeFile: want=2, got=1"},
+	}
+
+	for _, tt := range tests {
+		result, err := evaluator.New(WithFiles(tt.files)).Run(tt.input)
+		if err != nil {
+			t.Errorf("%s: %s", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s: got %s, want %s", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "created.txt")); !os.IsNotExist(err) {
+		t.Errorf("file created outside the root directory: %v", err)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("MONKEY_A", "a")
+	t.Setenv("MONKEY_B", "b")
+	t.Setenv("OTHER", "other")
+
+	tests := []struct {
+		allow    []string
+		input    string
+		expected string
+	}{
+		{[]string{"MONKEY_A"}, `getenv("MONKEY_A")`, "a"},
+		{[]string{"MONKEY_*"}, `getenv("MONKEY_A") + getenv("MONKEY_B")`, "ab"},
+		{[]string{"MONKEY_*"}, `getenv("MONKEY_UNSET")`, "null"},
+		{[]string{"MONKEY_*"}, `getenv("OTHER")`, `ERRORgetenv "OTHER": permission denied`},
+		{[]string{"*"}, `getenv("OTHER")`, "other"},
+		{nil, `getenv("MONKEY_A")`, `ERRORgetenv "MONKEY_A": permission denied`},
+		{[]string{"*"}, `getenv(1)`, "ERRORargument 1 to getenv must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		result, err := evaluator.New(WithEnv(tt.allow...)).Run(tt.input)
+		if err != nil {
+			t.Errorf("%s: %s", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s: got %s, want %s", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+
+	//没有授权时不注册
+	result, _ := evaluator.New().Run(`getenv("MONKEY_A")`)
+	if result.Inspect() != "ERRORidentifier not found: getenv" {
+		t.Errorf("getenv without WithEnv: got %s", result.Inspect())
+	}
+}
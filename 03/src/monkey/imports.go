@@ -0,0 +1,22 @@
+package main
+
+import (
+	"flag"
+	"strings"
+)
+
+// 可以重复的 -I dir 参数：import在主程序所在目录之后查找模块的目录
+type importPath []string
+
+func (p *importPath) String() string { return strings.Join(*p, ",") }
+
+func (p *importPath) Set(dir string) error {
+	*p = append(*p, dir)
+	return nil
+}
+
+func addImportFlag(fs *flag.FlagSet) *importPath {
+	p := &importPath{}
+	fs.Var(p, "I", "在该目录中查找import的模块，可以重复")
+	return p
+}
@@ -0,0 +1,19 @@
+package testsrc
+
+// 各个包的测试和基准测试共用的Monkey源码
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Benchmark 基准测试用的大段源码，包含n个函数定义和调用。标识符不能含数字，用字母编号
+func Benchmark(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		name := strings.Map(func(r rune) rune { return r - '0' + 'a' }, fmt.Sprint(i))
+		fmt.Fprintf(&b, "let add_%s = fn(x, y) { if (x < y) { return x + y * %d; } else { x - y / 2 } };\n", name, i)
+		fmt.Fprintf(&b, "let r_%s = add_%s(1 + 2 * (3 + 4) / 5 - 6, !true == false != (10 > 9));\n", name, name)
+	}
+	return b.String()
+}
@@ -1,21 +1,39 @@
 package lexer
 
-import "monkey/token"
+import (
+	"fmt"
+	"monkey/token"
+	"strings"
+)
 
 type Lexer struct {
 	input        string
 	position     int  //输入字符串当前位置
 	readPosition int  //输入字符串读取位置（当前位置的下一个）
 	ch           byte //当前正在查看的字符
+	line         int  //当前字符所在行
+	column       int  //当前字符所在列
+	trivia       bool //是否输出空白和注释词法单元
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar() //读取下一个字符，position=0，readPosition=1
 	return l
 }
 
+// NewWithTrivia 与New相同，但NextToken会额外返回WHITESPACE和COMMENT词法单元，供工具使用
+func NewWithTrivia(input string) *Lexer {
+	l := New(input)
+	l.trivia = true
+	return l
+}
+
 func (l *Lexer) readChar() { //读取一个字符
+	if l.ch == '\n' { //越过换行，行号+1，列号重置
+		l.line += 1
+		l.column = 0
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0 //是否达到input末尾
 	} else {
@@ -23,56 +41,88 @@ func (l *Lexer) readChar() { //读取一个字符
 	}
 	l.position = l.readPosition //更新位置
 	l.readPosition += 1         //更新位置+1
+	l.column += 1
+}
+
+// 当前字符的位置
+func (l *Lexer) pos() token.Position {
+	return token.Position{Offset: l.position, Line: l.line, Column: l.column}
 }
 
 func (l *Lexer) NextToken() token.Token { //转换当前*Lexer的正在查看的字符ch，返回为对应Token结构包含类型和值
+	if l.trivia {
+		if tok, ok := l.readTrivia(); ok {
+			return tok
+		}
+	} else {
+		l.skipWhitespace() //跳过空格、注释等无意义分隔符
+	}
+
+	pos := l.pos()
+	tok := l.readToken()
+	tok.Pos = pos
+	return tok
+}
+
+// 读取一个非琐碎内容的词法单元
+func (l *Lexer) readToken() token.Token {
 	var tok token.Token
-	l.skipWhitespace() //跳过空格等无意义分隔符
-	switch l.ch {      //匹配，得到语法单元<类型，值>
+	switch l.ch { //匹配，得到语法单元<类型，值>
 	case '=':
 		if l.peekChar() == '=' { // '=='，peekChar()仅查看下一个字符
-			ch := l.ch
 			l.readChar() //读取下一个字符并移动
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.EQ, Literal: literal}
+			tok = token.Token{Type: token.EQ, Literal: l.input[l.position-1 : l.readPosition]}
 		} else {
-			tok = newToken(token.ASSIGN, l.ch)
+			tok = l.newToken(token.ASSIGN)
 		}
 
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.newToken(token.PLUS)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '>' { // '->'，函数类型注解的返回类型
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: l.input[l.position-1 : l.readPosition]}
+		} else {
+			tok = l.newToken(token.MINUS)
+		}
 	case '!':
 		if l.peekChar() == '=' { // '!='，peekChar()仅查看下一个字符
-			ch := l.ch
 			l.readChar() //读取下一个字符并移动
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.NOT_EQ, Literal: literal}
+			tok = token.Token{Type: token.NOT_EQ, Literal: l.input[l.position-1 : l.readPosition]}
 		} else {
-			tok = newToken(token.BANG, l.ch)
+			tok = l.newToken(token.BANG)
 		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = l.newToken(token.SLASH)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		tok = l.newToken(token.ASTERISK)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		tok = l.newToken(token.LT)
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		tok = l.newToken(token.GT)
 
 	case ';':
-		tok = newToken(token.SEMICOLON, l.ch)
+		tok = l.newToken(token.SEMICOLON)
+	case ':':
+		tok = l.newToken(token.COLON)
+	case '.':
+		tok = l.newToken(token.DOT)
+	case '"':
+		return l.readString() //位置已改变，直接返回
 	case ('('):
-		tok = newToken(token.LPAREN, l.ch)
+		tok = l.newToken(token.LPAREN)
 	case ')':
-		tok = newToken(token.RPAREN, l.ch)
+		tok = l.newToken(token.RPAREN)
 	case ',':
-		tok = newToken(token.COMMA, l.ch)
+		tok = l.newToken(token.COMMA)
 	case '{':
-		tok = newToken(token.LBRACE, l.ch)
+		tok = l.newToken(token.LBRACE)
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+		tok = l.newToken(token.RBRACE)
+	case '[':
+		tok = l.newToken(token.LBRACKET)
+	case ']':
+		tok = l.newToken(token.RBRACKET)
 	case 0: //空
 		tok.Type = token.EOF
 		tok.Literal = ""
@@ -86,7 +136,7 @@ func (l *Lexer) NextToken() token.Token { //转换当前*Lexer的正在查看的
 			tok.Type = token.INT
 			return tok
 		} else {
-			tok = newToken(token.ILIEGAL, l.ch) //其他的字符统一报错
+			tok = l.newToken(token.ILIEGAL) //其他的字符统一报错
 		}
 	}
 
@@ -94,8 +144,9 @@ func (l *Lexer) NextToken() token.Token { //转换当前*Lexer的正在查看的
 	return tok
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token { //传入对应类型的名称和byte类型的值，转换为token结构体里
-	return token.Token{Type: tokenType, Literal: string(ch)}
+// 当前单个字符的词法单元，字面量直接切取输入字符串，避免string(ch)的内存分配
+func (l *Lexer) newToken(tokenType token.TokenType) token.Token {
+	return token.Token{Type: tokenType, Literal: l.input[l.position:l.readPosition]}
 }
 
 func isLetter(ch byte) bool { //判断是不是变量名
@@ -110,10 +161,51 @@ func (l *Lexer) readIdentifiler() string { //读出对应的字母下划线串
 	return l.input[position:l.position] //读出对应的字母下划线串
 }
 
-func (l *Lexer) skipWhitespace() { //跳过空格等无意义分隔符
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
-		l.readChar() //直接下一个
+func isWhitespace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+func (l *Lexer) skipWhitespace() { //跳过空格、注释等无意义分隔符
+	for {
+		switch {
+		case isWhitespace(l.ch):
+			l.readChar() //直接下一个
+		case l.isCommentStart():
+			l.readComment()
+		default:
+			return
+		}
+	}
+}
+
+// 当前是否是行注释 //
+func (l *Lexer) isCommentStart() bool {
+	return l.ch == '/' && l.peekChar() == '/'
+}
+
+// 读出注释直到行尾，不包含换行符
+func (l *Lexer) readComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	return l.input[position:l.position]
+}
+
+// 保留琐碎内容模式下，读取连续空白或一行注释
+func (l *Lexer) readTrivia() (token.Token, bool) {
+	pos := l.pos()
+	switch {
+	case isWhitespace(l.ch):
+		position := l.position
+		for isWhitespace(l.ch) {
+			l.readChar()
+		}
+		return token.Token{Type: token.WHITESPACE, Literal: l.input[position:l.position], Pos: pos}, true
+	case l.isCommentStart():
+		return token.Token{Type: token.COMMENT, Literal: l.readComment(), Pos: pos}, true
 	}
+	return token.Token{}, false
 }
 
 func isDigit(ch byte) bool { //判断是不是变量名
@@ -128,6 +220,65 @@ func (l *Lexer) readNumber() string { //读出对应的字母下划线串
 	return l.input[position:l.position] //读出对应的字母下划线串
 }
 
+// 读出字符串字面量，字面量保留两边的引号和转义序列，值由Unquote得到。
+// 字符串不能跨行，到行尾或输入结束还没有结束引号时为ILIEGAL
+func (l *Lexer) readString() token.Token {
+	position := l.position
+	for {
+		l.readChar()
+		switch l.ch {
+		case '\\':
+			if next := l.peekChar(); next != '\n' && next != 0 {
+				l.readChar() //跳过被转义的字符，例：\"
+			}
+		case '"':
+			l.readChar()
+			return token.Token{Type: token.STRING, Literal: l.input[position:l.position]}
+		case '\n', 0:
+			return token.Token{Type: token.ILIEGAL, Literal: l.input[position:l.position]}
+		}
+	}
+}
+
+// Unquote 字符串字面量的值，支持 \" \\ \n \t 转义
+func Unquote(lit string) (string, error) {
+	if len(lit) < 2 || lit[0] != '"' || lit[len(lit)-1] != '"' {
+		return "", fmt.Errorf("invalid string literal %s", lit)
+	}
+	lit = lit[1 : len(lit)-1]
+	if strings.IndexByte(lit, '\\') < 0 {
+		return lit, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(lit); i++ {
+		if lit[i] != '\\' {
+			b.WriteByte(lit[i])
+			continue
+		}
+		i++
+		if i == len(lit) {
+			return "", fmt.Errorf("invalid string literal %q", lit)
+		}
+		switch lit[i] {
+		case '"', '\\':
+			b.WriteByte(lit[i])
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		default:
+			return "", fmt.Errorf("unknown escape sequence \\%c", lit[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// Quote 把字符串写成Unquote能还原的字面量
+func Quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
 func (l *Lexer) peekChar() byte { //超前搜索
 	if l.readPosition >= len(l.input) {
 		return 0
@@ -135,3 +286,24 @@ func (l *Lexer) peekChar() byte { //超前搜索
 		return l.input[l.readPosition] //查看下一个单词
 	}
 }
+
+// Tokenize 把整个源码转换为词法单元切片，以EOF结尾
+func Tokenize(input string) []token.Token {
+	return collect(New(input))
+}
+
+// TokenizeWithTrivia 同Tokenize，但保留空白和注释词法单元，拼接所有Literal可还原源码
+func TokenizeWithTrivia(input string) []token.Token {
+	return collect(NewWithTrivia(input))
+}
+
+func collect(l *Lexer) []token.Token {
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
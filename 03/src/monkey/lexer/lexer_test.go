@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"monkey/internal/testsrc"
 	"monkey/token"
 	"testing"
 )
@@ -26,6 +27,10 @@ if (5 < 10) {
 
 10 == 10;
 10 != 9;
+macro(x, y) { x + y; };
+fn(a: int) -> int
+import m "lib/math"; export let x = m.sqrt;
+[1, 2][0];
 `
 	tests := []struct { //结构体抽象结构,测试返回结果是否匹配
 		expectedType    token.TokenType
@@ -96,6 +101,57 @@ if (5 < 10) {
 		{token.FALSE, "false"},
 		{token.SEMICOLON, ";"},
 		{token.RBRACE, "}"},
+		{token.INT, "10"},
+		{token.EQ, "=="},
+		{token.INT, "10"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "10"},
+		{token.NOT_EQ, "!="},
+		{token.INT, "9"},
+		{token.SEMICOLON, ";"},
+		{token.MACRO, "macro"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.COMMA, ","},
+		{token.IDENT, "y"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.IDENT, "y"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "a"},
+		{token.COLON, ":"},
+		{token.IDENT, "int"},
+		{token.RPAREN, ")"},
+		{token.ARROW, "->"},
+		{token.IDENT, "int"},
+		{token.IMPORT, "import"},
+		{token.IDENT, "m"},
+		{token.STRING, `"lib/math"`},
+		{token.SEMICOLON, ";"},
+		{token.EXPORT, "export"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "m"},
+		{token.DOT, "."},
+		{token.IDENT, "sqrt"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
 	}
 	l := New(input)
 
@@ -109,3 +165,126 @@ if (5 < 10) {
 		}
 	}
 }
+
+// 字符串字面量：Literal是带引号的原文，Unquote处理转义
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		typ      token.TokenType
+		literal  string
+		unquoted string
+	}{
+		{`"lib/math"`, token.STRING, `"lib/math"`, "lib/math"},
+		{`"a\"b\\c\n"`, token.STRING, `"a\"b\\c\n"`, "a\"b\\c\n"},
+		{`""`, token.STRING, `""`, ""},
+		{`"abc`, token.ILIEGAL, `"abc`, ""},
+		{"\"ab\ncd\"", token.ILIEGAL, `"ab`, ""},
+	}
+
+	for _, tt := range tests {
+		tok := New(tt.input).NextToken()
+		if tok.Type != tt.typ || tok.Literal != tt.literal {
+			t.Errorf("%s: got %s %q, want %s %q", tt.input, tok.Type, tok.Literal, tt.typ, tt.literal)
+			continue
+		}
+		if tok.Type != token.STRING {
+			continue
+		}
+		s, err := Unquote(tok.Literal)
+		if err != nil || s != tt.unquoted {
+			t.Errorf("Unquote(%s) = %q, %v, want %q", tok.Literal, s, err, tt.unquoted)
+		}
+	}
+
+	if _, err := Unquote(`"\q"`); err == nil {
+		t.Errorf("expected error for unknown escape")
+	}
+	for _, s := range []string{"", "a\"b\\c\n\t", "plain"} {
+		if got, err := Unquote(Quote(s)); err != nil || got != s {
+			t.Errorf("Unquote(Quote(%q)) = %q, %v", s, got, err)
+		}
+	}
+}
+
+// 测试词法单元的行列位置
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x + 10;"
+
+	tests := []struct {
+		expectedType token.TokenType
+		line, column int
+		offset       int
+	}{
+		{token.LET, 1, 1, 0},
+		{token.IDENT, 1, 5, 4},
+		{token.ASSIGN, 1, 7, 6},
+		{token.INT, 1, 9, 8},
+		{token.SEMICOLON, 1, 10, 9},
+		{token.IDENT, 2, 3, 13},
+		{token.PLUS, 2, 5, 15},
+		{token.INT, 2, 7, 17},
+		{token.SEMICOLON, 2, 9, 19},
+		{token.EOF, 2, 10, 20},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d]-tokentype wrong. expected=%q,got =%q", i, tt.expectedType, tok.Type)
+		}
+		want := token.Position{Offset: tt.offset, Line: tt.line, Column: tt.column}
+		if tok.Pos != want {
+			t.Fatalf("tests[%d]-position wrong. expected=%+v,got =%+v", i, want, tok.Pos)
+		}
+	}
+}
+
+// 测试注释被跳过，以及保留琐碎内容模式
+func TestTokenizeComments(t *testing.T) {
+	input := "// 注释\nlet a = 1; // 行尾注释\na / 2"
+
+	var types []token.TokenType
+	for _, tok := range Tokenize(input) {
+		types = append(types, tok.Type)
+	}
+	expected := []token.TokenType{
+		token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.SLASH, token.INT, token.EOF,
+	}
+	if len(types) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%v,got =%v", expected, types)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Fatalf("tokens[%d] wrong. expected=%q,got =%q", i, expected[i], types[i])
+		}
+	}
+
+	trivia := TokenizeWithTrivia(input)
+	if trivia[0].Type != token.COMMENT || trivia[0].Literal != "// 注释" {
+		t.Fatalf("first token is not comment. got=%+v", trivia[0])
+	}
+	if trivia[1].Type != token.WHITESPACE || trivia[1].Literal != "\n" {
+		t.Fatalf("second token is not whitespace. got=%+v", trivia[1])
+	}
+
+	var source string
+	for _, tok := range trivia { //拼接所有词法单元可还原源码
+		source += tok.Literal
+	}
+	if source != input {
+		t.Fatalf("trivia tokens do not round-trip. expected=%q,got =%q", input, source)
+	}
+}
+
+func BenchmarkNextToken(b *testing.B) {
+	input := testsrc.Benchmark(1000)
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l := New(input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/lsp"
+	"os"
+)
+
+// monkey lsp 通过标准输入输出运行语言服务器，供编辑器（VS Code、Neovim等）启动
+func runLSP(args []string) int {
+	fs := flag.NewFlagSet("lsp", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey lsp")
+		fmt.Fprintln(fs.Output(), "语言服务器通过标准输入输出通信，由编辑器启动")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey lsp: %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,151 @@
+package lsp
+
+import (
+	"monkey/analysis"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"monkey/typecheck"
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// 编辑器中打开的一个文档，每次变更后重新解析
+type document struct {
+	uri     string
+	version int
+	text    string
+	lines   []int //每行第一个字节的偏移
+
+	program     *ast.Program //有语法错误时是能解析出的部分
+	parseErrors []parser.Error
+	index       *index
+}
+
+func newDocument(uri string, version int, text string) *document {
+	d := &document{uri: uri, version: version, text: text, lines: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+	p := parser.New(lexer.New(text))
+	d.program = p.ParseProgram()
+	d.parseErrors = p.ErrorList()
+	d.index = buildIndex(d.program, len(text))
+	return d
+}
+
+// 字节偏移转为LSP位置
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+	return Position{Line: line, Character: utf16Len(d.text[d.lines[line]:offset])}
+}
+
+// LSP位置转为字节偏移，超出行尾时取行尾
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	start := d.lines[pos.Line]
+	end := len(d.text)
+	if pos.Line+1 < len(d.lines) {
+		end = d.lines[pos.Line+1] - 1
+	}
+	units := 0
+	for i, r := range d.text[start:end] {
+		if units >= pos.Character {
+			return start + i
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return end
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// 源码范围 [pos, end)，没有结束位置时取pos处的一个字符
+func (d *document) rangeOf(pos, end token.Position) Range {
+	if end.Line == 0 || end.Offset < pos.Offset {
+		end = pos
+		if pos.Offset < len(d.text) {
+			_, size := utf8.DecodeRuneInString(d.text[pos.Offset:])
+			end.Offset += size
+		}
+	}
+	return Range{Start: d.position(pos.Offset), End: d.position(end.Offset)}
+}
+
+func (d *document) nodeRange(node ast.Node) Range {
+	return d.rangeOf(node.Pos(), node.End())
+}
+
+// 标识符名字的范围，不含参数的类型注解
+func (d *document) nameRange(ident *ast.Identifier) Range {
+	start := ident.Token.Pos.Offset
+	return Range{Start: d.position(start), End: d.position(start + len(ident.Value))}
+}
+
+// 语法错误，没有语法错误时再做语义分析和类型检查
+func (d *document) diagnostics() []Diagnostic {
+	diags := []Diagnostic{}
+	for _, e := range d.parseErrors {
+		diags = append(diags, Diagnostic{
+			Range:    d.rangeOf(e.Pos, token.Position{}),
+			Severity: SeverityError,
+			Source:   "monkey",
+			Message:  e.Msg,
+		})
+	}
+	if len(d.parseErrors) != 0 {
+		return diags
+	}
+
+	found := analysis.Check(d.program, &analysis.Config{Globals: builtinNames()})
+	found = append(found, typecheck.Check(d.program, nil)...)
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Pos.Offset < found[j].Pos.Offset })
+	for _, f := range found {
+		severity := SeverityError
+		if f.Severity == analysis.Warning {
+			severity = SeverityWarning
+		}
+		diags = append(diags, Diagnostic{
+			Range:    d.rangeOf(f.Pos, f.End),
+			Severity: severity,
+			Source:   "monkey",
+			Message:  f.Message,
+		})
+	}
+	return diags
+}
+
+// 偏移处（或紧挨在其前面）的单词，用于补全
+func (d *document) wordBefore(offset int) string {
+	start := offset
+	for start > 0 && isLetter(d.text[start-1]) {
+		start--
+	}
+	return d.text[start:offset]
+}
+
+func isLetter(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+}
@@ -0,0 +1,313 @@
+package lsp
+
+// 文档中名字的绑定和引用，用于悬停提示、跳转到定义和补全。
+// 作用域规则与analysis一致：程序顶层和每个函数（宏）字面量各是一个作用域，if的{}不产生新作用域
+
+import (
+	"monkey/ast"
+	"monkey/format"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+// 一个名字的绑定：let语句、import语句或函数（宏）参数
+type binding struct {
+	name    *ast.Identifier
+	let     *ast.LetStatement    //let绑定时非nil
+	imp     *ast.ImportStatement //import绑定时非nil
+	visible int                  //从该偏移起绑定生效：let语句结束之后，参数在整个函数中有效
+}
+
+type scope struct {
+	outer      *scope
+	start, end int //作用域的源码范围（字节偏移）
+	bindings   []*binding
+}
+
+type index struct {
+	top    *scope
+	scopes []*scope                     //全部作用域，外层在前
+	refs   map[*ast.Identifier]*binding //标识符（引用或定义处）对应的绑定
+	idents []*ast.Identifier            //全部标识符，按源码位置排序
+}
+
+func buildIndex(program *ast.Program, size int) *index {
+	idx := &index{refs: map[*ast.Identifier]*binding{}}
+	idx.top = idx.openScope(nil, 0, size+1)
+	idx.hoist(idx.top, program.Statements)
+	for _, s := range program.Statements {
+		idx.statement(idx.top, s)
+	}
+	sort.Slice(idx.idents, func(i, j int) bool {
+		return idx.idents[i].Token.Pos.Offset < idx.idents[j].Token.Pos.Offset
+	})
+	return idx
+}
+
+func (idx *index) openScope(outer *scope, start, end int) *scope {
+	sc := &scope{outer: outer, start: start, end: end}
+	idx.scopes = append(idx.scopes, sc)
+	return sc
+}
+
+func (idx *index) define(sc *scope, b *binding) {
+	sc.bindings = append(sc.bindings, b)
+	idx.refs[b.name] = b
+	idx.idents = append(idx.idents, b.name)
+}
+
+// 收集作用域中的let语句（不进入内层函数）
+func (idx *index) hoist(sc *scope, stmts []ast.Statement) {
+	for _, s := range stmts {
+		ast.Inspect(s, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FunctionLiteral, *ast.MacroLiteral:
+				return false
+			case *ast.LetStatement:
+				if n == nil { //语法错误的语句
+					return false
+				}
+				if n.Name != nil && n.Name.Token.Pos.Line > 0 {
+					idx.define(sc, &binding{name: n.Name, let: n, visible: n.End().Offset})
+				}
+			case *ast.ImportStatement:
+				if n == nil {
+					return false
+				}
+				idx.define(sc, &binding{name: importName(n), imp: n, visible: n.End().Offset})
+				return false
+			}
+			return true
+		})
+	}
+}
+
+// 语法错误时语句可能是nil指针
+func (idx *index) statement(sc *scope, s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		if s != nil {
+			idx.expr(sc, s.Value)
+		}
+	case *ast.ReturnStatement:
+		if s != nil {
+			idx.expr(sc, s.ReturnValue)
+		}
+	case *ast.ExpressionStatement:
+		if s != nil {
+			idx.expr(sc, s.Expression)
+		}
+	}
+}
+
+// import绑定的名字，没有别名时用路径的位置
+func importName(s *ast.ImportStatement) *ast.Identifier {
+	if s.Name != nil {
+		return s.Name
+	}
+	name := s.Binding()
+	return &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name, Pos: s.Path.Pos}, Value: name}
+}
+
+func (idx *index) block(sc *scope, b *ast.BlockStatement) {
+	if b != nil {
+		for _, s := range b.Statements {
+			idx.statement(sc, s)
+		}
+	}
+}
+
+func (idx *index) function(sc *scope, node ast.Expression, params []*ast.Identifier, body *ast.BlockStatement) {
+	inner := idx.openScope(sc, node.Pos().Offset, node.End().Offset)
+	for _, p := range params {
+		idx.define(inner, &binding{name: p})
+	}
+	if body != nil {
+		idx.hoist(inner, body.Statements)
+		idx.block(inner, body)
+	}
+}
+
+func (idx *index) expr(sc *scope, e ast.Expression) {
+	switch e := e.(type) {
+	case nil:
+	case *ast.Identifier:
+		idx.use(sc, e)
+	case *ast.PrefixExpression:
+		idx.expr(sc, e.Right)
+	case *ast.InfixExpression:
+		idx.expr(sc, e.Left)
+		idx.expr(sc, e.Right)
+	case *ast.IfExpression:
+		idx.expr(sc, e.Condition)
+		idx.block(sc, e.Consequence)
+		idx.block(sc, e.Alternative)
+	case *ast.FunctionLiteral:
+		idx.function(sc, e, e.Parameters, e.Body)
+	case *ast.MacroLiteral:
+		idx.function(sc, e, e.Parameters, e.Body)
+	case *ast.SelectorExpression:
+		idx.expr(sc, e.X)
+	case *ast.ArrayLiteral:
+		for _, el := range e.Elements {
+			idx.expr(sc, el)
+		}
+	case *ast.IndexExpression:
+		idx.expr(sc, e.Left)
+		idx.expr(sc, e.Index)
+	case *ast.CallExpression:
+		idx.expr(sc, e.Function)
+		for _, a := range e.Arguments {
+			idx.expr(sc, a)
+		}
+	}
+}
+
+// 引用处的名字对应的绑定：本作用域中在引用之前生效的最后一个绑定，
+// 或外层作用域中的绑定（内层函数调用时才执行，可以引用外层稍后定义的名字）
+func (idx *index) use(sc *scope, ident *ast.Identifier) {
+	if ident.Token.Pos.Line == 0 {
+		return
+	}
+	idx.idents = append(idx.idents, ident)
+	if b := idx.lookup(sc, ident.Value, ident.Token.Pos.Offset); b != nil {
+		idx.refs[ident] = b
+	}
+}
+
+func (idx *index) lookup(sc *scope, name string, offset int) *binding {
+	var later *binding //本作用域中引用之后才定义的绑定，找不到其他绑定时使用
+	for s, inner := sc, true; s != nil; s, inner = s.outer, false {
+		var found, first *binding
+		for _, b := range s.bindings {
+			if b.name.Value != name {
+				continue
+			}
+			if first == nil {
+				first = b
+			}
+			if inner && b.visible <= offset || !inner && b.name.Token.Pos.Offset <= offset {
+				found = b
+			}
+		}
+		if found == nil && !inner {
+			found = first
+		}
+		if found != nil {
+			return found
+		}
+		if later == nil {
+			later = first
+		}
+	}
+	return later
+}
+
+// 偏移处的标识符
+func (idx *index) identAt(offset int) *ast.Identifier {
+	i := sort.Search(len(idx.idents), func(i int) bool {
+		return idx.idents[i].Token.Pos.Offset > offset
+	}) - 1
+	if i < 0 {
+		return nil
+	}
+	ident := idx.idents[i]
+	if offset > ident.Token.Pos.Offset+len(ident.Value) { //光标紧挨标识符末尾时也算
+		return nil
+	}
+	return ident
+}
+
+// 偏移处最内层的作用域
+func (idx *index) scopeAt(offset int) *scope {
+	result := idx.top
+	for _, sc := range idx.scopes {
+		if sc.start <= offset && offset < sc.end && sc.start >= result.start {
+			result = sc
+		}
+	}
+	return result
+}
+
+// 偏移处可以使用的绑定，内层的同名绑定遮蔽外层
+func (idx *index) visibleAt(offset int) []*binding {
+	seen := map[string]bool{}
+	var result []*binding
+	for sc := idx.scopeAt(offset); sc != nil; sc = sc.outer {
+		for _, b := range sc.bindings {
+			if !seen[b.name.Value] {
+				seen[b.name.Value] = true
+				result = append(result, b)
+			}
+		}
+	}
+	return result
+}
+
+// 绑定的签名，函数的形式与object.Function.Inspect一致：fn(x, y)，带类型注解时一并给出
+func (b *binding) signature() string {
+	if b.imp != nil {
+		return b.name.Value + " = import " + b.imp.Path.Literal
+	}
+	if b.let == nil {
+		sig := b.name.Value
+		if b.name.Type != nil {
+			sig += ": " + b.name.Type.String()
+		}
+		return sig
+	}
+	sig := b.name.Value
+	if b.let.Type != nil {
+		sig += ": " + b.let.Type.String()
+	}
+	switch v := b.let.Value.(type) {
+	case *ast.FunctionLiteral:
+		sig += " = fn" + params(v.Parameters)
+		if v.ReturnType != nil {
+			sig += " -> " + v.ReturnType.String()
+		}
+	case *ast.MacroLiteral:
+		sig += " = macro" + params(v.Parameters)
+	case nil:
+	default:
+		if value, ok := formatNode(v); ok && len(value) <= 60 && !strings.Contains(value, "\n") {
+			sig += " = " + value
+		}
+	}
+	return sig
+}
+
+func params(list []*ast.Identifier) string {
+	names := make([]string, 0, len(list))
+	for _, p := range list {
+		if p.Type != nil {
+			names = append(names, p.Value+": "+p.Type.String())
+		} else {
+			names = append(names, p.Value)
+		}
+	}
+	return "(" + strings.Join(names, ", ") + ")"
+}
+
+// 绑定的是函数或宏
+func (b *binding) isFunction() bool {
+	if b.let == nil {
+		return false
+	}
+	switch b.let.Value.(type) {
+	case *ast.FunctionLiteral, *ast.MacroLiteral:
+		return true
+	}
+	return false
+}
+
+// 格式化节点。有语法错误时ast中可能缺少子节点，格式化失败返回false
+func formatNode(node ast.Node) (s string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			s, ok = "", false
+		}
+	}()
+	return format.Node(node), true
+}
@@ -0,0 +1,379 @@
+package lsp
+
+// 语言服务器：编辑器通过标准输入输出与其通信（JSON-RPC，Content-Length分帧），
+// 每次编辑后发布诊断（语法错误、analysis的语义检查和typecheck的类型检查），
+// 并提供悬停提示、跳转到定义、文档符号、补全和格式化
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/format"
+	"monkey/framing"
+	"monkey/stdlib"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+// 求值器特殊处理的调用和标准库的内置函数，补全和悬停时作为内置函数
+var builtins = map[string]string{
+	"quote":   "quote(expr)\n\n返回expr未求值的ast，其中的unquote在此时求值",
+	"unquote": "unquote(expr)\n\n在quote中对expr求值，把结果转换为ast插入",
+}
+
+func init() {
+	for _, f := range stdlib.BuiltinFuncs() {
+		builtins[f.Name] = f.Sig + "\n\n" + f.Doc
+	}
+}
+
+func builtinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type server struct {
+	in       *bufio.Reader
+	out      io.Writer
+	docs     map[string]*document
+	shutdown bool //已收到shutdown请求
+}
+
+// Serve 在in和out上运行语言服务器，直到收到exit通知或输入结束。
+// 先收到shutdown请求再退出时返回nil
+func Serve(in io.Reader, out io.Writer) error {
+	s := &server{in: bufio.NewReader(in), out: out, docs: map[string]*document{}}
+	for {
+		body, err := framing.Read(s.in)
+		if err == io.EOF && s.shutdown {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.replyError(nil, codeParseError, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		}
+		if err := s.handle(&req); err != nil {
+			return err
+		}
+	}
+}
+
+type handler func(s *server, params json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":              (*server).initialize,
+	"initialized":             nop,
+	"shutdown":                (*server).shutdownRequest,
+	"textDocument/didOpen":    (*server).didOpen,
+	"textDocument/didChange":  (*server).didChange,
+	"textDocument/didClose":   (*server).didClose,
+	"textDocument/didSave":    nop,
+	"textDocument/hover":      (*server).hover,
+	"textDocument/definition": (*server).definition,
+
+	"textDocument/documentSymbol": (*server).documentSymbol,
+	"textDocument/completion":     (*server).completion,
+	"textDocument/formatting":     (*server).formatting,
+}
+
+func nop(*server, json.RawMessage) (interface{}, error) { return nil, nil }
+
+// 参数格式错误
+type paramsError struct{ err error }
+
+func (e *paramsError) Error() string { return "invalid params: " + e.err.Error() }
+
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &paramsError{err}
+	}
+	return nil
+}
+
+// 处理一条请求或通知，只有写出消息失败时返回错误
+func (s *server) handle(req *request) error {
+	h, ok := handlers[req.Method]
+	if req.ID == nil { //通知不需要回复，未知的通知（例：$/cancelRequest）忽略
+		if ok {
+			call(h, s, req.Params)
+		}
+		return nil
+	}
+	if s.shutdown {
+		return s.replyError(req.ID, codeInvalidRequest, "server is shutting down")
+	}
+	if !ok {
+		return s.replyError(req.ID, codeMethodNotFound, fmt.Sprintf("method not found: %s", req.Method))
+	}
+	result, err := call(h, s, req.Params)
+	if err != nil {
+		code := codeInvalidRequest
+		switch err.(type) {
+		case *paramsError:
+			code = codeInvalidParams
+		case *internalError:
+			code = codeInternalError
+		}
+		return s.replyError(req.ID, code, err.Error())
+	}
+	return framing.Write(s.out, &response{JSONRPC: "2.0", ID: req.ID, Result: result})
+}
+
+// 处理函数panic（例：不完整的ast触发的bug），只影响这一条请求，服务继续运行
+type internalError struct{ value interface{} }
+
+func (e *internalError) Error() string { return fmt.Sprintf("internal error: %v", e.value) }
+
+// 调用处理函数，把panic转换为internalError
+func call(h handler, s *server, params json.RawMessage) (result interface{}, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			result, err = nil, &internalError{v}
+		}
+	}()
+	return h(s, params)
+}
+
+func (s *server) replyError(id *json.RawMessage, code int, message string) error {
+	return framing.Write(s.out, &errorResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   &responseError{Code: code, Message: message},
+	})
+}
+
+func (s *server) notify(method string, params interface{}) error {
+	return framing.Write(s.out, &notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *server) initialize(json.RawMessage) (interface{}, error) {
+	var result InitializeResult
+	result.ServerInfo.Name = "monkey-lsp"
+	result.Capabilities = ServerCapabilities{
+		TextDocumentSync:           1, //全量同步
+		HoverProvider:              true,
+		DefinitionProvider:         true,
+		DocumentSymbolProvider:     true,
+		CompletionProvider:         map[string]interface{}{},
+		DocumentFormattingProvider: true,
+	}
+	return result, nil
+}
+
+func (s *server) shutdownRequest(json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+/*文档同步*/
+
+func (s *server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p DidOpenTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc := newDocument(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text)
+	s.docs[doc.uri] = doc
+	return nil, s.publish(doc)
+}
+
+func (s *server) didChange(params json.RawMessage) (interface{}, error) {
+	var p DidChangeTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+	text := p.ContentChanges[len(p.ContentChanges)-1].Text
+	doc := newDocument(p.TextDocument.URI, p.TextDocument.Version, text)
+	s.docs[doc.uri] = doc
+	return nil, s.publish(doc)
+}
+
+func (s *server) didClose(params json.RawMessage) (interface{}, error) {
+	var p DidCloseTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	return nil, s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+func (s *server) publish(doc *document) error {
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Version:     doc.version,
+		Diagnostics: doc.diagnostics(),
+	})
+}
+
+// 请求中的文档和光标的字节偏移
+func (s *server) documentAt(params json.RawMessage) (*document, int, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, 0, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown document %s", p.TextDocument.URI)
+	}
+	return doc, doc.offset(p.Position), nil
+}
+
+/*语言功能*/
+
+func (s *server) hover(params json.RawMessage) (interface{}, error) {
+	doc, offset, err := s.documentAt(params)
+	if err != nil {
+		return nil, err
+	}
+	ident := doc.index.identAt(offset)
+	if ident == nil {
+		return nil, nil
+	}
+	var text string
+	if b, ok := doc.index.refs[ident]; ok {
+		if b.let != nil {
+			text = "```monkey\nlet " + b.signature() + "\n```"
+		} else {
+			text = "```monkey\n(parameter) " + b.signature() + "\n```"
+		}
+	} else if help, ok := builtins[ident.Value]; ok {
+		sig, rest, _ := strings.Cut(help, "\n\n")
+		text = "```monkey\n(built-in) " + sig + "\n```\n" + rest
+	} else {
+		return nil, nil
+	}
+	r := doc.nameRange(ident)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}, nil
+}
+
+func (s *server) definition(params json.RawMessage) (interface{}, error) {
+	doc, offset, err := s.documentAt(params)
+	if err != nil {
+		return nil, err
+	}
+	ident := doc.index.identAt(offset)
+	if ident == nil {
+		return nil, nil
+	}
+	b, ok := doc.index.refs[ident]
+	if !ok {
+		return nil, nil
+	}
+	return []Location{{URI: doc.uri, Range: doc.nameRange(b.name)}}, nil
+}
+
+func (s *server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p DocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, fmt.Errorf("unknown document %s", p.TextDocument.URI)
+	}
+	return doc.symbols(doc.index.top), nil
+}
+
+// 作用域中的let绑定，函数中的绑定作为其子符号
+func (d *document) symbols(sc *scope) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, b := range sc.bindings {
+		if b.let == nil {
+			continue
+		}
+		sym := DocumentSymbol{
+			Name:           b.name.Value,
+			Detail:         strings.TrimPrefix(b.signature(), b.name.Value),
+			Kind:           SymbolVariable,
+			Range:          d.nodeRange(b.let),
+			SelectionRange: d.nameRange(b.name),
+		}
+		if b.isFunction() {
+			sym.Kind = SymbolFunction
+			for _, inner := range d.index.scopes {
+				if inner.outer == sc && inner.start == b.let.Value.Pos().Offset {
+					sym.Children = d.symbols(inner)
+				}
+			}
+		}
+		symbols = append(symbols, sym)
+	}
+	return symbols
+}
+
+func (s *server) completion(params json.RawMessage) (interface{}, error) {
+	doc, offset, err := s.documentAt(params)
+	if err != nil {
+		return nil, err
+	}
+	prefix := doc.wordBefore(offset)
+	items := []CompletionItem{}
+	add := func(item CompletionItem) {
+		if strings.HasPrefix(item.Label, prefix) {
+			items = append(items, item)
+		}
+	}
+	for _, b := range doc.index.visibleAt(offset) {
+		if b.name.Token.Pos.Offset+len(b.name.Value) == offset { //正在输入的名字本身
+			continue
+		}
+		item := CompletionItem{Label: b.name.Value, Kind: CompletionVariable, Detail: b.signature()}
+		if b.isFunction() {
+			item.Kind = CompletionFunction
+		}
+		add(item)
+	}
+	for _, name := range builtinNames() {
+		sig, _, _ := strings.Cut(builtins[name], "\n")
+		add(CompletionItem{Label: name, Kind: CompletionFunction, Detail: sig})
+	}
+	for _, kw := range token.Keywords() {
+		add(CompletionItem{Label: kw, Kind: CompletionKeyword})
+	}
+	return items, nil
+}
+
+func (s *server) formatting(params json.RawMessage) (interface{}, error) {
+	var p DocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, fmt.Errorf("unknown document %s", p.TextDocument.URI)
+	}
+	formatted, err := format.Source([]byte(doc.text))
+	if err != nil || string(formatted) == doc.text { //有语法错误时不格式化，错误已在诊断中给出
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{
+		Range:   Range{Start: Position{}, End: doc.position(len(doc.text))},
+		NewText: string(formatted),
+	}}, nil
+}
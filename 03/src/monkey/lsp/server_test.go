@@ -0,0 +1,393 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"monkey/framing"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+const testURI = "file:///test.mk"
+
+const testSource = `let add = fn(a: int, b: int) -> int {
+    let sum = a + b;
+    sum
+};
+let twice = fn(f, x) { f(f(x)) };
+let one = 1; // 一
+twice(fn(n) { add(n, 1) }, 5);
+`
+
+// 依次发送消息给服务器，返回服务器输出的全部消息
+type session struct {
+	in     bytes.Buffer
+	nextID int
+}
+
+func (s *session) request(method string, params interface{}) int {
+	s.nextID++
+	s.send(map[string]interface{}{"jsonrpc": "2.0", "id": s.nextID, "method": method, "params": params})
+	return s.nextID
+}
+
+func (s *session) notify(method string, params interface{}) {
+	s.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (s *session) send(msg interface{}) {
+	if err := framing.Write(&s.in, msg); err != nil {
+		panic(err)
+	}
+}
+
+type output struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+func (s *session) run(t *testing.T) []output {
+	t.Helper()
+	s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	var out bytes.Buffer
+	if err := Serve(&s.in, &out); err != nil {
+		t.Fatalf("Serve: %s", err)
+	}
+	var msgs []output
+	r := bufio.NewReader(&out)
+	for {
+		body, err := framing.Read(r)
+		if err != nil {
+			break
+		}
+		var msg output
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func result(t *testing.T, msgs []output, id int, v interface{}) {
+	t.Helper()
+	for _, m := range msgs {
+		if m.ID != nil && *m.ID == id {
+			if m.Error != nil {
+				t.Fatalf("request %d failed: %s", id, m.Error.Message)
+			}
+			if err := json.Unmarshal(m.Result, v); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+	}
+	t.Fatalf("no response to request %d", id)
+}
+
+func diagnostics(t *testing.T, msgs []output) [][]Diagnostic {
+	var all [][]Diagnostic
+	for _, m := range msgs {
+		if m.Method == "textDocument/publishDiagnostics" {
+			var p PublishDiagnosticsParams
+			if err := json.Unmarshal(m.Params, &p); err != nil {
+				t.Fatal(err)
+			}
+			all = append(all, p.Diagnostics)
+		}
+	}
+	return all
+}
+
+func open(s *session, text string) {
+	s.request("initialize", map[string]interface{}{})
+	s.notify("initialized", map[string]interface{}{})
+	s.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": TextDocumentItem{URI: testURI, LanguageID: "monkey", Version: 1, Text: text},
+	})
+}
+
+func at(line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": TextDocumentIdentifier{URI: testURI},
+		"position":     Position{Line: line, Character: character},
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	s := &session{}
+	open(s, testSource)
+	s.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   VersionedTextDocumentIdentifier{URI: testURI, Version: 2},
+		"contentChanges": []map[string]string{{"text": "let x = 1;\nlet y = x +;\n"}},
+	})
+	s.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   VersionedTextDocumentIdentifier{URI: testURI, Version: 3},
+		"contentChanges": []map[string]string{{"text": "let x: bool = 1;\nfoo(x);\n"}},
+	})
+	msgs := s.run(t)
+
+	all := diagnostics(t, msgs)
+	if len(all) != 3 {
+		t.Fatalf("expected 3 diagnostics notifications, got %d", len(all))
+	}
+	if len(all[0]) != 0 {
+		t.Errorf("expected no diagnostics for valid source, got %+v", all[0])
+	}
+	if len(all[1]) != 1 || all[1][0].Message != "no prefix parse function for ; found" ||
+		all[1][0].Range.Start != (Position{Line: 1, Character: 11}) {
+		t.Errorf("wrong syntax error diagnostics: %+v", all[1])
+	}
+	var messages []string
+	for _, d := range all[2] {
+		messages = append(messages, d.Message)
+	}
+	expected := []string{"cannot use int as bool in let x", "undefined: foo"}
+	if strings.Join(messages, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong diagnostics. want=%q, got=%q", expected, messages)
+	}
+}
+
+func TestHoverAndDefinition(t *testing.T) {
+	s := &session{}
+	open(s, testSource)
+	hoverAdd := s.request("textDocument/hover", at(6, 15))   //add(n, 1) 中的add
+	hoverParam := s.request("textDocument/hover", at(2, 5))  //sum
+	hoverQuote := s.request("textDocument/hover", at(0, 14)) //参数a
+	hoverNone := s.request("textDocument/hover", at(0, 1))
+	defAdd := s.request("textDocument/definition", at(6, 15))
+	defSum := s.request("textDocument/definition", at(2, 6))
+	defParam := s.request("textDocument/definition", at(1, 14)) //a + b 中的a
+	msgs := s.run(t)
+
+	var h Hover
+	result(t, msgs, hoverAdd, &h)
+	if h.Contents.Value != "```monkey\nlet add = fn(a: int, b: int) -> int\n```" {
+		t.Errorf("wrong hover for add: %q", h.Contents.Value)
+	}
+	result(t, msgs, hoverParam, &h)
+	if h.Contents.Value != "```monkey\nlet sum = a + b\n```" {
+		t.Errorf("wrong hover for sum: %q", h.Contents.Value)
+	}
+	result(t, msgs, hoverQuote, &h)
+	if h.Contents.Value != "```monkey\n(parameter) a: int\n```" {
+		t.Errorf("wrong hover for parameter: %q", h.Contents.Value)
+	}
+	var none *Hover
+	result(t, msgs, hoverNone, &none)
+	if none != nil {
+		t.Errorf("expected no hover on keyword, got %+v", none)
+	}
+
+	tests := []struct {
+		id       int
+		expected Range
+	}{
+		{defAdd, Range{Position{0, 4}, Position{0, 7}}},
+		{defSum, Range{Position{1, 8}, Position{1, 11}}},
+		{defParam, Range{Position{0, 13}, Position{0, 14}}},
+	}
+	for _, tt := range tests {
+		var locs []Location
+		result(t, msgs, tt.id, &locs)
+		if len(locs) != 1 || locs[0].URI != testURI || locs[0].Range != tt.expected {
+			t.Errorf("request %d: wrong definition. want=%+v, got=%+v", tt.id, tt.expected, locs)
+		}
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	s := &session{}
+	open(s, testSource)
+	id := s.request("textDocument/documentSymbol", map[string]interface{}{"textDocument": TextDocumentIdentifier{URI: testURI}})
+	msgs := s.run(t)
+
+	var symbols []DocumentSymbol
+	result(t, msgs, id, &symbols)
+	var got []string
+	var walk func(prefix string, list []DocumentSymbol)
+	walk = func(prefix string, list []DocumentSymbol) {
+		for _, sym := range list {
+			got = append(got, prefix+sym.Name+sym.Detail)
+			walk(prefix+sym.Name+".", sym.Children)
+		}
+	}
+	walk("", symbols)
+	expected := []string{"add = fn(a: int, b: int) -> int", "add.sum = a + b", "twice = fn(f, x)", "one = 1"}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong symbols. want=%q, got=%q", expected, got)
+	}
+	if symbols[0].Kind != SymbolFunction || symbols[2].Kind != SymbolVariable {
+		t.Errorf("wrong symbol kinds: %+v", symbols)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	s := &session{}
+	open(s, testSource)
+	inside := s.request("textDocument/completion", at(2, 4))  //add函数体中
+	prefix := s.request("textDocument/completion", at(6, 17)) //add(n, 1) 的ad之后
+	msgs := s.run(t)
+
+	labels := func(id int) []string {
+		var items []CompletionItem
+		result(t, msgs, id, &items)
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Label)
+		}
+		return names
+	}
+	got := strings.Join(labels(inside), " ")
+	for _, want := range []string{"a", "b", "sum", "add", "twice", "one", "quote", "unquote", "fn", "let"} {
+		if !strings.Contains(" "+got+" ", " "+want+" ") {
+			t.Errorf("completion inside add missing %q. got=%s", want, got)
+		}
+	}
+	if got := labels(prefix); strings.Join(got, " ") != "add" {
+		t.Errorf("wrong completion for prefix ad: %q", got)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	s := &session{}
+	open(s, "let x=fn(a){a+1};\nx(1)")
+	id := s.request("textDocument/formatting", map[string]interface{}{"textDocument": TextDocumentIdentifier{URI: testURI}})
+	msgs := s.run(t)
+
+	var edits []TextEdit
+	result(t, msgs, id, &edits)
+	if len(edits) != 1 {
+		t.Fatalf("expected 1 edit, got %+v", edits)
+	}
+	expected := "let x = fn(a) {\n    a + 1;\n};\nx(1);\n"
+	if edits[0].NewText != expected || edits[0].Range.End != (Position{Line: 1, Character: 4}) {
+		t.Errorf("wrong edit: %+v", edits[0])
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	s := &session{}
+	open(s, "")
+	unknown := s.request("textDocument/rename", at(0, 0))
+	badDoc := s.request("textDocument/hover", map[string]interface{}{"textDocument": TextDocumentIdentifier{URI: "file:///missing.mk"}})
+	msgs := s.run(t)
+
+	for _, tt := range []struct{ id, code int }{{unknown, codeMethodNotFound}, {badDoc, codeInvalidRequest}} {
+		for _, m := range msgs {
+			if m.ID != nil && *m.ID == tt.id && (m.Error == nil || m.Error.Code != tt.code) {
+				t.Errorf("request %d: expected error code %d, got %+v", tt.id, tt.code, m.Error)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	if err := Serve(strings.NewReader("Content-Length: 33\r\n\r\n{\"jsonrpc\":\"2.0\",\"method\":\"exit\"}"), &out); err == nil {
+		t.Errorf("expected error on exit without shutdown")
+	}
+}
+
+// 编辑过程中的文档常在语句中间截断（例：函数体中刚输入let），各请求都要正常回复
+func TestIncompleteDocuments(t *testing.T) {
+	s := &session{}
+	open(s, "let f = fn(x) {\n let")
+	var ids []int
+	version := 1
+	for i := range testSource { //testSource的每个前缀
+		text := testSource[:i]
+		version++
+		s.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   VersionedTextDocumentIdentifier{URI: testURI, Version: version},
+			"contentChanges": []map[string]string{{"text": text}},
+		})
+		lines := strings.Split(text, "\n")
+		end := at(len(lines)-1, utf8.RuneCountInString(lines[len(lines)-1]))
+		doc := map[string]interface{}{"textDocument": TextDocumentIdentifier{URI: testURI}}
+		ids = append(ids,
+			s.request("textDocument/hover", end),
+			s.request("textDocument/definition", end),
+			s.request("textDocument/completion", end),
+			s.request("textDocument/documentSymbol", doc),
+			s.request("textDocument/formatting", doc),
+		)
+	}
+	msgs := s.run(t)
+
+	replied := map[int]bool{}
+	for _, m := range msgs {
+		if m.ID == nil {
+			continue
+		}
+		replied[*m.ID] = true
+		if m.Error != nil {
+			t.Errorf("request %d failed: %+v", *m.ID, m.Error)
+		}
+	}
+	for _, id := range ids {
+		if !replied[id] {
+			t.Errorf("no response to request %d", id)
+		}
+	}
+	if n := len(diagnostics(t, msgs)); n != version {
+		t.Errorf("expected %d diagnostics notifications, got %d", version, n)
+	}
+}
+
+// 处理函数panic时回复internal error，服务继续处理之后的请求
+func TestHandlerPanic(t *testing.T) {
+	handlers["test/panic"] = func(*server, json.RawMessage) (interface{}, error) { panic("boom") }
+	defer delete(handlers, "test/panic")
+
+	s := &session{}
+	open(s, testSource)
+	failed := s.request("test/panic", nil)
+	s.notify("test/panic", nil)
+	symbols := s.request("textDocument/documentSymbol", map[string]interface{}{"textDocument": TextDocumentIdentifier{URI: testURI}})
+	msgs := s.run(t)
+
+	for _, m := range msgs {
+		if m.ID != nil && *m.ID == failed && (m.Error == nil || m.Error.Code != codeInternalError || m.Error.Message != "internal error: boom") {
+			t.Errorf("expected internal error, got %+v", m.Error)
+		}
+	}
+	var list []DocumentSymbol
+	result(t, msgs, symbols, &list)
+	if len(list) != 3 {
+		t.Errorf("expected 3 symbols after the panic, got %+v", list)
+	}
+}
+
+// LSP的列按UTF-16编码单元计算，源码位置按字节计算
+func TestPositions(t *testing.T) {
+	doc := newDocument(testURI, 1, "// 文😀\nlet x = 1;\n")
+	tests := []struct {
+		offset   int
+		expected Position
+	}{
+		{0, Position{0, 0}},
+		{3, Position{0, 3}},
+		{6, Position{0, 4}},
+		{10, Position{0, 6}},
+		{11, Position{1, 0}},
+		{15, Position{1, 4}},
+		{22, Position{2, 0}},
+	}
+	for _, tt := range tests {
+		if got := doc.position(tt.offset); got != tt.expected {
+			t.Errorf("position(%d) = %+v, want %+v", tt.offset, got, tt.expected)
+		}
+		if got := doc.offset(tt.expected); got != tt.offset {
+			t.Errorf("offset(%+v) = %d, want %d", tt.expected, got, tt.offset)
+		}
+	}
+	if got := doc.offset(Position{0, 100}); got != 10 {
+		t.Errorf("offset past end of line = %d, want 10", got)
+	}
+}
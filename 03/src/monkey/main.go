@@ -2,18 +2,42 @@ package main
 
 import (
 	"fmt"
+	"monkey/evaluator"
 	"monkey/repl"
+	"monkey/stdlib"
 	"os"
 	user2 "os/user"
 )
 
+// 子命令表：monkey <命令> [参数]，没有子命令时进入REPL
+var commands = map[string]func(args []string) int{
+	"tokens": runTokens,
+	"run":    runRun,
+	"ast":    runAST,
+	"fmt":    runFmt,
+	"check":  runCheck,
+	"test":   runTest,
+	"serve":  runServe,
+	"lsp":    runLSP,
+	"dap":    runDAP,
+	"doc":    runDoc,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
+		}
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		os.Exit(2)
+	}
+
 	user, err := user2.Current() //当前返回当前用户
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("Hello %s! 这是自定义语言的解释器!\n", user.Username)
-	fmt.Printf("该语言支持函数、高阶函数、闭包、整数，以及算术运算\n")
+	fmt.Printf("该语言支持函数、高阶函数、闭包、整数、字符串、数组，以及算术运算，import \"std/...\" 使用标准库\n")
 	fmt.Printf("比如：\n")
 	fmt.Printf("let add = fn(x,y) { return x + y };\n")
 	fmt.Printf("add(1 + 2 * (3 + 4) / 5 - 6, add(6, 7 * 8));\n")
@@ -27,5 +51,7 @@ func main() {
 	fmt.Printf("let applyFunc = fn(a,b,func) { func(a ,b) };\n")
 	fmt.Printf("applyFunc(2,2,add);\n")
 
-	repl.Start(os.Stdin, os.Stdout) //参数为系统的标准输入输出，
+	//参数为系统的标准输入输出，本地会话可以import当前目录中的模块和标准库
+	opts := append(stdlib.Options(), evaluator.WithImports())
+	repl.Start(os.Stdin, os.Stdout, repl.WithInterpreterOptions(opts...))
 }
@@ -1,9 +1,23 @@
 package object
 
+import (
+	"sort"
+	"sync"
+)
+
 // 环境：存储 {标识符,值}
+// 普通环境不加锁，只能被一个goroutine使用；NewSyncEnvironment创建的环境读写加锁，
+// 可作为多个解释器共享的全局环境。函数调用产生的局部域总是不加锁的，它只属于执行该调用的goroutine
+//
+// 环境有两种存储方式：全局环境和未经resolver解析的函数调用用map按名字存放；
+// resolver解析过的函数调用用NewFrame创建帧，参数和局部变量按槽位下标存放在切片中，
+// 标识符求值时直接用 (层数, 下标) 访问，不再逐层查map
 type Environment struct {
 	store map[string]Object
+	names []string //帧中各槽位的名字，与slots一一对应
+	slots []Object //帧中的值，nil表示该局部变量尚未绑定
 	outer *Environment
+	mu    *sync.RWMutex //非nil时读写加锁
 }
 
 // 环境——产生一个Environment-域 实例
@@ -12,6 +26,13 @@ func NewEnviroment() *Environment {
 	return &Environment{store: s} //产生一个Environment实例
 }
 
+// NewSyncEnvironment 产生一个并发安全的Environment，可在多个goroutine之间共享
+func NewSyncEnvironment() *Environment {
+	env := NewEnviroment()
+	env.mu = &sync.RWMutex{}
+	return env
+}
+
 // 实现函数的局部域，传入函数为外部的域，内部新建一个
 func NewEnclodedEnvironment(outer *Environment) *Environment {
 	env := NewEnviroment()
@@ -19,17 +40,128 @@ func NewEnclodedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
+// NewFrame 创建函数调用的帧，names是各槽位的名字（参数在前），由resolver给出
+func NewFrame(outer *Environment, names []string) *Environment {
+	return &Environment{names: names, slots: make([]Object, len(names)), outer: outer}
+}
+
 // 环境——域 中查找标识符对应的值
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
+	obj, ok := e.getLocal(name)
 	if !ok && e.outer != nil { //内部域没找到，且存在外部域
 		obj, ok = e.outer.Get(name) //外部域找
 	}
 	return obj, ok
 }
 
+// 只在本层查找
+func (e *Environment) getLocal(name string) (Object, bool) {
+	for i, n := range e.names {
+		if n == name && e.slots[i] != nil {
+			return e.slots[i], true
+		}
+	}
+	if e.store == nil {
+		return nil, false
+	}
+	if e.mu != nil {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+	}
+	obj, ok := e.store[name]
+	return obj, ok
+}
+
 // 环境——域 中存放 标识符对应的值
 func (e *Environment) Set(name string, val Object) Object {
+	for i, n := range e.names {
+		if n == name {
+			e.slots[i] = val
+			return val
+		}
+	}
+	if e.mu != nil {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+	}
+	if e.store == nil { //帧中没有该名字的槽位
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
+
+// GetAt 读取往外depth层的帧中slot号槽位的值。
+// 槽位尚未绑定时与按名字查找的语义一致：继续在更外层按名字查找
+func (e *Environment) GetAt(depth, slot int, name string) (Object, bool) {
+	frame := e
+	for i := 0; i < depth; i++ {
+		frame = frame.outer
+	}
+	if obj := frame.slots[slot]; obj != nil {
+		return obj, true
+	}
+	if frame.outer == nil {
+		return nil, false
+	}
+	return frame.outer.Get(name)
+}
+
+// SetAt 设置本层帧中slot号槽位的值
+func (e *Environment) SetAt(slot int, val Object) Object {
+	e.slots[slot] = val
+	return val
+}
+
+// Names 返回本层及外层环境中已绑定的名字，去重后按字母顺序排列
+func (e *Environment) Names() []string {
+	seen := map[string]bool{}
+	for env := e; env != nil; env = env.outer {
+		for i, n := range env.names {
+			if env.slots[i] != nil {
+				seen[n] = true
+			}
+		}
+		if env.mu != nil {
+			env.mu.RLock()
+		}
+		for n := range env.store {
+			seen[n] = true
+		}
+		if env.mu != nil {
+			env.mu.RUnlock()
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for n := range seen {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Outer 返回外层环境，全局环境返回nil
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// LocalNames 返回只在本层环境中已绑定的名字，按字母顺序排列
+func (e *Environment) LocalNames() []string {
+	var names []string
+	for i, n := range e.names {
+		if e.slots[i] != nil {
+			names = append(names, n)
+		}
+	}
+	if e.mu != nil {
+		e.mu.RLock()
+	}
+	for n := range e.store {
+		names = append(names, n)
+	}
+	if e.mu != nil {
+		e.mu.RUnlock()
+	}
+	sort.Strings(names)
+	return names
+}
@@ -15,10 +15,16 @@ const (
 	//类型被封装，对应一个封装结构体
 	INTEGER_OBJ      = "INTEGER" //整数类型
 	BOOLEAN_OBJ      = "BOOLEAN" //布尔类型
+	STRING_OBJ       = "STRING"  //字符串类型
+	ARRAY_OBJ        = "ARRAY"   //数组类型
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION" //函数封装
+	QUOTE_OBJ        = "QUOTE"    //quote(expr)返回的未求值ast
+	MACRO_OBJ        = "MACRO"    //宏
+	BUILTIN_OBJ      = "BUILTIN"  //Go实现的内置函数
+	MODULE_OBJ       = "MODULE"   //import得到的模块
 )
 
 type Object interface { //
@@ -42,6 +48,28 @@ type Boolean struct {
 func (b Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 func (b Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
 
+// 字符串类型
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+
+// 数组类型，创建后不再修改（push等返回新数组）
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	elements := make([]string, len(a.Elements))
+	for i, e := range a.Elements {
+		elements[i] = e.Inspect()
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
 // 空值
 type Null struct {
 }
@@ -70,6 +98,7 @@ type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Locals     []string //resolver解析过的函数：调用时按这些槽位创建帧，nil时使用map环境
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -90,3 +119,73 @@ func (f *Function) Inspect() string {
 
 	return out.String()
 }
+
+// Caller 由求值器实现，内置函数通过它调用作为参数传入的函数
+type Caller interface {
+	Call(fn Object, args ...Object) Object
+}
+
+// SizeChecker 求值器传给内置函数的Caller可能实现它：创建长度为n的字符串（字节数）或数组之前检查大小限制，
+// 超出时返回错误，内置函数应原样返回该错误
+type SizeChecker interface {
+	CheckSize(n int) *Error
+}
+
+// BuiltinFunction 内置函数的实现，出错时返回*Error
+type BuiltinFunction func(c Caller, args ...Object) Object
+
+// 内置函数，例：monkey test提供的assert
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin function " + b.Name }
+
+// 模块 import "lib/math" 的结果，通过 math.sqrt 访问导出的绑定
+type Module struct {
+	Name    string            //import的路径
+	Path    string            //模块的文件或目录
+	Env     *Environment      //模块顶层的环境
+	Exports map[string]Object //export let 导出的绑定
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return "module " + m.Name }
+
+// quote(expr) 的结果，封装未求值的ast节点
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+// 宏 封装 形参，宏体，定义时的域
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
@@ -0,0 +1,370 @@
+package optimizer
+
+// 求值前在ast上做的优化，结果与未优化的程序求值结果一致：
+//   - 常量折叠：整数的 + - * / < > == !=，布尔值的 == !=，前缀 - 和 !。
+//     除数为0的表达式不折叠，留到运行时报错
+//   - 死分支消除：条件是字面量的if只保留会执行的分支
+//   - 内联：参数都是字面量、函数体只有一个表达式的立即调用函数 fn(x) { x + 1 }(2)
+//
+// quote(...) 的参数是原样返回的ast，宏字面量在宏展开阶段求值，都不做优化。
+
+import (
+	"monkey/ast"
+	"monkey/token"
+	"strconv"
+)
+
+// Optimize 优化程序，直接修改并返回传入的ast。宏应当在优化前展开
+func Optimize(program *ast.Program) *ast.Program {
+	program.Statements = statements(program.Statements)
+	return program
+}
+
+// 优化语句序列，并删除不产生任何效果的语句
+func statements(stmts []ast.Statement) []ast.Statement {
+	out := make([]ast.Statement, 0, len(stmts))
+	for i, s := range stmts {
+		s = statement(s)
+		last := i == len(stmts)-1
+		es, ok := s.(*ast.ExpressionStatement)
+		if !ok {
+			out = append(out, s)
+			continue
+		}
+		if !last && isPure(es.Expression) { //值被丢弃且没有效果
+			continue
+		}
+		if block := takenBlock(es.Expression); block != nil && (!last || len(block.Statements) != 0) {
+			out = append(out, block.Statements...) //if的{}不产生新作用域，可以直接展开
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func statement(s ast.Statement) ast.Statement {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		s.Value = expression(s.Value)
+	case *ast.ReturnStatement:
+		s.ReturnValue = expression(s.ReturnValue)
+	case *ast.ExpressionStatement:
+		s.Expression = expression(s.Expression)
+	}
+	return s
+}
+
+func expression(e ast.Expression) ast.Expression {
+	switch e := e.(type) {
+	case *ast.PrefixExpression:
+		e.Right = expression(e.Right)
+		return foldPrefix(e)
+	case *ast.InfixExpression:
+		e.Left = expression(e.Left)
+		e.Right = expression(e.Right)
+		return foldInfix(e)
+	case *ast.IfExpression:
+		e.Condition = expression(e.Condition)
+		e.Consequence.Statements = statements(e.Consequence.Statements)
+		if e.Alternative != nil {
+			e.Alternative.Statements = statements(e.Alternative.Statements)
+		}
+		return eliminateBranch(e)
+	case *ast.FunctionLiteral:
+		e.Body.Statements = statements(e.Body.Statements)
+	case *ast.BlockStatement:
+		e.Statements = statements(e.Statements)
+	case *ast.ArrayLiteral:
+		for i, el := range e.Elements {
+			e.Elements[i] = expression(el)
+		}
+	case *ast.IndexExpression:
+		e.Left = expression(e.Left)
+		e.Index = expression(e.Index)
+	case *ast.CallExpression:
+		if e.Function.TokenLiteral() == "quote" {
+			return e
+		}
+		e.Function = expression(e.Function)
+		for i, a := range e.Arguments {
+			e.Arguments[i] = expression(a)
+		}
+		return inline(e)
+	}
+	return e
+}
+
+func foldPrefix(e *ast.PrefixExpression) ast.Expression {
+	switch right := e.Right.(type) {
+	case *ast.IntegerLiteral:
+		switch e.Operator {
+		case "-":
+			return integer(e.Pos(), -right.Value)
+		case "!":
+			return boolean(e.Pos(), false) //整数都是真值
+		}
+	case *ast.Boolean:
+		if e.Operator == "!" {
+			return boolean(e.Pos(), !right.Value)
+		}
+	}
+	return e
+}
+
+func foldInfix(e *ast.InfixExpression) ast.Expression {
+	switch left := e.Left.(type) {
+	case *ast.IntegerLiteral:
+		right, ok := e.Right.(*ast.IntegerLiteral)
+		if !ok {
+			return e
+		}
+		l, r := left.Value, right.Value
+		switch e.Operator {
+		case "+":
+			return integer(e.Pos(), l+r)
+		case "-":
+			return integer(e.Pos(), l-r)
+		case "*":
+			return integer(e.Pos(), l*r)
+		case "/":
+			if r == 0 {
+				return e
+			}
+			return integer(e.Pos(), l/r)
+		case "<":
+			return boolean(e.Pos(), l < r)
+		case ">":
+			return boolean(e.Pos(), l > r)
+		case "==":
+			return boolean(e.Pos(), l == r)
+		case "!=":
+			return boolean(e.Pos(), l != r)
+		}
+	case *ast.Boolean:
+		right, ok := e.Right.(*ast.Boolean)
+		if !ok {
+			return e
+		}
+		switch e.Operator {
+		case "==":
+			return boolean(e.Pos(), left.Value == right.Value)
+		case "!=":
+			return boolean(e.Pos(), left.Value != right.Value)
+		}
+	}
+	return e
+}
+
+// 条件是字面量时只保留会执行的分支：
+// 分支只有一个表达式时直接替换整个if，否则变为 if (true) { 分支 }，由statements在语句层展开
+func eliminateBranch(e *ast.IfExpression) ast.Expression {
+	truthy, ok := constantCondition(e.Condition)
+	if !ok || isTrueIf(e) {
+		return e
+	}
+	taken := e.Consequence
+	if !truthy {
+		taken = e.Alternative
+	}
+	if taken == nil { //if (false) {...} 没有else，值为null
+		e.Consequence = &ast.BlockStatement{Token: e.Consequence.Token, Rbrace: e.Consequence.Rbrace}
+		return e
+	}
+	if len(taken.Statements) == 1 {
+		if es, ok := taken.Statements[0].(*ast.ExpressionStatement); ok && es.Expression != nil {
+			return es.Expression
+		}
+	}
+	e.Condition = boolean(e.Condition.Pos(), true)
+	e.Consequence = taken
+	e.Alternative = nil
+	return e
+}
+
+// 字面量条件的真假，与求值器的isTruthy一致：整数都是真值
+func constantCondition(cond ast.Expression) (truthy, ok bool) {
+	switch cond := cond.(type) {
+	case *ast.Boolean:
+		return cond.Value, true
+	case *ast.IntegerLiteral:
+		return true, true
+	}
+	return false, false
+}
+
+// 已经化简过的 if (true) { ... }
+func isTrueIf(e *ast.IfExpression) bool {
+	b, ok := e.Condition.(*ast.Boolean)
+	return ok && b.Value && e.Alternative == nil
+}
+
+// 表达式是if (true) {...} 时返回其中的{}
+func takenBlock(e ast.Expression) *ast.BlockStatement {
+	if ie, ok := e.(*ast.IfExpression); ok && isTrueIf(ie) {
+		return ie.Consequence
+	}
+	return nil
+}
+
+// 求值不会出错、没有任何效果的表达式
+func isPure(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral, *ast.Boolean, *ast.StringLiteral, *ast.FunctionLiteral:
+		return true
+	case *ast.IfExpression:
+		b, ok := e.Condition.(*ast.Boolean)
+		return ok && !b.Value && e.Alternative == nil
+	}
+	return false
+}
+
+// 内联立即调用的函数字面量：参数都是字面量，函数体只有一个表达式且其中没有let、return和quote，
+// 用实参替换形参后就是调用的结果
+func inline(call *ast.CallExpression) ast.Expression {
+	fl, ok := call.Function.(*ast.FunctionLiteral)
+	if !ok || len(fl.Parameters) != len(call.Arguments) || len(fl.Body.Statements) != 1 {
+		return call
+	}
+	es, ok := fl.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok || es.Expression == nil || !inlinable(es.Expression) {
+		return call
+	}
+
+	values := map[string]ast.Expression{}
+	for i, p := range fl.Parameters {
+		if _, dup := values[p.Value]; dup {
+			return call
+		}
+		switch call.Arguments[i].(type) {
+		case *ast.IntegerLiteral, *ast.Boolean:
+			values[p.Value] = call.Arguments[i]
+		default:
+			return call
+		}
+	}
+	return expression(substitute(es.Expression, values).(ast.Expression))
+}
+
+// 表达式中（不含内层函数）没有let、return，任何位置都没有quote/unquote和宏
+func inlinable(e ast.Expression) bool {
+	ok := true
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.MacroLiteral:
+			ok = false
+		case *ast.CallExpression:
+			if name := n.Function.TokenLiteral(); name == "quote" || name == "unquote" {
+				ok = false
+			}
+		}
+		return ok
+	})
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.LetStatement, *ast.ReturnStatement:
+			ok = false
+		}
+		return ok
+	})
+	return ok
+}
+
+// 把形参替换为实参的副本，内层函数重新绑定了同名参数或局部变量时不进入替换
+func substitute(node ast.Node, values map[string]ast.Expression) ast.Node {
+	if len(values) == 0 {
+		return node
+	}
+	switch n := node.(type) {
+	case *ast.Identifier:
+		switch v := values[n.Value].(type) {
+		case *ast.IntegerLiteral:
+			return &ast.IntegerLiteral{Token: v.Token, Value: v.Value}
+		case *ast.Boolean:
+			return &ast.Boolean{Token: v.Token, Value: v.Value}
+		}
+	case *ast.LetStatement:
+		n.Value = substitute(n.Value, values).(ast.Expression)
+	case *ast.ReturnStatement:
+		n.ReturnValue = substitute(n.ReturnValue, values).(ast.Expression)
+	case *ast.ExpressionStatement:
+		if n.Expression != nil {
+			n.Expression = substitute(n.Expression, values).(ast.Expression)
+		}
+	case *ast.BlockStatement:
+		for i, s := range n.Statements {
+			n.Statements[i] = substitute(s, values).(ast.Statement)
+		}
+	case *ast.PrefixExpression:
+		n.Right = substitute(n.Right, values).(ast.Expression)
+	case *ast.InfixExpression:
+		n.Left = substitute(n.Left, values).(ast.Expression)
+		n.Right = substitute(n.Right, values).(ast.Expression)
+	case *ast.IfExpression:
+		n.Condition = substitute(n.Condition, values).(ast.Expression)
+		substitute(n.Consequence, values)
+		if n.Alternative != nil {
+			substitute(n.Alternative, values)
+		}
+	case *ast.SelectorExpression:
+		n.X = substitute(n.X, values).(ast.Expression)
+	case *ast.ArrayLiteral:
+		for i, el := range n.Elements {
+			n.Elements[i] = substitute(el, values).(ast.Expression)
+		}
+	case *ast.IndexExpression:
+		n.Left = substitute(n.Left, values).(ast.Expression)
+		n.Index = substitute(n.Index, values).(ast.Expression)
+	case *ast.CallExpression:
+		n.Function = substitute(n.Function, values).(ast.Expression)
+		for i, a := range n.Arguments {
+			n.Arguments[i] = substitute(a, values).(ast.Expression)
+		}
+	case *ast.FunctionLiteral:
+		substitute(n.Body, unshadowed(n, values))
+	}
+	return node
+}
+
+// 去掉在函数中被参数或let重新绑定的名字
+func unshadowed(fl *ast.FunctionLiteral, values map[string]ast.Expression) map[string]ast.Expression {
+	bound := map[string]bool{}
+	for _, p := range fl.Parameters {
+		bound[p.Value] = true
+	}
+	ast.Inspect(fl.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.LetStatement:
+			bound[n.Name.Value] = true
+		}
+		return true
+	})
+
+	rest := map[string]ast.Expression{}
+	for name, v := range values {
+		if !bound[name] {
+			rest[name] = v
+		}
+	}
+	return rest
+}
+
+// 折叠得到的字面量沿用原表达式的位置
+func integer(pos token.Position, value int64) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{
+		Token: token.Token{Type: token.INT, Literal: strconv.FormatInt(value, 10), Pos: pos},
+		Value: value,
+	}
+}
+
+func boolean(pos token.Position, value bool) *ast.Boolean {
+	t := token.Token{Type: token.FALSE, Literal: "false", Pos: pos}
+	if value {
+		t.Type, t.Literal = token.TRUE, "true"
+	}
+	return &ast.Boolean{Token: t, Value: value}
+}
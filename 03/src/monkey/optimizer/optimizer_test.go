@@ -0,0 +1,108 @@
+package optimizer_test
+
+import (
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/format"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/optimizer"
+	"monkey/parser"
+	"monkey/resolver"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * (3 + 4) / 5 - 6", "-3"},
+		{"let x = 2 * 3 + y;", "let x = 6 + y;"},
+		{"-(2 * 3)", "-6"},
+		{"!5", "false"},
+		{"!(1 < 2)", "false"},
+		{"true == (1 == 2)", "false"},
+		{"1 == true", "1 == true"},
+		{"10 / (5 - 5)", "10 / 0"},
+		{"-true", "-true"},
+		{"if (1 < 2) { a } else { b }", "a"},
+		{"if (1 > 2) { a } else { b }", "b"},
+		{"if (1 > 2) { a }", "if (false) {}"},
+		{"if (x) { 1 + 1 }", "if (x) { 2 }"},
+		{"if (true) { let a = 1; a }", "let a = 1; a"},
+		{"let v = if (false) { 1 } else { let a = 1; a };", "let v = if (true) { let a = 1; a };"},
+		{"1; f; true; fn(x) { x }; if (false) { 1 }; 2", "f; 2"},
+		{"fn(x, y) { x * y + z }(2, 3)", "6 + z"},
+		{"fn() { 1 + 2 }()", "3"},
+		{"fn(x) { fn(x) { x } }(1)", "fn(x) { x }"},
+		{"fn(x) { fn(y) { let x = y; x } }(1)", "fn(y) { let x = y; x }"},
+		{"fn(x) { fn(y) { x + y } }(1)", "fn(y) { 1 + y }"},
+		{"fn(x) { let y = x; y }(1)", "fn(x) { let y = x; y }(1)"},
+		{"fn(x) { x }(a)", "fn(x) { x }(a)"},
+		{"fn(x) { quote(x) }(1)", "fn(x) { quote(x) }(1)"},
+		{"quote(1 + 2)", "quote(1 + 2)"},
+	}
+
+	for _, tt := range tests {
+		program := optimizer.Optimize(parse(t, tt.input))
+		expected := format.Node(parse(t, tt.expected))
+		if got := format.Node(program); got != expected {
+			t.Errorf("%s: want %q, got %q", tt.input, expected, got)
+		}
+	}
+}
+
+// 优化前后求值结果一致
+func TestOptimizePreservesSemantics(t *testing.T) {
+	tests := []string{
+		"1 + 2 * (3 + 4) / 5 - 6",
+		"let add = fn(x, y) { return x + y }; add(1 + 2 * (3 + 4) / 5 - 6, add(6, 7 * 8));",
+		"fn(x) { x == 10 }(10);",
+		"let newAddr = fn(x) { fn(y) { x + y } }; let addTwo = newAddr(2); addTwo(2);",
+		"10 / (5 - 5)",
+		"let f = fn() { 1 / 0 }; 1",
+		"5 + true",
+		"!(1 < 2) == false",
+		"if (1 > 2) { 10 }",
+		"let f = fn(n) { if (true) { let a = n * 2; } a + 1 }; f(4)",
+		"let f = fn(n) { if (2 > 1) { return n; } 99 }; f(7)",
+		"let f = fn() { if (true) { return 1; }; 2 }; f()",
+		"if (true) { 5; }",
+		"if (true) {}",
+		"let a = 3; fn(x) { x + a }(4)",
+		"let g = fn(x) { fn(y) { let x = y * 2; x + 1 } }(100); g(5)",
+		"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(2 * 5)",
+		"let x = fn() { let a = 1; }(); x",
+		"fn(a, a) { a }(1, 2)",
+		"fn(x) { x }(1, 2)",
+	}
+
+	for _, input := range tests {
+		expected := evaluator.Eval(parse(t, input), object.NewEnviroment())
+
+		program := optimizer.Optimize(parse(t, input))
+		resolver.Resolve(program)
+		got := evaluator.Eval(program, object.NewEnviroment())
+
+		if inspect(got) != inspect(expected) {
+			t.Errorf("%s: optimized=%s, original=%s", input, inspect(got), inspect(expected))
+		}
+	}
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return obj.Inspect()
+}
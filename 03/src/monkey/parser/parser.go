@@ -17,9 +17,12 @@ const ( //设置运算符优先级
 	PRODUCT     //*
 	PREFIX      //-X or !X
 	CALL        //myFunction(X)
+	INDEX       //array[index]
+	SELECTOR    //math.sqrt
 )
 
-var precedences = map[token.TokenType]int{ //{类型：优先级}映射
+// 按词法单元类型索引的优先级表，0表示未定义（按LOWEST处理）
+var precedences = [token.NumTypes]int{ //{类型：优先级}映射
 	token.EQ:       EQUALS,      //=
 	token.NOT_EQ:   EQUALS,      //!=
 	token.LT:       LESSGREATER, //<
@@ -29,32 +32,42 @@ var precedences = map[token.TokenType]int{ //{类型：优先级}映射
 	token.SLASH:    PRODUCT,     // /
 	token.ASTERISK: PRODUCT,     //*
 
-	token.LPAREN: CALL, //'(' add(),调用表达式。 ？？但遇到（ 都会调用callExpression函数
+	token.LPAREN:   CALL,     //'(' add(),调用表达式。 ？？但遇到（ 都会调用callExpression函数
+	token.LBRACKET: INDEX,    //'[' array[index]
+	token.DOT:      SELECTOR, //'.' math.sqrt，模块成员
 }
 
 type Parser struct {
-	l         *lexer.Lexer //输入文本，调用l.nextToken读取词法单元
-	errors    []string     //存放错误
-	curToken  token.Token  //当前词法单元
-	peekToken token.Token  //下一个词法单元
+	l         *lexer.Lexer     //输入文本，调用l.nextToken读取词法单元
+	errors    []string         //存放错误
+	errorPos  []token.Position //各错误对应的源码位置，与errors一一对应
+	curToken  token.Token      //当前词法单元
+	peekToken token.Token      //下一个词法单元
 
-	prefixParseFns map[token.TokenType]prefixParseFn //检查token类型映射是否有管理的解析函数
-	infixParseFns  map[token.TokenType]infixParseFn  //实现token类型映射对应执行函数类型
+	prefixParseFns [token.NumTypes]prefixParseFn //按token类型索引的前缀解析函数表，nil表示没有
+	infixParseFns  [token.NumTypes]infixParseFn  //按token类型索引的中缀解析函数表
+
+	tracer     func(TraceEvent) //解析过程追踪回调，nil表示关闭
+	traceLevel int              //追踪嵌套深度
 }
 
-// 初始化
-func New(l *lexer.Lexer) *Parser {
+// 初始化，opts可开启追踪等可选功能
+func New(l *lexer.Lexer, opts ...Option) *Parser {
 	p := &Parser{l: l, errors: []string{}} //[]string{}空错误切片
+	for _, opt := range opts {
+		opt(p)
+	}
 
 	//读取2个词法单元，以设置curToken和peekToken
 	p.nextToken() //0，0->0,1 ;1表示指向第一个token
 	p.nextToken() //0,1->1,2
 
-	p.prefixParseFns = make(map[token.TokenType]prefixParseFn) //初始化前缀映射
-	p.registerPrefix(token.IDENT, p.parseIdentifier)           //标识符添加{token类型:解析函数}映射
-	p.registerPrefix(token.INT, p.parseIntegerLiteral)         //整数字面量添加{token类型:解析函数}映射
-	p.registerPrefix(token.BANG, p.parsePrefixExpression)      //前缀运算符（!）{token类型:解析函数}映射
-	p.registerPrefix(token.MINUS, p.parsePrefixExpression)     //前缀运算符（-）{token类型:解析函数}映射
+	p.registerPrefix(token.IDENT, p.parseIdentifier)       //标识符添加{token类型:解析函数}映射
+	p.registerPrefix(token.INT, p.parseIntegerLiteral)     //整数字面量添加{token类型:解析函数}映射
+	p.registerPrefix(token.STRING, p.parseStringLiteral)   //字符串字面量
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)  //数组字面量 [1, 2]
+	p.registerPrefix(token.BANG, p.parsePrefixExpression)  //前缀运算符（!）{token类型:解析函数}映射
+	p.registerPrefix(token.MINUS, p.parsePrefixExpression) //前缀运算符（-）{token类型:解析函数}映射
 
 	p.registerPrefix(token.TRUE, p.parseBoolean)  //布尔运算符（ture）{token类型:解析函数}映射
 	p.registerPrefix(token.FALSE, p.parseBoolean) //布尔运算符（false）{token类型:解析函数}映射
@@ -64,8 +77,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression) //if表达式： if-else +{ }
 
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral) //表达式fn
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)       //表达式macro
 
-	p.infixParseFns = make(map[token.TokenType]infixParseFn) //初始化中缀映射
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
@@ -75,7 +88,9 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 
-	p.registerInfix(token.LPAREN, p.parseCallExpression) //调用函数 add() (的中缀解析
+	p.registerInfix(token.LPAREN, p.parseCallExpression)    //调用函数 add() (的中缀解析
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression) //索引 array[0]
+	p.registerInfix(token.DOT, p.parseSelectorExpression)   //模块成员 math.sqrt
 
 	return p
 }
@@ -90,7 +105,7 @@ func (p *Parser) ParseProgram() *ast.Program { //调用语法分析器入口
 	program := &ast.Program{}              //AST根节点
 	program.Statements = []ast.Statement{} //子结构体初始化
 	for p.curToken.Type != token.EOF {     //遍历词法单元
-		stmt := p.parseStatement() //语法分析一句，返回指向该句生成的AST的指针（子节点）
+		stmt := p.parseTopLevelStatement() //语法分析一句，返回指向该句生成的AST的指针（子节点）
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt) //加入AST根节点的切片
 			//fmt.Println(stmt)                                     //输出查看解析的句子
@@ -101,12 +116,30 @@ func (p *Parser) ParseProgram() *ast.Program { //调用语法分析器入口
 
 }
 
+// 顶层的语句，比函数体中多了import和export
+func (p *Parser) parseTopLevelStatement() ast.Statement {
+	switch p.curToken.Type {
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
+	default:
+		return p.parseStatement()
+	}
+}
+
 // 语法分析一句，返回指向该句生成的AST的指针（子节点）
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	//按词法单元类型进行不同的处理
+	case token.IMPORT, token.EXPORT:
+		p.addError(p.curToken.Pos, fmt.Sprintf("%s is only allowed at top level", p.curToken.Literal))
+		return nil
 	case token.LET:
-		return p.parseLetStatement() //调用对LET语句的语法分析
+		if stmt := p.parseLetStatement(); stmt != nil { //调用对LET语句的语法分析
+			return stmt
+		}
+		return nil //失败时返回nil接口值，而不是值为nil的*ast.LetStatement
 	case token.RETURN:
 		return p.parseReturnStatement() //调用对RETURN语句的语法分析
 	default:
@@ -122,6 +155,11 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 	//let节点name放标识符节点
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(token.COLON) { //可选的类型注解 let x: int = 5
+		p.nextToken()
+		p.nextToken()
+		stmt.Type = p.parseType()
+	}
 	if !p.expectPeek(token.ASSIGN) { //下一个词法单元类型是否是'='
 		return nil
 	}
@@ -136,6 +174,48 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// import [<别名>] "<模块路径>"
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = p.curToken
+	path, err := lexer.Unquote(p.curToken.Literal)
+	if err != nil {
+		p.addError(p.curToken.Pos, err.Error())
+		return nil
+	}
+	if path == "" {
+		p.addError(p.curToken.Pos, "empty import path")
+		return nil
+	}
+	stmt.Value = path
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// export let <标识符> = <表达式>，导出顶层绑定
+func (p *Parser) parseExportStatement() ast.Statement {
+	export := p.curToken.Pos
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+	stmt := p.parseLetStatement()
+	if stmt == nil {
+		return nil
+	}
+	stmt.Export = export
+	return stmt
+}
+
 // Expression语句的语法分析
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken} //return语句根节点
@@ -152,7 +232,7 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 // Expression语句的语法分析
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	defer untrace(trace("parseExpressionStatement")) //添加跟踪语句，执行结束后输出
+	defer p.untrace(p.trace("parseExpressionStatement", LOWEST)) //添加跟踪语句，执行结束后输出
 
 	stmt := &ast.ExpressionStatement{Token: p.curToken} //return语句根节点
 	stmt.Expression = p.parseExpression(LOWEST)         //传入前一个运算符优先级，初始为最低 例：1+2  +与LOWEST比较
@@ -190,20 +270,45 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// Error 带位置的语法错误
+type Error struct {
+	Pos token.Position //出错的词法单元的位置
+	Msg string
+}
+
+func (e Error) String() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// ErrorList 返回带位置的语法错误，顺序与Errors一致
+func (p *Parser) ErrorList() []Error {
+	list := make([]Error, len(p.errors))
+	for i, msg := range p.errors {
+		list[i] = Error{Pos: p.errorPos[i], Msg: msg}
+	}
+	return list
+}
+
+// 加入错误队列，pos是出错的词法单元的位置
+func (p *Parser) addError(pos token.Position, msg string) {
+	p.errors = append(p.errors, msg)
+	p.errorPos = append(p.errorPos, pos)
+}
+
 // 当下一个token与预期不符时，报错并加入错误队列
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken.Pos, msg)
 }
 
-// 定义函数类型，前缀解析函数和中缀解析函数，映射：map[token.TokenType]prefixParseFn
+// 定义函数类型，前缀解析函数和中缀解析函数，表：[token.NumTypes]prefixParseFn
 type (
 	prefixParseFn func() ast.Expression
 	infixParseFn  func(ast.Expression) ast.Expression
 )
 
-// 向{token类型:解析函数}映射中添加内容
+// 向{token类型:解析函数}表中添加内容
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
 	p.prefixParseFns[tokenType] = fn
 }
@@ -213,7 +318,7 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 
 // 检查前缀位置是否有token类型关联的解析函数
 func (p *Parser) parseExpression(precedence int) ast.Expression { //传入前一个运算符优先级，例：1+2+3 的第一个+
-	defer untrace(trace("parseExpression")) //添加跟踪语句，执行结束后输出
+	defer p.untrace(p.trace("parseExpression", precedence)) //添加跟踪语句，执行结束后输出
 
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
@@ -238,7 +343,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression { //传入前一
 // 前缀解析函数-没有加入error消息
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.Errors(), msg)
+	p.addError(p.curToken.Pos, msg)
 }
 
 // 表达式-标识符解析函数-返回Identifier节点包含token和value值
@@ -248,23 +353,55 @@ func (p *Parser) parseIdentifier() ast.Expression {
 
 // 表达式-整数字面量解析函数-返回IntegerLiteral节点包含token和value值,value是int类型
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	defer untrace(trace("parseIntegerLiteral")) //添加跟踪语句，执行结束后输出
+	defer p.untrace(p.trace("parseIntegerLiteral", LOWEST)) //添加跟踪语句，执行结束后输出
 
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 	//str转int64
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken.Pos, msg)
 		return nil
 	}
 	lit.Value = value
 	return lit
 }
 
+// 表达式-字符串字面量，Value是处理过转义的值
+func (p *Parser) parseStringLiteral() ast.Expression {
+	value, err := lexer.Unquote(p.curToken.Literal)
+	if err != nil {
+		p.addError(p.curToken.Pos, err.Error())
+		return nil
+	}
+	return &ast.StringLiteral{Token: p.curToken, Value: value}
+}
+
+// 表达式-数组字面量 [<expression>, <expression>, ...]
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	if p.curTokenIs(token.RBRACKET) {
+		array.Rbracket = p.curToken.Pos
+	}
+	return array
+}
+
+// 索引表达式 array[index]，'['作为中缀触发
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.Rbracket = p.curToken.Pos
+	return exp
+}
+
 // 表达式-前缀运算符解析函数
 func (p *Parser) parsePrefixExpression() ast.Expression {
-	defer untrace(trace("parsePrefixExpression")) //添加跟踪语句，执行结束后输出
+	defer p.untrace(p.trace("parsePrefixExpression", PREFIX)) //添加跟踪语句，执行结束后输出
 
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -279,15 +416,21 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 
 // 查看下一个token优先级，返回int，未定义的默认最低
 func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return precedenceOf(p.peekToken.Type)
 }
 
 // 查看当前token优先级，返回int，未定义的默认最低
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
+	return precedenceOf(p.curToken.Type)
+}
+
+// Precedence 返回词法单元作为中缀运算符时的优先级，不是中缀运算符的返回LOWEST
+func Precedence(t token.TokenType) int {
+	return precedenceOf(t)
+}
+
+func precedenceOf(t token.TokenType) int {
+	if p := precedences[t]; p != 0 {
 		return p
 	}
 	return LOWEST
@@ -295,7 +438,7 @@ func (p *Parser) curPrecedence() int {
 
 // 表达式-中缀运算符解析函数, 需传入左表达式
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression { //例1+2，传入1为*ast.IntegerLiteral， 节点
-	defer untrace(trace("parseInfixExpression")) //添加跟踪语句，执行结束后输出
+	defer p.untrace(p.trace("parseInfixExpression", p.curPrecedence())) //添加跟踪语句，执行结束后输出
 
 	expression := &ast.InfixExpression{
 		Token:    p.curToken,
@@ -373,6 +516,9 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	if p.curTokenIs(token.RBRACE) {
+		block.Rbrace = p.curToken.Pos
+	}
 	return block
 }
 
@@ -385,6 +531,12 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 
 	lit.Parameters = p.parseFunctionParameters() //解析标识符参数
 
+	if p.peekTokenIs(token.ARROW) { //可选的返回类型注解 -> int
+		p.nextToken()
+		p.nextToken()
+		lit.ReturnType = p.parseType()
+	}
+
 	if !p.expectPeek(token.LBRACE) { //expectPeek 预期正确会自动nextToken
 		return nil
 	} //	左大括号{
@@ -394,6 +546,70 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
+// 一个参数：标识符，后面可以跟类型注解 a: int
+func (p *Parser) parseParameter() *ast.Identifier {
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		ident.Type = p.parseType()
+	}
+	return ident
+}
+
+// 类型注解：类型名 int、bool，或函数类型 fn(<参数类型>, ...) -> <返回类型>
+func (p *Parser) parseType() ast.TypeExpr {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+	case token.FUNCTION:
+		ft := &ast.FunctionType{Token: p.curToken, Parameters: []ast.TypeExpr{}}
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		if p.peekTokenIs(token.RPAREN) {
+			p.nextToken()
+		} else {
+			p.nextToken()
+			ft.Parameters = append(ft.Parameters, p.parseType())
+			for p.peekTokenIs(token.COMMA) {
+				p.nextToken()
+				p.nextToken()
+				ft.Parameters = append(ft.Parameters, p.parseType())
+			}
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		p.nextToken()
+		ft.Result = p.parseType()
+		return ft
+	}
+	p.addError(p.curToken.Pos, fmt.Sprintf("expected type, got %s instead", p.curToken.Type))
+	return nil
+}
+
+// 表达式 macro <parameters> <block statement>，语法与fn相同
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseBlockStatement()
+
+	return lit
+}
+
 // 表达式 fn 的解析标识符参数，参数任意个
 func (p *Parser) parseFunctionParameters() []*ast.Identifier { //返回标识符（参数）数组指针
 	identifiers := []*ast.Identifier{}
@@ -403,15 +619,13 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier { //返回标识符
 	}
 	p.nextToken() //到第一个参数
 	//产生参数标识符节点，加入数组
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	identifiers = append(identifiers, p.parseParameter())
 
 	for p.peekTokenIs(token.COMMA) { //下一个是'，' 再加入一个参数
 		p.nextToken()
 		p.nextToken()
 		//产生参数标识符节点，加入数组
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		identifiers = append(identifiers, p.parseParameter())
 	}
 
 	if !p.expectPeek(token.RPAREN) { //最后期望是 ）
@@ -424,14 +638,32 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier { //返回标识符
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression { //中缀解析会传入leftExp，左语法树节点，即传入函数名 add标识符节点
 	exp := &ast.CallExpression{Token: p.curToken, Function: function} //p.curToken 为'（' ，Function:传入的标识符节点
 	exp.Arguments = p.parseCallArguments()                            //解析函数的词参数表达式
+	if p.curTokenIs(token.RPAREN) {
+		exp.Rparen = p.curToken.Pos
+	}
+	return exp
+}
+
+// 选择表达式 math.sqrt，'.'后面必须是标识符
+func (p *Parser) parseSelectorExpression(x ast.Expression) ast.Expression {
+	exp := &ast.SelectorExpression{Token: p.curToken, X: x}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Sel = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	return exp
 }
 
 // 调用表达式解析——解析调用表达式的参数，传入参数由n个表达式组成
 func (p *Parser) parseCallArguments() []ast.Expression {
+	return p.parseExpressionList(token.RPAREN)
+}
+
+// 逗号分隔的表达式列表，以end结尾：调用的参数、数组的元素
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	args := []ast.Expression{}
 
-	if p.peekTokenIs(token.RPAREN) { //没有传入参数的情况
+	if p.peekTokenIs(end) { //空列表
 		p.nextToken()
 		return args
 	}
@@ -445,7 +677,7 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 		args = append(args, p.parseExpression(LOWEST)) //解析表达式，参数即表达式
 	}
 
-	if !p.expectPeek(token.RPAREN) { // )结尾
+	if !p.expectPeek(end) { // )或]结尾
 		return nil
 	}
 
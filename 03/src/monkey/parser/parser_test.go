@@ -1,8 +1,10 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"monkey/ast"
+	"monkey/internal/testsrc"
 	"monkey/lexer"
 	"testing"
 )
@@ -850,3 +852,346 @@ func TestCallExpressionParameterParsing(t *testing.T) {
 		}
 	}
 }
+
+func BenchmarkParseProgram(b *testing.B) {
+	input := testsrc.Benchmark(1000)
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			b.Fatalf("parser errors: %v", p.Errors())
+		}
+	}
+}
+
+// 测试追踪事件：默认关闭，开启后BEGIN/END成对出现且深度正确
+func TestParserTracing(t *testing.T) {
+	var events []TraceEvent
+	p := New(lexer.New("-1 + 2;"), WithTracer(func(ev TraceEvent) {
+		events = append(events, ev)
+	}))
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(events) == 0 || len(events)%2 != 0 {
+		t.Fatalf("wrong number of trace events. got=%d", len(events))
+	}
+	first := events[0]
+	if first.Phase != TraceBegin || first.Function != "parseExpressionStatement" || first.Depth != 1 {
+		t.Fatalf("first event wrong. got=%+v", first)
+	}
+
+	depth := 0
+	maxDepth := 0
+	for _, ev := range events {
+		if ev.Phase == TraceBegin {
+			depth++
+			if ev.Depth != depth {
+				t.Fatalf("event %s depth wrong. want=%d, got=%d", ev.Function, depth, ev.Depth)
+			}
+		} else {
+			if ev.Depth != depth {
+				t.Fatalf("event %s depth wrong. want=%d, got=%d", ev.Function, depth, ev.Depth)
+			}
+			depth--
+		}
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+	}
+	if depth != 0 {
+		t.Fatalf("BEGIN/END not balanced. depth=%d", depth)
+	}
+	if maxDepth < 4 { //语句->表达式->中缀->表达式->整数
+		t.Fatalf("trace too shallow. got=%d", maxDepth)
+	}
+
+	var buf bytes.Buffer
+	p = New(lexer.New("5;"), WithTraceWriter(&buf))
+	p.ParseProgram()
+	expected := "BEGIN parseExpressionStatement\n" +
+		"\tBEGIN parseExpression\n" +
+		"\t\tBEGIN parseIntegerLiteral\n" +
+		"\t\tEND parseIntegerLiteral\n" +
+		"\tEND parseExpression\n" +
+		"END parseExpressionStatement\n"
+	if buf.String() != expected {
+		t.Fatalf("trace output wrong. want=%q, got=%q", expected, buf.String())
+	}
+}
+
+/* 测试宏字面量 macro(x, y) { x + y; } */
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T",
+			stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n",
+			len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n",
+			len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T",
+			macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+// 类型注解：let、函数参数和返回类型
+func TestTypeAnnotationParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"let x = 5;", "let x = 5;"},
+		{"fn(a: int, b) -> bool { a }", "fn(a: int, b） -> bool a"},
+		{"let f: fn(int, int) -> int = g;", "let f: fn(int, int) -> int = g;"},
+		{"let f: fn() -> fn(bool) -> int = g;", "let f: fn() -> fn(bool) -> int = g;"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, got)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: = 5;", "expected type, got = instead"},
+		{"fn(a: 5) { a }", "expected type, got INT instead"},
+		{"let f: fn(int) int = g;", "expected next token to be ->, got IDENT instead"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%s: expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x 5;", []string{"1:7: expected next token to be =, got INT instead"}},
+		{"let f = fn(a) {\n  a +;\n};", []string{"2:6: no prefix parse function for ; found"}},
+		{"let x: 5 = 1;", []string{"1:8: expected type, got INT instead"}},
+		{"99999999999999999999", []string{"1:1: could not parse \"99999999999999999999\" as integer"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		var got []string
+		for _, e := range p.ErrorList() {
+			got = append(got, e.String())
+		}
+		if len(got) < len(tt.expected) {
+			t.Errorf("%q: expected errors %q, got %q", tt.input, tt.expected, got)
+			continue
+		}
+		for i, want := range tt.expected {
+			if got[i] != want {
+				t.Errorf("%q: error %d wrong. want=%q, got=%q", tt.input, i, want, got[i])
+			}
+		}
+	}
+}
+
+// 解析失败的let语句不加入ast，Program和BlockStatement中没有值为nil的*ast.LetStatement
+func TestFailedStatementsDropped(t *testing.T) {
+	tests := []struct {
+		input      string
+		statements int //Program中的语句数
+		block      int //函数体中的语句数，-1表示没有函数
+	}{
+		{"let", 0, -1},
+		{"let x 5; x", 2, -1}, //let失败后 5; 和 x 各是一个表达式语句
+		{"export let", 0, -1},
+		{"let f = fn(x) {\n let", 1, 0},
+		{"let f = fn(x) { let 1; x }", 1, 2},
+	}
+
+	checkNil := func(input string, stmts []ast.Statement) {
+		for _, s := range stmts {
+			if ls, ok := s.(*ast.LetStatement); s == nil || ok && ls == nil {
+				t.Errorf("%q: nil statement in the ast", input)
+			}
+		}
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected parse errors", tt.input)
+		}
+		if len(program.Statements) != tt.statements {
+			t.Errorf("%q: wrong number of statements. want=%d, got=%d", tt.input, tt.statements, len(program.Statements))
+		}
+		checkNil(tt.input, program.Statements)
+		ast.Inspect(program, func(n ast.Node) bool {
+			if fn, ok := n.(*ast.FunctionLiteral); ok {
+				checkNil(tt.input, fn.Body.Statements)
+				if len(fn.Body.Statements) != tt.block {
+					t.Errorf("%q: wrong number of statements in the body. want=%d, got=%d", tt.input, tt.block, len(fn.Body.Statements))
+				}
+			}
+			return true
+		})
+	}
+}
+
+func TestImportExportParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/math"`, `import "lib/math";`},
+		{`import m "lib/math"; m.sqrt(4)`, `import m "lib/math";m.sqrt(4)`},
+		{"export let x = 5;", "export let x = 5;"},
+		{"a.b.c(1) + 2", "(a.b.c(1) + 2)"},
+		{"-m.x", "(-m.x)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, got)
+		}
+	}
+
+	p := New(lexer.New(`import m "lib/math"`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	imp, ok := program.Statements[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ImportStatement. got=%T", program.Statements[0])
+	}
+	if imp.Value != "lib/math" || imp.Binding() != "m" {
+		t.Errorf("wrong import. path=%q, binding=%q", imp.Value, imp.Binding())
+	}
+	if imp.Name = nil; imp.Binding() != "math" {
+		t.Errorf("binding without alias wrong. got=%q", imp.Binding())
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"fn() { import \"a\" }", "1:8: import is only allowed at top level"},
+		{"if (x) { export let y = 1; }", "1:10: export is only allowed at top level"},
+		{"import a;", "1:9: expected next token to be STRING, got ; instead"},
+		{`import ""`, "1:8: empty import path"},
+		{"export x", "1:8: expected next token to be LET, got IDENT instead"},
+		{"m.1", "1:3: expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		list := p.ErrorList()
+		if len(list) == 0 || list[0].String() != tt.expected {
+			t.Errorf("%s: expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestStringsAndArraysParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"hello world"`, `"hello world"`},
+		{`[1, 2 * 2, "a"]`, `[1, (2 * 2), "a"]`},
+		{"[]", "[]"},
+		{"a * [1, 2, 3][b * c] * d", "((a * ([1, 2, 3][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"m.xs[0]", "(m.xs[0])"},
+		{"f(x)[0]", "(f(x)[0])"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, got)
+		}
+	}
+
+	p := New(lexer.New(`"a\tb"`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	str, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if str.Value != "a\tb" {
+		t.Errorf("str.Value not %q. got=%q", "a\tb", str.Value)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\qb"`, `1:1: unknown escape sequence \q`},
+		{"[1, 2;", "1:6: expected next token to be ], got ; instead"},
+		{"xs[1;", "1:5: expected next token to be ], got ; instead"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		list := p.ErrorList()
+		if len(list) == 0 || list[0].String() != tt.expected {
+			t.Errorf("%s: expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
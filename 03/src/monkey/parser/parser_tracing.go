@@ -1,33 +1,73 @@
 package parser
 
-//提供普拉特解析执行时各个函数生命周期的追踪查看
+//提供普拉特解析执行时各个函数生命周期的追踪查看，默认关闭，通过Option按Parser开启
 import (
 	"fmt"
+	"io"
+	"monkey/token"
 	"strings"
 )
 
-var traceLevel int = 0
+// TracePhase 追踪事件发生在解析函数进入还是退出时
+type TracePhase int
 
-const traceIdentPlaceholder string = "\t"
+const (
+	TraceBegin TracePhase = iota
+	TraceEnd
+)
+
+func (ph TracePhase) String() string {
+	if ph == TraceBegin {
+		return "BEGIN"
+	}
+	return "END"
+}
 
-func identLevel() string {
-	return strings.Repeat(traceIdentPlaceholder, traceLevel-1)
+// TraceEvent 一次解析函数进入或退出的结构化追踪事件
+type TraceEvent struct {
+	Phase      TracePhase
+	Function   string      //解析函数名，例：parseExpression
+	Token      token.Token //事件发生时的当前词法单元
+	Precedence int         //该解析函数使用的优先级
+	Depth      int         //嵌套深度，从1开始
 }
 
-func tracePrint(fs string) {
-	fmt.Printf("%s%s\n", identLevel(), fs)
+// Option 语法分析器的可选配置，传给New
+type Option func(*Parser)
+
+// WithTracer 每次进入/退出解析函数时回调fn
+func WithTracer(fn func(TraceEvent)) Option {
+	return func(p *Parser) { p.tracer = fn }
+}
+
+// WithTraceWriter 以缩进的 BEGIN/END 文本格式把追踪输出写入w
+func WithTraceWriter(w io.Writer) Option {
+	return WithTracer(func(ev TraceEvent) {
+		fmt.Fprintf(w, "%s%s %s\n", strings.Repeat(traceIdentPlaceholder, ev.Depth-1), ev.Phase, ev.Function)
+	})
 }
 
-func incIdent() { traceLevel = traceLevel + 1 }
-func decIdent() { traceLevel = traceLevel - 1 }
+const traceIdentPlaceholder string = "\t"
 
-func trace(msg string) string {
-	incIdent()
-	tracePrint("BEGIN " + msg)
-	return msg
+// 进入解析函数，返回的事件交给untrace，用法：defer p.untrace(p.trace("parseExpression", precedence))
+func (p *Parser) trace(fn string, precedence int) TraceEvent {
+	ev := TraceEvent{Phase: TraceBegin, Function: fn, Precedence: precedence}
+	if p.tracer == nil { //未开启追踪时不产生任何开销
+		return ev
+	}
+	p.traceLevel = p.traceLevel + 1
+	ev.Token = p.curToken
+	ev.Depth = p.traceLevel
+	p.tracer(ev)
+	return ev
 }
 
-func untrace(msg string) {
-	tracePrint("END " + msg)
-	decIdent()
+func (p *Parser) untrace(ev TraceEvent) {
+	if p.tracer == nil {
+		return
+	}
+	ev.Phase = TraceEnd
+	ev.Token = p.curToken
+	p.tracer(ev)
+	p.traceLevel = p.traceLevel - 1
 }
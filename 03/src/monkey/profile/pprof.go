@@ -0,0 +1,191 @@
+package profile
+
+// 输出pprof使用的profile.proto格式（gzip压缩的protobuf），可以用 go tool pprof 查看。
+// 格式见 https://github.com/google/pprof/blob/main/proto/profile.proto
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// profile.proto中用到的字段编号
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+	profileDefaultType   = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// 每个sample的值：调用次数、自身时间（插桩统计），采样次数、采样时间
+var sampleTypes = [][2]string{
+	{"calls", "count"},
+	{"time", "nanoseconds"},
+	{"samples", "count"},
+	{"cpu", "nanoseconds"},
+}
+
+// WriteProfile 以pprof格式输出，默认的sample类型是time
+func (p *Profiler) WriteProfile(w io.Writer) error {
+	index := map[string]int64{"": 0}
+	table := []string{""}
+	str := func(s string) int64 {
+		if i, ok := index[s]; ok {
+			return i
+		}
+		index[s] = int64(len(table))
+		table = append(table, s)
+		return index[s]
+	}
+
+	var b protobuf
+	for _, st := range sampleTypes {
+		b.message(profileSampleType, func(b *protobuf) {
+			b.int64(valueTypeType, str(st[0]))
+			b.int64(valueTypeUnit, str(st[1]))
+		})
+	}
+
+	//每个不同的（函数，行）是一个location
+	locIDs := map[location]uint64{}
+	var locs []location
+	keys := make([]string, 0, len(p.stacks))
+	for key := range p.stacks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := p.stacks[key]
+		ids := make([]uint64, len(s.locs))
+		for i, l := range s.locs {
+			if locIDs[l] == 0 {
+				locs = append(locs, l)
+				locIDs[l] = uint64(len(locs))
+			}
+			ids[i] = locIDs[l]
+		}
+		b.message(profileSample, func(b *protobuf) {
+			b.packed(sampleLocationID, ids)
+			b.packed(sampleValue, []uint64{
+				uint64(s.calls), uint64(s.nanos),
+				uint64(s.samples), uint64(s.samples * int64(samplePeriod)),
+			})
+		})
+	}
+	for i, l := range locs {
+		b.message(profileLocation, func(b *protobuf) {
+			b.uint64(locationID, uint64(i+1))
+			b.message(locationLine, func(b *protobuf) {
+				b.uint64(lineFunctionID, l.fn.id)
+				b.int64(lineLine, int64(l.line))
+			})
+		})
+	}
+	for _, fn := range p.order {
+		b.message(profileFunction, func(b *protobuf) {
+			b.uint64(functionID, fn.id)
+			b.int64(functionName, str(fn.Name))
+			b.int64(functionSystemName, str(fn.Name))
+			b.int64(functionFilename, str(p.File))
+			b.int64(functionStartLine, int64(fn.Pos.Line))
+		})
+	}
+
+	b.int64(profileTimeNanos, p.start.UnixNano())
+	b.int64(profileDurationNanos, int64(p.duration))
+	b.message(profilePeriodType, func(b *protobuf) {
+		b.int64(valueTypeType, str("cpu"))
+		b.int64(valueTypeUnit, str("nanoseconds"))
+	})
+	b.int64(profilePeriod, int64(samplePeriod))
+	b.int64(profileDefaultType, str("time"))
+	for _, s := range table { //string_table放在最后，上面的字符串都已加入
+		b.string(profileStringTable, s)
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.buf); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// 只支持profile.proto用到的几种字段的protobuf编码
+type protobuf struct {
+	buf []byte
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.buf = append(b.buf, byte(x)|0x80)
+		x >>= 7
+	}
+	b.buf = append(b.buf, byte(x))
+}
+
+// 字段头：编号和类型，0是varint，2是带长度的字节串
+func (b *protobuf) key(tag int, wireType int) {
+	b.varint(uint64(tag)<<3 | uint64(wireType))
+}
+
+// 零值是默认值，不输出
+func (b *protobuf) uint64(tag int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.key(tag, 0)
+	b.varint(x)
+}
+
+func (b *protobuf) int64(tag int, x int64) {
+	b.uint64(tag, uint64(x))
+}
+
+// 字符串表中的空字符串也要输出，不能省略
+func (b *protobuf) string(tag int, s string) {
+	b.key(tag, 2)
+	b.varint(uint64(len(s)))
+	b.buf = append(b.buf, s...)
+}
+
+func (b *protobuf) packed(tag int, xs []uint64) {
+	var inner protobuf
+	for _, x := range xs {
+		inner.varint(x)
+	}
+	b.key(tag, 2)
+	b.varint(uint64(len(inner.buf)))
+	b.buf = append(b.buf, inner.buf...)
+}
+
+func (b *protobuf) message(tag int, f func(b *protobuf)) {
+	var inner protobuf
+	f(&inner)
+	b.key(tag, 2)
+	b.varint(uint64(len(inner.buf)))
+	b.buf = append(b.buf, inner.buf...)
+}
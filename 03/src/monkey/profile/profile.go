@@ -0,0 +1,228 @@
+package profile
+
+// Monkey程序的性能分析器，作为evaluator.Hooks安装到解释器上。
+// 统计每个Monkey函数（以绑定名和源码位置区分）的调用次数、自身时间（flat）和累计时间（cum），
+// 同时按固定周期对当前的调用栈采样。结果可以输出为pprof格式（见pprof.go）或文本报告（见report.go）
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/token"
+	"strings"
+	"time"
+)
+
+const (
+	samplePeriod = time.Millisecond //采样周期
+	sampleCheck  = 256              //每求值这么多个节点检查一次是否到了采样时间，避免每步都读时钟
+)
+
+// Func 被分析的函数
+type Func struct {
+	Name string         //let绑定的名字，匿名函数为anonymous，内置函数为其名字，其他被调用对象为被调用表达式的源码
+	Pos  token.Position //函数字面量的位置
+	id   uint64         //pprof中的编号，从1开始
+}
+
+// Stats 函数的统计结果
+type Stats struct {
+	Func  *Func
+	Calls int64
+	Flat  time.Duration //函数自身的时间，不含调用的其他函数
+	Cum   time.Duration //从调用到返回的时间，递归调用只计算最外层
+}
+
+// 调用栈中的一层
+type frame struct {
+	fn    *Func
+	call  token.Position //调用处的位置
+	start time.Time
+	child time.Duration //调用其他函数用的时间
+	stack *stack        //以该层为栈顶的调用栈
+}
+
+// 一种不同的调用栈，pprof中的一个sample
+type stack struct {
+	locs    []location //栈顶在前
+	calls   int64
+	nanos   int64 //栈顶函数在该调用栈中的自身时间
+	samples int64
+}
+
+// 调用栈中的一个位置：函数和其中的行
+type location struct {
+	fn   *Func
+	line int
+}
+
+// Profiler 性能分析器，用evaluator.WithHooks安装，求值前后分别调用Start、Stop。
+// 同一时间只能分析一次求值
+type Profiler struct {
+	evaluator.NopHooks
+
+	File string //被分析的源码文件名，输出时使用
+
+	now     func() time.Time
+	funcs   map[*ast.BlockStatement]*Func //函数字面量（以函数体区分）对应的Func
+	callees map[string]*Func              //不是Monkey函数的被调用对象
+	order   []*Func
+	stats   map[*Func]*Stats
+	active  map[*Func]int //正在执行的层数，用于递归时只计算一次cum
+	stacks  map[string]*stack
+	frames  []*frame
+
+	nodes      int64     //已求值的节点数
+	nextSample time.Time //下一次采样的时间
+	start      time.Time
+	duration   time.Duration
+}
+
+// New 创建性能分析器，file是被分析的源码文件名
+func New(file string) *Profiler {
+	p := &Profiler{
+		File:    file,
+		now:     time.Now,
+		funcs:   map[*ast.BlockStatement]*Func{},
+		callees: map[string]*Func{},
+		stats:   map[*Func]*Stats{},
+		active:  map[*Func]int{},
+		stacks:  map[string]*stack{},
+	}
+	return p
+}
+
+// Start 开始计时和采样，顶层程序作为调用栈的最外层program
+func (p *Profiler) Start() {
+	p.start = p.now()
+	p.nextSample = p.start.Add(samplePeriod)
+	root := p.newFunc("program", token.Position{Line: 1, Column: 1})
+	p.push(root, token.Position{})
+}
+
+// Stop 停止计时和采样
+func (p *Profiler) Stop() {
+	for len(p.frames) > 0 { //求值被中止时可能还有未返回的调用
+		p.pop()
+	}
+	p.duration = p.now().Sub(p.start)
+}
+
+func (p *Profiler) newFunc(name string, pos token.Position) *Func {
+	fn := &Func{Name: name, Pos: pos, id: uint64(len(p.order) + 1)}
+	p.order = append(p.order, fn)
+	p.stats[fn] = &Stats{Func: fn}
+	return fn
+}
+
+// 采样，记录函数字面量的名字和位置
+func (p *Profiler) EnterNode(node ast.Node, env *object.Environment) {
+	p.nodes++
+	if p.nodes%sampleCheck == 0 {
+		p.sample()
+	}
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		if fl, ok := node.Value.(*ast.FunctionLiteral); ok && p.funcs[fl.Body] == nil {
+			p.funcs[fl.Body] = p.newFunc(node.Name.Value, fl.Pos())
+		}
+	case *ast.FunctionLiteral:
+		if p.funcs[node.Body] == nil {
+			p.funcs[node.Body] = p.newFunc("anonymous", node.Pos())
+		}
+	}
+}
+
+// 距上次采样过去的每个周期都记为当前调用栈的一次采样
+func (p *Profiler) sample() {
+	if len(p.frames) == 0 {
+		return
+	}
+	top := p.frames[len(p.frames)-1].stack
+	for now := p.now(); !now.Before(p.nextSample); p.nextSample = p.nextSample.Add(samplePeriod) {
+		top.samples++
+	}
+}
+
+func (p *Profiler) Call(call *ast.CallExpression, fn object.Object, args []object.Object) {
+	p.push(p.funcOf(call, fn), call.Pos())
+}
+
+func (p *Profiler) Return(call *ast.CallExpression, fn object.Object, result object.Object) {
+	p.pop()
+}
+
+// 被调用对象对应的Func
+func (p *Profiler) funcOf(call *ast.CallExpression, obj object.Object) *Func {
+	if fn, ok := obj.(*object.Function); ok {
+		if f := p.funcs[fn.Body]; f != nil {
+			return f
+		}
+		f := p.newFunc("anonymous", fn.Body.Pos()) //开始分析之前创建的函数
+		p.funcs[fn.Body] = f
+		return f
+	}
+	name := call.Function.String()
+	if builtin, ok := obj.(*object.Builtin); ok {
+		name = builtin.Name
+	}
+	if f := p.callees[name]; f != nil {
+		return f
+	}
+	f := p.newFunc(name, token.Position{})
+	p.callees[name] = f
+	return f
+}
+
+func (p *Profiler) push(fn *Func, call token.Position) {
+	f := &frame{fn: fn, call: call, start: p.now()}
+
+	//调用栈：新的一层在函数开头，外面各层在各自的调用处
+	locs := []location{{fn, fn.Pos.Line}}
+	for i := len(p.frames) - 1; i >= 0; i-- {
+		line := call.Line
+		call = p.frames[i].call
+		locs = append(locs, location{p.frames[i].fn, line})
+	}
+	var key strings.Builder
+	for _, l := range locs {
+		fmt.Fprintf(&key, "%d:%d/", l.fn.id, l.line)
+	}
+	f.stack = p.stacks[key.String()]
+	if f.stack == nil {
+		f.stack = &stack{locs: locs}
+		p.stacks[key.String()] = f.stack
+	}
+
+	f.stack.calls++
+	p.stats[fn].Calls++
+	p.active[fn]++
+	p.frames = append(p.frames, f)
+}
+
+func (p *Profiler) pop() {
+	f := p.frames[len(p.frames)-1]
+	p.frames = p.frames[:len(p.frames)-1]
+
+	elapsed := p.now().Sub(f.start)
+	stats := p.stats[f.fn]
+	stats.Flat += elapsed - f.child
+	f.stack.nanos += int64(elapsed - f.child)
+	p.active[f.fn]--
+	if p.active[f.fn] == 0 {
+		stats.Cum += elapsed
+	}
+	if len(p.frames) > 0 {
+		p.frames[len(p.frames)-1].child += elapsed
+	}
+}
+
+// Stats 每个函数的统计结果，按创建的顺序
+func (p *Profiler) Stats() []*Stats {
+	result := make([]*Stats, 0, len(p.order))
+	for _, fn := range p.order {
+		result = append(result, p.stats[fn])
+	}
+	return result
+}
@@ -0,0 +1,157 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"monkey/evaluator"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSource = `let sq = fn(x) { x * x };
+let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };
+sq(3) + fact(3) + fn(y) { y }(1)`
+
+// 每读一次时钟前进1ms，使时间可以预测
+func run(t *testing.T, src string) *Profiler {
+	t.Helper()
+	p := New("test.mk")
+	clock := time.Unix(0, 0)
+	p.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+	p.Start()
+	result, err := evaluator.New(evaluator.WithHooks(p)).Run(src)
+	p.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inspect() != "16" {
+		t.Fatalf("result = %s", result.Inspect())
+	}
+	return p
+}
+
+func TestStats(t *testing.T) {
+	p := run(t, testSource)
+	expected := []struct {
+		name      string
+		line      int
+		calls     int64
+		flat, cum time.Duration
+	}{
+		{"program", 1, 1, 4 * time.Millisecond, 11 * time.Millisecond},
+		{"sq", 1, 1, time.Millisecond, time.Millisecond},
+		{"fact", 2, 3, 5 * time.Millisecond, 5 * time.Millisecond}, //递归调用只计算最外层的cum
+		{"anonymous", 3, 1, time.Millisecond, time.Millisecond},
+	}
+	stats := p.Stats()
+	if len(stats) != len(expected) {
+		t.Fatalf("got %d functions, want %d", len(stats), len(expected))
+	}
+	for i, tt := range expected {
+		s := stats[i]
+		if s.Func.Name != tt.name || s.Func.Pos.Line != tt.line || s.Calls != tt.calls || s.Flat != tt.flat || s.Cum != tt.cum {
+			t.Errorf("stats[%d] = %s at line %d: calls=%d flat=%s cum=%s, want %+v",
+				i, s.Func.Name, s.Func.Pos.Line, s.Calls, s.Flat, s.Cum, tt)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := run(t, testSource).WriteReport(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[0] != "Total: 13ms, 5 calls" {
+		t.Errorf("header = %q", lines[0])
+	}
+	if len(lines) != 6 {
+		t.Fatalf("report:\n%s", buf.String())
+	}
+	//按flat从多到少
+	for i, name := range []string{"fact test.mk:2:12", "program test.mk:1:1", "sq test.mk:1:10", "anonymous test.mk:3:19"} {
+		if !strings.HasSuffix(lines[i+2], " "+name) {
+			t.Errorf("line %d = %q, want function %s", i+2, lines[i+2], name)
+		}
+	}
+	if fields := strings.Fields(lines[2]); strings.Join(fields[:6], " ") != "5ms 38.46% 38.46% 5ms 38.46% 3" {
+		t.Errorf("fact row = %q", lines[2])
+	}
+}
+
+// 读出pprof输出中的字符串表和sample数量，检查编码是否正确
+func TestWriteProfile(t *testing.T) {
+	var buf bytes.Buffer
+	if err := run(t, testSource).WriteProfile(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var table []string
+	samples := 0
+	for len(data) > 0 {
+		key, n := uvarint(data)
+		data = data[n:]
+		switch key & 7 {
+		case 0:
+			_, n = uvarint(data)
+			data = data[n:]
+		case 2:
+			size, n := uvarint(data)
+			field := data[n : n+int(size)]
+			data = data[n+int(size):]
+			switch key >> 3 {
+			case profileStringTable:
+				table = append(table, string(field))
+			case profileSample:
+				samples++
+			}
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	if len(table) == 0 || table[0] != "" {
+		t.Fatalf("string table must start with an empty string: %q", table)
+	}
+	for _, s := range []string{"program", "sq", "fact", "anonymous", "test.mk", "calls", "time", "nanoseconds"} {
+		if !contains(table, s) {
+			t.Errorf("string table %q is missing %q", table, s)
+		}
+	}
+	//不同的调用栈：program、sq、fact×3层、anonymous
+	if samples != 6 {
+		t.Errorf("got %d samples, want 6", samples)
+	}
+}
+
+func uvarint(b []byte) (uint64, int) {
+	var x uint64
+	for i, c := range b {
+		x |= uint64(c&0x7f) << (7 * i)
+		if c < 0x80 {
+			return x, i + 1
+		}
+	}
+	return 0, len(b)
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
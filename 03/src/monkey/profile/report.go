@@ -0,0 +1,58 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// WriteReport 输出文本报告：按自身时间从多到少列出每个被调用过的函数，格式类似 go tool pprof -top
+func (p *Profiler) WriteReport(w io.Writer) error {
+	var stats []*Stats
+	var calls int64
+	for _, s := range p.Stats() {
+		if s.Calls > 0 {
+			stats = append(stats, s)
+			calls += s.Calls
+		}
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Flat != stats[j].Flat {
+			return stats[i].Flat > stats[j].Flat
+		}
+		return stats[i].Cum > stats[j].Cum
+	})
+
+	fmt.Fprintf(w, "Total: %s, %d calls\n", round(p.duration), calls-1) //不计program
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "flat\tflat%\tsum%\tcum\tcum%\tcalls\t function")
+	var sum time.Duration
+	for _, s := range stats {
+		sum += s.Flat
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t %s\n",
+			round(s.Flat), percent(s.Flat, p.duration), percent(sum, p.duration),
+			round(s.Cum), percent(s.Cum, p.duration), s.Calls, p.funcName(s.Func))
+	}
+	return tw.Flush()
+}
+
+// 函数名和位置，例：fib fib.mk:1:11
+func (p *Profiler) funcName(fn *Func) string {
+	if fn.Pos.Line == 0 {
+		return fn.Name
+	}
+	return fmt.Sprintf("%s %s:%s", fn.Name, p.File, fn.Pos)
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
+
+func percent(d, total time.Duration) string {
+	if total <= 0 {
+		return "0.00%"
+	}
+	return fmt.Sprintf("%.2f%%", float64(d)*100/float64(total))
+}
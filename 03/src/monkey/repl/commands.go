@@ -0,0 +1,166 @@
+package repl
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// REPL命令，输入以冒号开头，例：:ast 1 + 2
+type command struct {
+	name  string
+	args  string //参数说明，用于:help
+	help  string
+	run   func(s *session, arg string) //arg是命令名之后的整行内容，已去掉首尾空白
+	noArg bool                         //命令不接受参数
+	files bool                         //命令读写本地文件，WithoutFileAccess时禁用
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: ":tokens", args: "expr", help: "输出表达式的词法单元", run: (*session).cmdTokens},
+		{name: ":ast", args: "expr", help: "以树形输出表达式的语法树", run: (*session).cmdAST},
+		{name: ":env", help: "列出全局环境中的绑定及其类型", run: (*session).cmdEnv, noArg: true},
+		{name: ":load", args: "file.mk", help: "在当前会话中执行文件", run: (*session).cmdLoad, files: true},
+		{name: ":save", args: "session.mk", help: "把本次会话中求值成功的输入保存到文件", run: (*session).cmdSave, files: true},
+		{name: ":reset", help: "清空全局环境、宏定义和会话记录", run: (*session).cmdReset, noArg: true},
+		{name: ":time", args: "expr", help: "求值并输出耗时", run: (*session).cmdTime},
+		{name: ":break", args: "[N [if cond] | clear [N]]", help: "列出、设置或删除断点（行号从输入的第一行算起）", run: (*session).cmdBreak},
+		{name: ":step", args: "expr", help: "单步执行表达式，在调试提示符下输入help查看调试命令", run: (*session).cmdStep},
+		{name: ":watch", args: "expr", help: "添加调试暂停时输出的监视表达式", run: (*session).cmdWatch},
+		{name: ":trace", args: "on|off", help: "是否输出语法解析过程", run: (*session).cmdTrace},
+		{name: ":help", help: "列出全部命令", run: (*session).cmdHelp, noArg: true},
+	}
+}
+
+func (c *command) usage() string {
+	if c.args == "" {
+		return c.name
+	}
+	return c.name + " " + c.args
+}
+
+// 执行REPL命令，例：:trace on
+func (s *session) command(line string) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		if c.files && s.noFiles {
+			fmt.Fprintf(s.out, "command %s is disabled in this session\n", c.name)
+			return
+		}
+		if (c.noArg && arg != "") || (c.args != "" && !strings.HasPrefix(c.args, "[") && arg == "") { //[...]表示参数可选
+			fmt.Fprintf(s.out, "usage: %s\n", c.usage())
+			return
+		}
+		c.run(s, arg)
+		return
+	}
+	fmt.Fprintf(s.out, "unknown command %s, type :help for a list of commands\n", name)
+}
+
+func (s *session) cmdTokens(arg string) {
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POS\tTYPE\tLITERAL")
+	for _, tok := range lexer.Tokenize(arg) {
+		fmt.Fprintf(w, "%s\t%s\t%q\n", tok.Pos, tok.Type, tok.Literal)
+	}
+	w.Flush()
+}
+
+func (s *session) cmdAST(arg string) {
+	p := parser.New(lexer.New(arg))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return
+	}
+	var node ast.Node = program
+	if len(program.Statements) == 1 { //只有一条语句时省略Program
+		node = program.Statements[0]
+	}
+	ast.Fprint(s.out, node)
+}
+
+func (s *session) cmdEnv(string) {
+	env := s.interp.Env()
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tVALUE")
+	for _, name := range env.Names() {
+		val, ok := env.Get(name)
+		if !ok || val == nil {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, val.Type(), summary(val.Inspect()))
+	}
+	w.Flush()
+}
+
+// 多行的值（例：函数）压缩成一行，过长时截断
+func summary(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > 60 {
+		s = string(r[:57]) + "..."
+	}
+	return s
+}
+
+func (s *session) cmdLoad(path string) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	s.eval(string(src))
+}
+
+func (s *session) cmdSave(path string) {
+	var out strings.Builder
+	for _, input := range s.inputs {
+		out.WriteString(strings.TrimRight(input, "\n"))
+		out.WriteString("\n")
+	}
+	if err := os.WriteFile(path, []byte(out.String()), 0644); err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	fmt.Fprintf(s.out, "saved %d inputs to %s\n", len(s.inputs), path)
+}
+
+func (s *session) cmdReset(string) {
+	s.interp = s.newInterpreter()
+	s.inputs = nil
+	fmt.Fprintln(s.out, "environment cleared")
+}
+
+func (s *session) cmdTime(arg string) {
+	start := time.Now()
+	s.eval(arg)
+	fmt.Fprintf(s.out, "耗时: %s\n", time.Since(start))
+}
+
+func (s *session) cmdTrace(arg string) {
+	if arg != "on" && arg != "off" {
+		fmt.Fprintln(s.out, "usage: :trace on|off")
+		return
+	}
+	s.trace = arg == "on"
+}
+
+func (s *session) cmdHelp(string) {
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(w, "%s\t%s\n", c.usage(), c.help)
+	}
+	w.Flush()
+}
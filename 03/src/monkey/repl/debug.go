@@ -0,0 +1,180 @@
+package repl
+
+import (
+	"fmt"
+	"monkey/debugger"
+	"monkey/format"
+	"monkey/object"
+	"strconv"
+	"strings"
+)
+
+const DEBUG_PROMPT = "(dbg) "
+
+// 调试提示符下的命令
+const debugHelp = `s, step          执行到下一条语句，进入被调用的函数
+n, next          执行到当前函数的下一条语句
+o, out           执行到当前函数返回
+c, continue      运行到下一个断点
+p, print expr    在当前环境中对表达式求值
+w, watch expr    添加监视表达式，每次暂停时输出
+env              输出当前的环境链
+bt, stack        输出调用栈
+b, break N [if cond]  在第N行设置断点
+q, quit          停止求值
+`
+
+// 调试器暂停时进入调试提示符，读取命令直到继续执行
+func (s *session) onStop(stop *debugger.Stop) debugger.Action {
+	frame := stop.Frames[len(stop.Frames)-1]
+	fmt.Fprintf(s.out, "stopped at %s (%s) in %s: %s\n", stop.Pos, stop.Reason, frame.Name, firstLine(format.Node(stop.Stmt)))
+	if stop.Err != nil {
+		fmt.Fprintf(s.out, "  %s\n", stop.Err)
+	}
+	for _, w := range s.dbg.Watches() {
+		s.printValue("  "+w+" = ", w, stop.Env)
+	}
+
+	for {
+		line, err := s.lines.ReadLine(DEBUG_PROMPT)
+		if err != nil {
+			return debugger.Abort
+		}
+		name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		arg = strings.TrimSpace(arg)
+		switch name {
+		case "":
+		case "s", "step":
+			return debugger.StepIn
+		case "n", "next":
+			return debugger.StepOver
+		case "o", "out":
+			return debugger.StepOut
+		case "c", "continue":
+			return debugger.Continue
+		case "q", "quit":
+			return debugger.Abort
+		case "p", "print":
+			s.printValue("", arg, stop.Env)
+		case "w", "watch":
+			if err := s.dbg.AddWatch(arg); err != nil {
+				fmt.Fprintln(s.out, err)
+				continue
+			}
+			s.printValue("  "+arg+" = ", arg, stop.Env)
+		case "env":
+			s.printEnv(stop.Env)
+		case "bt", "stack":
+			for i := len(stop.Frames) - 1; i >= 0; i-- {
+				f := stop.Frames[i]
+				if i == 0 {
+					fmt.Fprintf(s.out, "#%d %s\n", i, f.Name)
+				} else {
+					fmt.Fprintf(s.out, "#%d %s called at %s\n", i, f.Name, f.Call)
+				}
+			}
+		case "b", "break":
+			s.setBreakpoint(arg)
+		case "h", "help":
+			fmt.Fprint(s.out, debugHelp)
+		default:
+			fmt.Fprintf(s.out, "unknown debugger command %s, type help for a list of commands\n", name)
+		}
+	}
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " ..."
+	}
+	return s
+}
+
+func (s *session) printValue(prefix, expr string, env *object.Environment) {
+	if expr == "" {
+		fmt.Fprintln(s.out, "usage: print expr")
+		return
+	}
+	val, err := debugger.Eval(expr, env)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	fmt.Fprintf(s.out, "%s%s\n", prefix, summary(val.Inspect()))
+}
+
+// 从当前环境往外逐层输出绑定，最外层是全局环境
+func (s *session) printEnv(env *object.Environment) {
+	for level := 0; env != nil; level, env = level+1, env.Outer() {
+		if env.Outer() == nil {
+			fmt.Fprintln(s.out, "globals:")
+		} else {
+			fmt.Fprintf(s.out, "#%d locals:\n", level)
+		}
+		for _, v := range debugger.Variables(env) {
+			fmt.Fprintf(s.out, "  %s = %s\n", v.Name, summary(v.Value.Inspect()))
+		}
+	}
+}
+
+// 设置断点，arg为 N 或 N if cond
+func (s *session) setBreakpoint(arg string) {
+	lineText, cond, _ := strings.Cut(arg, " ")
+	line, err := strconv.Atoi(lineText)
+	cond = strings.TrimSpace(cond)
+	if err != nil || (cond != "" && !strings.HasPrefix(cond, "if ")) {
+		fmt.Fprintln(s.out, "usage: :break N [if cond]")
+		return
+	}
+	bp, err := s.dbg.SetBreakpoint(line, strings.TrimPrefix(cond, "if "))
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	fmt.Fprintf(s.out, "breakpoint at line %d%s\n", bp.Line, breakpointCondition(bp))
+}
+
+func breakpointCondition(bp *debugger.Breakpoint) string {
+	if bp.Condition == "" {
+		return ""
+	}
+	return " if " + bp.Condition
+}
+
+// :break 列出断点；:break N [if cond] 设置断点；:break clear [N] 删除断点
+func (s *session) cmdBreak(arg string) {
+	switch {
+	case arg == "":
+		list := s.dbg.Breakpoints()
+		if len(list) == 0 {
+			fmt.Fprintln(s.out, "no breakpoints")
+		}
+		for _, bp := range list {
+			fmt.Fprintf(s.out, "line %d%s (hits: %d)\n", bp.Line, breakpointCondition(bp), bp.Hits)
+		}
+	case arg == "clear":
+		s.dbg.ClearBreakpoint(0)
+	case strings.HasPrefix(arg, "clear "):
+		line, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(arg, "clear ")))
+		if err != nil {
+			fmt.Fprintln(s.out, "usage: :break clear [N]")
+			return
+		}
+		s.dbg.ClearBreakpoint(line)
+	default:
+		s.setBreakpoint(arg)
+	}
+}
+
+// :step expr 从第一条语句开始单步执行
+func (s *session) cmdStep(arg string) {
+	s.dbg.Pause("entry")
+	s.eval(arg)
+}
+
+// :watch expr 添加监视表达式
+func (s *session) cmdWatch(arg string) {
+	if err := s.dbg.AddWatch(arg); err != nil {
+		fmt.Fprintln(s.out, err)
+	}
+}
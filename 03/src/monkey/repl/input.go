@@ -0,0 +1,40 @@
+package repl
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+)
+
+const CONTINUE_PROMPT = ".. " //输入未完成时的续行提示符
+
+// 输入是否还没写完：{ ( [ 没有闭合，或者语法分析在输入结尾处还期待更多内容（例：结尾是运算符、let x）。
+// 这种情况下REPL继续读下一行，而不是报语法错误
+func incomplete(input string) bool {
+	depth := 0
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LBRACE, token.LPAREN, token.LBRACKET:
+			depth++
+		case token.RBRACE, token.RPAREN, token.RBRACKET:
+			depth--
+		}
+	}
+	if depth > 0 {
+		return true
+	}
+	if depth < 0 { //多余的右括号，再读也不会完整
+		return false
+	}
+
+	p := parser.New(lexer.New(input))
+	p.ParseProgram()
+	for _, msg := range p.Errors() {
+		if strings.Contains(msg, token.EOF.String()) { //错误发生在输入结尾
+			return true
+		}
+	}
+	return false
+}
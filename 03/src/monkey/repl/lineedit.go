@@ -0,0 +1,485 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// 读取一行输入，prompt是提示符
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// errInterrupt 用户按了Ctrl-C，放弃当前输入
+var errInterrupt = errors.New("interrupt")
+
+// 输入输出都是终端时使用行编辑器，否则（管道、文件、网络连接）按普通文本逐行读取
+func newLineReader(in io.Reader, out io.Writer, complete func(prefix string) []string) lineReader {
+	inFile, ok1 := in.(*os.File)
+	outFile, ok2 := out.(*os.File)
+	if ok1 && ok2 && isTerminal(inFile.Fd()) && isTerminal(outFile.Fd()) {
+		e := newLineEditor(in, out)
+		e.complete = complete
+		if home, err := os.UserHomeDir(); err == nil {
+			e.loadHistory(filepath.Join(home, HISTORY_FILE))
+		}
+		return &terminalReader{fd: inFile.Fd(), editor: e}
+	}
+	return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+// 普通文本输入，为 I/O 提供了缓冲区，一次读入一行
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scannerReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprintf(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// 终端输入：读一行时切换到原始模式由行编辑器处理按键，读完恢复，求值的输出照常显示
+type terminalReader struct {
+	fd     uintptr
+	editor *lineEditor
+}
+
+func (r *terminalReader) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	return r.editor.ReadLine(prompt)
+}
+
+const (
+	HISTORY_FILE = ".monkey_history" //历史记录文件，位于用户主目录
+	maxHistory   = 1000              //最多保留的历史记录条数
+)
+
+// 行编辑器：光标移动、历史记录（上下键、Ctrl-R搜索）和Tab补全。
+// 按键：
+//
+//	← → Ctrl-B Ctrl-F 移动光标    Home End Ctrl-A Ctrl-E 行首行尾   Alt-B Alt-F 按单词移动
+//	↑ ↓ Ctrl-P Ctrl-N 历史记录    Ctrl-R 向前搜索历史记录
+//	Backspace Delete 删除字符     Ctrl-W 删除前一个单词   Ctrl-U Ctrl-K 删除到行首/行尾
+//	Tab 补全                      Ctrl-L 清屏             Ctrl-C 放弃当前行       Ctrl-D 空行时结束输入
+//
+// 输入需是原始模式的终端，行编辑器本身只处理字节流，便于测试
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	complete func(prefix string) []string //返回以prefix开头的候选词
+
+	history  []string
+	histFile string //为空时不保存历史记录
+
+	prompt  string
+	buf     []rune //当前行
+	pos     int    //光标在buf中的位置
+	histIdx int    //正在浏览的历史记录下标，len(history)表示正在编辑的新行
+	draft   []rune //浏览历史记录前正在编辑的内容
+}
+
+func newLineEditor(in io.Reader, out io.Writer) *lineEditor {
+	return &lineEditor{in: bufio.NewReader(in), out: out}
+}
+
+// 按键
+const (
+	keyUnknown = -iota - 1
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyWordLeft
+	keyWordRight
+)
+
+func ctrl(c rune) rune { return c & 0x1f }
+
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	e.prompt, e.buf, e.pos = prompt, nil, 0
+	e.histIdx, e.draft = len(e.history), nil
+	e.refresh()
+
+	for {
+		r, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			return e.finish(), nil
+		case ctrl('C'):
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupt
+		case ctrl('D'):
+			if len(e.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteAt(e.pos)
+		case ctrl('A'), keyHome:
+			e.pos = 0
+		case ctrl('E'), keyEnd:
+			e.pos = len(e.buf)
+		case ctrl('B'), keyLeft:
+			if e.pos > 0 {
+				e.pos--
+			}
+		case ctrl('F'), keyRight:
+			if e.pos < len(e.buf) {
+				e.pos++
+			}
+		case keyWordLeft:
+			e.pos = e.wordStart()
+		case keyWordRight:
+			for e.pos < len(e.buf) && !isWordRune(e.buf[e.pos]) {
+				e.pos++
+			}
+			for e.pos < len(e.buf) && isWordRune(e.buf[e.pos]) {
+				e.pos++
+			}
+		case ctrl('H'), 127:
+			if e.pos > 0 {
+				e.pos--
+				e.deleteAt(e.pos)
+			}
+		case keyDelete:
+			e.deleteAt(e.pos)
+		case ctrl('W'):
+			start := e.wordStart()
+			e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+			e.pos = start
+		case ctrl('U'):
+			e.buf = append([]rune{}, e.buf[e.pos:]...)
+			e.pos = 0
+		case ctrl('K'):
+			e.buf = e.buf[:e.pos]
+		case ctrl('L'):
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case ctrl('P'), keyUp:
+			e.showHistory(e.histIdx - 1)
+		case ctrl('N'), keyDown:
+			e.showHistory(e.histIdx + 1)
+		case ctrl('R'):
+			submit, err := e.search()
+			if err != nil {
+				return "", err
+			}
+			if submit {
+				return e.finish(), nil
+			}
+		case '\t':
+			e.completeWord()
+		default:
+			if r >= 0 && unicode.IsPrint(r) {
+				e.insert(r)
+			}
+		}
+		e.refresh()
+	}
+}
+
+// 回车：换行并把当前行加入历史记录
+func (e *lineEditor) finish() string {
+	io.WriteString(e.out, "\r\n")
+	line := string(e.buf)
+	e.addHistory(line)
+	return line
+}
+
+// 读一个按键，方向键等转义序列转换为key常量
+func (e *lineEditor) readKey() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != 27 {
+		return r, err
+	}
+	next, _, err := e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch next {
+	case 'b':
+		return keyWordLeft, nil
+	case 'f':
+		return keyWordRight, nil
+	case '[', 'O':
+	default:
+		return keyUnknown, nil
+	}
+	//CSI序列：参数字节，直到0x40-0x7E之间的结束字节
+	var params []rune
+	for {
+		c, _, err := e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if c >= 0x40 && c <= 0x7e {
+			return csiKey(string(params), c), nil
+		}
+		params = append(params, c)
+	}
+}
+
+func csiKey(params string, final rune) rune {
+	switch final {
+	case 'A':
+		return keyUp
+	case 'B':
+		return keyDown
+	case 'C':
+		return keyRight
+	case 'D':
+		return keyLeft
+	case 'H':
+		return keyHome
+	case 'F':
+		return keyEnd
+	case '~':
+		switch params {
+		case "1", "7":
+			return keyHome
+		case "4", "8":
+			return keyEnd
+		case "3":
+			return keyDelete
+		}
+	}
+	return keyUnknown
+}
+
+func (e *lineEditor) insert(rs ...rune) {
+	buf := make([]rune, 0, len(e.buf)+len(rs))
+	buf = append(buf, e.buf[:e.pos]...)
+	buf = append(buf, rs...)
+	e.buf = append(buf, e.buf[e.pos:]...)
+	e.pos += len(rs)
+}
+
+func (e *lineEditor) deleteAt(i int) {
+	if i < len(e.buf) {
+		e.buf = append(e.buf[:i], e.buf[i+1:]...)
+	}
+}
+
+// 光标前一个单词的开头
+func (e *lineEditor) wordStart() int {
+	i := e.pos
+	for i > 0 && !isWordRune(e.buf[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(e.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+// 标识符中的字符，与词法分析器一致
+func isWordRune(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_'
+}
+
+// 重画当前行并把光标移到正确位置
+func (e *lineEditor) refresh() {
+	e.draw(e.prompt, e.buf, e.pos)
+}
+
+func (e *lineEditor) draw(prompt string, buf []rune, pos int) {
+	var b strings.Builder
+	b.WriteString("\r")
+	b.WriteString(prompt)
+	b.WriteString(string(buf))
+	b.WriteString("\x1b[K") //清除到行尾
+	if back := width(buf[pos:]); back > 0 {
+		fmt.Fprintf(&b, "\x1b[%dD", back)
+	}
+	io.WriteString(e.out, b.String())
+}
+
+// 字符在终端中占的列数，中日韩等全角字符占两列
+func width(rs []rune) int {
+	n := 0
+	for _, r := range rs {
+		n++
+		if isWide(r) {
+			n++
+		}
+	}
+	return n
+}
+
+func isWide(r rune) bool {
+	return r >= 0x1100 && r <= 0x115f ||
+		r >= 0x2e80 && r <= 0xa4cf ||
+		r >= 0xac00 && r <= 0xd7a3 ||
+		r >= 0xf900 && r <= 0xfaff ||
+		r >= 0xfe30 && r <= 0xfe4f ||
+		r >= 0xff00 && r <= 0xff60 ||
+		r >= 0xffe0 && r <= 0xffe6 ||
+		r >= 0x20000 && r <= 0x3fffd
+}
+
+// 显示第i条历史记录，i为len(history)时回到正在编辑的新行
+func (e *lineEditor) showHistory(i int) {
+	if i < 0 || i > len(e.history) || i == e.histIdx {
+		return
+	}
+	if e.histIdx == len(e.history) {
+		e.draft = append([]rune{}, e.buf...)
+	}
+	e.histIdx = i
+	if i == len(e.history) {
+		e.buf = e.draft
+	} else {
+		e.buf = []rune(e.history[i])
+	}
+	e.pos = len(e.buf)
+}
+
+// Ctrl-R 增量搜索历史记录：输入的内容在历史记录中从新到旧查找，再按Ctrl-R找更早的匹配。
+// 回车执行找到的行；Ctrl-G、Ctrl-C放弃搜索；其他按键把找到的行放入编辑区继续编辑
+func (e *lineEditor) search() (submit bool, err error) {
+	var query []rune
+	match := len(e.history) //当前匹配的下标
+	failed := false
+	find := func(from int) {
+		for i := from; i >= 0; i-- {
+			if i < len(e.history) && strings.Contains(e.history[i], string(query)) {
+				match, failed = i, false
+				return
+			}
+		}
+		failed = true
+	}
+	draw := func() {
+		prompt := "(reverse-i-search)`" + string(query) + "': "
+		if failed {
+			prompt = "(failed " + prompt[1:]
+		}
+		line := []rune{}
+		if match < len(e.history) {
+			line = []rune(e.history[match])
+		}
+		e.draw(prompt, line, len(line))
+	}
+
+	draw()
+	for {
+		r, err := e.readKey()
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case r == ctrl('R'):
+			find(match - 1)
+		case r == ctrl('H') || r == 127:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				find(len(e.history) - 1)
+			}
+		case r == ctrl('G') || r == ctrl('C'):
+			return false, nil
+		case r >= 0 && r != '\r' && r != '\n' && unicode.IsPrint(r):
+			query = append(query, r)
+			find(match)
+		default:
+			if match < len(e.history) {
+				e.buf = []rune(e.history[match])
+				e.pos = len(e.buf)
+			}
+			return r == '\r' || r == '\n', nil
+		}
+		draw()
+	}
+}
+
+// Tab 补全光标前的单词：只有一个候选时补全整个词，多个候选时补全公共前缀，无法再补全时列出候选
+func (e *lineEditor) completeWord() {
+	start := e.pos
+	for start > 0 && isWordRune(e.buf[start-1]) {
+		start--
+	}
+	prefix := string(e.buf[start:e.pos])
+	if prefix == "" || e.complete == nil {
+		return
+	}
+	candidates := e.complete(prefix)
+	switch len(candidates) {
+	case 0:
+		io.WriteString(e.out, "\a")
+	case 1:
+		e.insert([]rune(candidates[0][len(prefix):])...)
+	default:
+		common := candidates[0]
+		for _, c := range candidates[1:] {
+			for !strings.HasPrefix(c, common) {
+				common = common[:len(common)-1]
+			}
+		}
+		if len(common) > len(prefix) {
+			e.insert([]rune(common[len(prefix):])...)
+			return
+		}
+		io.WriteString(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+	}
+}
+
+// 读取历史记录文件，之后输入的每一行追加到文件中
+func (e *lineEditor) loadHistory(path string) {
+	e.histFile = path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > maxHistory { //文件只保留最近的记录
+		e.history = e.history[len(e.history)-maxHistory:]
+		os.WriteFile(path, []byte(strings.Join(e.history, "\n")+"\n"), 0600)
+	}
+}
+
+// 加入历史记录，空行和与上一条相同的行不记录
+func (e *lineEditor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[1:]
+	}
+	if e.histFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.histFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(line + "\n")
+}
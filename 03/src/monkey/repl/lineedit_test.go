@@ -0,0 +1,158 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"monkey/evaluator"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 依次读出输入中的各行
+func readLines(t *testing.T, e *lineEditor) []string {
+	var lines []string
+	for {
+		line, err := e.ReadLine(">> ")
+		if err == io.EOF {
+			return lines
+		}
+		if err == errInterrupt {
+			lines = append(lines, "<interrupt>")
+			continue
+		}
+		if err != nil {
+			t.Fatalf("ReadLine error: %s", err)
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestLineEditing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"abc\r", "abc"},
+		{"abc\x1b[D\x1b[DX\r", "aXbc"},
+		{"abc\x01X\x05Y\r", "XabcY"},
+		{"abc\x7f\x7fd\r", "ad"},
+		{"abc\x1b[H\x1b[3~\r", "bc"},
+		{"let foo = bar\x17baz\r", "let foo = baz"},
+		{"let foo\x1bbX\r", "let Xfoo"},
+		{"abc\x02\x02\x0b\r", "a"},
+		{"abc\x02\x15\r", "c"},
+		{"你好\x1b[DX\r", "你X好"},
+		{"a\tb\r", "ab"},
+	}
+
+	for _, tt := range tests {
+		e := newLineEditor(strings.NewReader(tt.input), io.Discard)
+		lines := readLines(t, e)
+		if len(lines) != 1 || lines[0] != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, lines)
+		}
+	}
+}
+
+func TestLineEditorControl(t *testing.T) {
+	var out bytes.Buffer
+	e := newLineEditor(strings.NewReader("abc\x03xy\x04\x01\x04\r\x04"), &out)
+	lines := readLines(t, e)
+	if strings.Join(lines, "|") != "<interrupt>|y" {
+		t.Errorf("wrong lines. got=%q", lines)
+	}
+	if !strings.Contains(out.String(), "^C") {
+		t.Errorf("Ctrl-C not echoed. got=%q", out.String())
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HISTORY_FILE)
+	os.WriteFile(path, []byte("let a = 1;\nlet b = 2;\n"), 0600)
+
+	input := "" +
+		"\x1b[A\r" + //上一条
+		"\x1b[A\x1b[A\x1b[A\r" + //最早一条，不会越界
+		"x\x1b[A\x1b[B\r" + //回到正在编辑的内容
+		"\x12a =\r" + //搜索
+		"\x12let\x12\x12\x05X\r" //再找更早的匹配，然后继续编辑
+	e := newLineEditor(strings.NewReader(input), io.Discard)
+	e.loadHistory(path)
+
+	expected := []string{"let b = 2;", "let a = 1;", "x", "let a = 1;", "let b = 2;X"}
+	lines := readLines(t, e)
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Fatalf("wrong lines.\nwant %q\ngot  %q", expected, lines)
+	}
+
+	data, _ := os.ReadFile(path)
+	want := "let a = 1;\nlet b = 2;\nlet a = 1;\nx\nlet a = 1;\nlet b = 2;X\n" //与上一条相同的行不记录
+	if string(data) != want {
+		t.Errorf("wrong history file.\nwant %q\ngot  %q", want, string(data))
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	words := []string{"let", "letter", "lettuce", "quote", "unquote"}
+	complete := func(prefix string) []string {
+		var out []string
+		for _, w := range words {
+			if strings.HasPrefix(w, prefix) {
+				out = append(out, w)
+			}
+		}
+		return out
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"qu\t(1)\r", "quote(1)"},
+		{"lett\t\r", "lett"},
+		{"lette\t\r", "letter"},
+		{"x + unq\t\x01\t\r", "x + unquote"},
+		{"zz\t\r", "zz"},
+	}
+
+	for _, tt := range tests {
+		e := newLineEditor(strings.NewReader(tt.input), io.Discard)
+		e.complete = complete
+		lines := readLines(t, e)
+		if len(lines) != 1 || lines[0] != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, lines)
+		}
+	}
+
+	var out bytes.Buffer
+	e := newLineEditor(strings.NewReader("le\t\t\r"), &out)
+	e.complete = complete
+	readLines(t, e)
+	if !strings.Contains(out.String(), "let  letter  lettuce") {
+		t.Errorf("candidates not listed. got=%q", out.String())
+	}
+}
+
+// 补全候选包括关键字、特殊调用和已绑定的名字
+func TestSessionCompletions(t *testing.T) {
+	s := &session{out: io.Discard, interp: evaluator.New()}
+	if _, err := s.interp.Run("let reduce = 1; let result = 2; let quotient = 3;"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		prefix   string
+		expected string
+	}{
+		{"re", "reduce result return"},
+		{"qu", "quote quotient"},
+		{"z", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(s.completions(tt.prefix), " "); got != tt.expected {
+			t.Errorf("completions(%q): expected %q, got %q", tt.prefix, tt.expected, got)
+		}
+	}
+}
@@ -1,48 +1,165 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
+	"monkey/ast"
+	"monkey/debugger"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/resolver"
+	"monkey/token"
+	"sort"
+	"strings"
 )
 
 const PORMPT = ">> "
 
+// REPL 一次会话的状态
+type session struct {
+	out    io.Writer
+	interp *evaluator.Interpreter //解释器，其全局环境整个会话共用
+	trace  bool                   //是否输出语法解析过程
+	inputs []string               //求值成功的输入，:save 保存
+
+	interpOpts []evaluator.Option //创建解释器（含:reset）时使用的选项
+	noFiles    bool               //禁用读写本地文件的命令
+
+	lines lineReader         //输入，调试器暂停时也从这里读取命令
+	dbg   *debugger.Debugger //:break、:step 使用的调试器
+}
+
+// Option REPL会话的可选配置，传给Start
+type Option func(*session)
+
+// WithInterpreterOptions 会话的解释器（包括:reset后新建的）使用的选项，例：evaluator.WithLimits
+func WithInterpreterOptions(opts ...evaluator.Option) Option {
+	return func(s *session) { s.interpOpts = append(s.interpOpts, opts...) }
+}
+
+// WithoutFileAccess 禁用读写本地文件的命令（:load、:save），用于远程客户端的会话
+func WithoutFileAccess() Option {
+	return func(s *session) { s.noFiles = true }
+}
+
 // REPL 实现读取-求值-打印 循环
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in) //为文本 I/O 提供了缓冲区，读入一行给扫描器
-	env := object.NewEnviroment()   //创建标识符的环境-域
+// 输入未完成时（见incomplete）显示续行提示符，把后续各行累积起来一起求值，
+// 因此可以逐行输入或直接粘贴多行的程序；未完成时连续输入两个空行放弃累积的内容，交给语法分析报错。
+// in和out都是终端时使用行编辑器（见lineEditor），支持历史记录和Tab补全
+func Start(in io.Reader, out io.Writer, opts ...Option) {
+	s := &session{out: out}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.dbg = debugger.New(s.onStop)
+	s.interp = s.newInterpreter()
+	s.lines = newLineReader(in, out, s.completions)
+	var pending strings.Builder //已读入但还未完成的输入
+	blank := 0                  //续行中连续的空行数
 
 	for {
-		fmt.Fprintf(out, PORMPT)
-		scanned := scanner.Scan() //读取缓冲区内容
-
-		if !scanned {
+		prompt := PORMPT
+		if pending.Len() != 0 {
+			prompt = CONTINUE_PROMPT
+		}
+		line, err := s.lines.ReadLine(prompt)
+		if err == errInterrupt { //Ctrl-C 放弃当前输入
+			pending.Reset()
+			blank = 0
+			continue
+		}
+		if err != nil {
+			if pending.Len() != 0 { //输入结束时还有未完成的内容，照常求值以报告错误
+				s.eval(pending.String())
+			}
 			return
 		}
-		line := scanner.Text() //读取??
-		l := lexer.New(line)   //字符串转为 lexer结构，l.NextToken()才会转换词法单元
-		p := parser.New(l)     //语法解析 传入lexer结构文本 并初始化
-
-		io.WriteString(out, "语法解析过程可视化输出：\n")
-		program := p.ParseProgram() //开始语法解析处理程序
-		if len(p.Errors()) != 0 {   //错误输出
-			printParserErrors(out, p.Errors())
+		if pending.Len() == 0 && strings.HasPrefix(line, ":") { //冒号开头的是REPL命令
+			s.command(line)
+			continue
+		}
+		if pending.Len() == 0 && strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		pending.WriteString(line)
+		pending.WriteString("\n")
+		if strings.TrimSpace(line) == "" {
+			blank++
+		} else {
+			blank = 0
+		}
+		if blank < 2 && incomplete(pending.String()) {
 			continue
 		}
+		s.eval(pending.String())
+		pending.Reset()
+		blank = 0
+	}
+}
 
-		//ast树遍历求值
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, "\n求值结果:\n")
-			io.WriteString(out, evaluated.Inspect()) //查看求值结果
-			io.WriteString(out, "\n")
+// 创建会话的解释器，安装会话的调试器
+func (s *session) newInterpreter() *evaluator.Interpreter {
+	opts := append([]evaluator.Option{evaluator.WithDebugger(s.dbg)}, s.interpOpts...)
+	return evaluator.New(opts...)
+}
+
+// 求值器特殊处理的调用，补全时与关键字一起提供
+var specialForms = []string{"quote", "unquote"}
+
+// Tab补全的候选词：关键字、特殊调用和全局环境中已绑定的名字
+func (s *session) completions(prefix string) []string {
+	seen := map[string]bool{}
+	var words []string
+	for _, group := range [][]string{token.Keywords(), specialForms, s.interp.Env().Names()} {
+		for _, w := range group {
+			if strings.HasPrefix(w, prefix) && !seen[w] {
+				seen[w] = true
+				words = append(words, w)
+			}
 		}
 	}
+	sort.Strings(words)
+	return words
+}
+
+// 解析并求值一段输入，打印结果；求值成功时记入会话
+func (s *session) eval(line string) {
+	var opts []parser.Option
+	if s.trace {
+		io.WriteString(s.out, "语法解析过程可视化输出：\n")
+		opts = append(opts, parser.WithTraceWriter(s.out))
+	}
+	l := lexer.New(line)        //字符串转为 lexer结构，l.NextToken()才会转换词法单元
+	p := parser.New(l, opts...) //语法解析 传入lexer结构文本 并初始化
+
+	program := p.ParseProgram() //开始语法解析处理程序
+	if len(p.Errors()) != 0 {   //错误输出
+		printParserErrors(s.out, p.Errors())
+		return
+	}
+
+	expanded, err := s.interp.ExpandMacros(program) //宏展开
+	if err != nil {
+		fmt.Fprintf(s.out, "macro expansion error: %s\n", err)
+		return
+	}
+
+	resolver.Resolve(expanded.(*ast.Program)) //为局部变量分配槽位
+
+	//ast树遍历求值
+	evaluated := s.interp.Eval(expanded)
+	s.dbg.Done()
+	if evaluated != nil {
+		io.WriteString(s.out, "\n求值结果:\n")
+		io.WriteString(s.out, evaluated.Inspect()) //查看求值结果
+		io.WriteString(s.out, "\n")
+	}
+	if evaluated == nil || evaluated.Type() != object.ERROR_OBJ {
+		s.inputs = append(s.inputs, line)
+	}
 }
 
 const MONKEY_FACE = `            __,__
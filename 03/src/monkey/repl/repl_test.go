@@ -0,0 +1,175 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"let add = fn(x, y) {", true},
+		{"add(1,", true},
+		{"add(1, 2", true},
+		{"5 +", true},
+		{"let x =", true},
+		{"let x", true},
+		{"if (x > 1)", true},
+		{"if (x > 1) { 1 } else", true},
+		{"fn(a: int) ->", true},
+		{"let xs = [", true},
+		{"let xs = [1, 2,\n  [3,", true},
+		{"let xs = [1, 2,\n  [3, 4]\n];", false},
+		{"xs[", true},
+		{"]", false},
+		{"let f = fn(x) {\n  x +\n  1\n};", false},
+		{"5 + ;", false},
+		{"})", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := incomplete(tt.input); got != tt.expected {
+			t.Errorf("incomplete(%q) = %t, want %t", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestStartMultiLine(t *testing.T) {
+	input := `let add = fn(x, y) {
+  x +
+    y
+};
+add(1,
+  2)
+`
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	got := out.String()
+	if strings.Count(got, CONTINUE_PROMPT) != 4 {
+		t.Errorf("expected 4 continuation prompts. got=%q", got)
+	}
+	if !strings.HasSuffix(got, "求值结果:\n3\n>> ") {
+		t.Errorf("wrong output. got=%q", got)
+	}
+}
+
+// 续行中连续两个空行放弃等待，报告语法错误
+func TestStartAbandonContinuation(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("let x =\n\n\n1 + 1\n"), &out)
+
+	got := out.String()
+	if !strings.Contains(got, "no prefix parse function for EOF found") {
+		t.Errorf("expected parse error. got=%q", got)
+	}
+	if !strings.HasSuffix(got, "求值结果:\n2\n>> ") {
+		t.Errorf("input after the abandoned statement not evaluated. got=%q", got)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.mk")
+	if err := os.WriteFile(lib, []byte("let double = fn(x) { x * 2 };\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	saved := filepath.Join(dir, "session.mk")
+
+	input := strings.Join([]string{
+		":tokens let x = 1;",
+		":ast -a + 1",
+		":load " + lib,
+		"let y = double(21);",
+		"y + true",
+		":env",
+		":time y",
+		":save " + saved,
+		":reset",
+		":env",
+		":load",
+		":env x",
+		":nope",
+		":help",
+	}, "\n")
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	got := out.String()
+
+	for _, want := range []string{
+		"1:5   IDENT  \"x\"\n",
+		"ExpressionStatement 1:1\n  InfixExpression + 1:1\n    PrefixExpression - 1:1\n      Identifier a 1:2\n    IntegerLiteral 1 1:6\n",
+		"double  FUNCTION  fn(x) { (x * 2) }\n",
+		"y       INTEGER   42\n",
+		"耗时: ",
+		"saved 3 inputs to " + saved,
+		"environment cleared\n>> NAME  TYPE  VALUE\n>> ",
+		"usage: :load file.mk\n",
+		"usage: :env\n",
+		"unknown command :nope",
+		":trace on|off ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q. got=\n%s", want, got)
+		}
+	}
+
+	data, err := os.ReadFile(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "let double = fn(x) { x * 2 };\nlet y = double(21);\ny\n"
+	if string(data) != expected {
+		t.Errorf("wrong saved session. want=%q, got=%q", expected, data)
+	}
+}
+
+func TestDebugCommands(t *testing.T) {
+	input := `let add = fn(a, b) {
+  let s = a + b;
+  s * 2
+};
+:break 2 if a > 1
+add(1, 2) + add(5, 6)
+p a
+bt
+w s
+n
+env
+c
+:step add(1, 1)
+s
+s
+q
+:break
+:break clear
+:break
+`
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	got := out.String()
+
+	for _, want := range []string{
+		"breakpoint at line 2 if a > 1\n",
+		"stopped at 2:3 (breakpoint) in add: let s = a + b;\n(dbg) 5\n",
+		"(dbg) #1 add called at 1:13\n#0 <program>\n(dbg)   s = ERRORidentifier not found: s\n",
+		"stopped at 3:3 (step) in add: s * 2;\n  s = 11\n",
+		"(dbg) #0 locals:\n  a = 5\n  b = 6\n  s = 11\nglobals:\n  add = fn(a, b) { let s = (a + b);(s * 2) }\n",
+		"求值结果:\n28\n",
+		"stopped at 1:1 (entry) in <program>: add(1, 1);\n  s = ERRORidentifier not found: s\n",
+		"stopped at 2:3 (step) in add: let s = a + b;\n",
+		"stopped at 3:3 (step) in add: s * 2;\n  s = 2\n(dbg) \n求值结果:\nERRORdebugger: execution aborted\n",
+		">> line 2 if a > 1 (hits: 1)\n>> >> no breakpoints\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q. got=\n%s", want, got)
+		}
+	}
+}
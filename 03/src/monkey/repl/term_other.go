@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package repl
+
+import "errors"
+
+// 其他平台不支持行编辑，REPL按普通输入逐行读取
+func isTerminal(fd uintptr) bool { return false }
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("raw terminal mode not supported on this platform")
+}
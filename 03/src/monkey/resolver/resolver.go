@@ -0,0 +1,154 @@
+package resolver
+
+// 在求值前为标识符确定存储位置。
+//
+// 函数（已解析的）调用时创建一个帧，参数和函数体中的let绑定各占一个槽位，
+// 参数在前，同名的绑定共用一个槽位；if 的 {} 不产生新的帧。
+// 函数体中的标识符若在某一层外围函数中有绑定，标记为 (Depth, Slot)，
+// Depth是往外的帧数（0为当前函数）；否则是全局名字，求值时在全局环境中按名字查找。
+//
+// 宏字面量的函数体在宏展开阶段用map环境求值，不做解析；quote中的代码要拼接到别处，
+// 也不做解析，只解析其中unquote的参数。
+
+import "monkey/ast"
+
+// 一个函数的作用域
+type scope struct {
+	slots  map[string]int
+	locals []string
+	outer  *scope
+}
+
+// 为名字分配槽位，已有的直接返回
+func (s *scope) declare(name string) int {
+	if slot, ok := s.slots[name]; ok {
+		return slot
+	}
+	s.slots[name] = len(s.locals)
+	s.locals = append(s.locals, name)
+	return s.slots[name]
+}
+
+type resolver struct {
+	scope *scope //nil表示顶层
+}
+
+// Resolve 解析程序中的标识符，直接修改ast节点。
+// 同一棵ast不能在求值的同时被解析，需要共享的ast应先解析好再交给各个解释器
+func Resolve(program *ast.Program) {
+	r := &resolver{}
+	r.resolve(program)
+}
+
+func (r *resolver) resolve(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			r.resolve(s)
+		}
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			r.resolve(s)
+		}
+	case *ast.LetStatement:
+		r.resolve(node.Value)
+		r.identifier(node.Name)
+	case *ast.ReturnStatement:
+		r.resolve(node.ReturnValue)
+	case *ast.ExpressionStatement:
+		r.resolve(node.Expression)
+	case *ast.PrefixExpression:
+		r.resolve(node.Right)
+	case *ast.InfixExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Right)
+	case *ast.IfExpression:
+		r.resolve(node.Condition)
+		r.resolve(node.Consequence)
+		if node.Alternative != nil {
+			r.resolve(node.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		r.function(node)
+	case *ast.SelectorExpression:
+		r.resolve(node.X) //成员名不是变量
+	case *ast.ArrayLiteral:
+		for _, e := range node.Elements {
+			r.resolve(e)
+		}
+	case *ast.IndexExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Index)
+	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			r.quoted(node)
+			return
+		}
+		r.resolve(node.Function)
+		for _, a := range node.Arguments {
+			r.resolve(a)
+		}
+	case *ast.Identifier:
+		r.identifier(node)
+	}
+}
+
+func (r *resolver) function(fl *ast.FunctionLiteral) {
+	s := &scope{slots: map[string]int{}, locals: []string{}, outer: r.scope}
+	for _, p := range fl.Parameters {
+		s.declare(p.Value)
+	}
+	hoist(s, fl.Body)
+
+	r.scope = s
+	for _, p := range fl.Parameters {
+		r.identifier(p)
+	}
+	r.resolve(fl.Body)
+	r.scope = s.outer
+
+	fl.Locals = s.locals
+}
+
+// 为函数体中的let绑定分配槽位，不进入内层函数和quote
+func hoist(s *scope, body *ast.BlockStatement) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		case *ast.CallExpression:
+			return n.Function.TokenLiteral() != "quote"
+		case *ast.LetStatement:
+			s.declare(n.Name.Value)
+		}
+		return true
+	})
+}
+
+// quote(...) 中只解析unquote的参数，它们在quote求值时就在当前环境中求值
+func (r *resolver) quoted(call *ast.CallExpression) {
+	for _, a := range call.Arguments {
+		ast.Inspect(a, func(n ast.Node) bool {
+			c, ok := n.(*ast.CallExpression)
+			if !ok || c.Function.TokenLiteral() != "unquote" {
+				return true
+			}
+			for _, arg := range c.Arguments {
+				r.resolve(arg)
+			}
+			return false
+		})
+	}
+}
+
+func (r *resolver) identifier(id *ast.Identifier) {
+	id.Local, id.Depth, id.Slot = false, 0, 0
+	depth := 0
+	for s := r.scope; s != nil; s = s.outer {
+		if slot, ok := s.slots[id.Value]; ok {
+			id.Local, id.Depth, id.Slot = true, depth, slot
+			return
+		}
+		depth++
+	}
+}
@@ -0,0 +1,124 @@
+package resolver
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+type location struct {
+	local       bool
+	depth, slot int
+}
+
+// 按出现顺序收集标识符的解析结果
+func collect(program *ast.Program) (names []string, locs []location) {
+	ast.Inspect(program, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Identifier); ok {
+			names = append(names, id.Value)
+			locs = append(locs, location{id.Local, id.Depth, id.Slot})
+		}
+		return true
+	})
+	return names, locs
+}
+
+func TestResolve(t *testing.T) {
+	input := `
+let g = 1;
+let f = fn(a, b) {
+    let c = a + g;
+    if (c) { let d = b; }
+    fn(x) { x + c + d + a };
+};`
+	program := parse(t, input)
+	Resolve(program)
+
+	expected := []struct {
+		name string
+		loc  location
+	}{
+		{"g", location{}},
+		{"f", location{}},
+		{"a", location{true, 0, 0}},
+		{"b", location{true, 0, 1}},
+		{"c", location{true, 0, 2}},
+		{"a", location{true, 0, 0}},
+		{"g", location{}},
+		{"c", location{true, 0, 2}},
+		{"d", location{true, 0, 3}},
+		{"b", location{true, 0, 1}},
+		{"x", location{true, 0, 0}},
+		{"x", location{true, 0, 0}},
+		{"c", location{true, 1, 2}},
+		{"d", location{true, 1, 3}},
+		{"a", location{true, 1, 0}},
+	}
+
+	names, locs := collect(program)
+	if len(names) != len(expected) {
+		t.Fatalf("wrong number of identifiers. want=%d, got=%d (%v)", len(expected), len(names), names)
+	}
+	for i, tt := range expected {
+		if names[i] != tt.name || locs[i] != tt.loc {
+			t.Errorf("identifier %d: want %s%+v, got %s%+v", i, tt.name, tt.loc, names[i], locs[i])
+		}
+	}
+
+	fl := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	want := []string{"a", "b", "c", "d"}
+	if len(fl.Locals) != len(want) {
+		t.Fatalf("wrong locals. want=%v, got=%v", want, fl.Locals)
+	}
+	for i, name := range want {
+		if fl.Locals[i] != name {
+			t.Errorf("locals[%d]: want %q, got %q", i, name, fl.Locals[i])
+		}
+	}
+}
+
+func TestResolveSkipsMacrosAndQuotes(t *testing.T) {
+	input := `
+let m = macro(a) { fn(x) { x } };
+fn(y) { quote(y + unquote(y)) };`
+	program := parse(t, input)
+	Resolve(program)
+
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.MacroLiteral:
+			ast.Inspect(n, func(n ast.Node) bool {
+				if fl, ok := n.(*ast.FunctionLiteral); ok && fl.Locals != nil {
+					t.Errorf("function literal inside macro was resolved")
+				}
+				if id, ok := n.(*ast.Identifier); ok && id.Local {
+					t.Errorf("identifier %s inside macro was resolved", id.Value)
+				}
+				return true
+			})
+			return false
+		}
+		return true
+	})
+
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral).
+		Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	infix := call.Arguments[0].(*ast.InfixExpression)
+	if infix.Left.(*ast.Identifier).Local {
+		t.Errorf("quoted identifier was resolved")
+	}
+	unquoted := infix.Right.(*ast.CallExpression).Arguments[0].(*ast.Identifier)
+	if !unquoted.Local || unquoted.Slot != 0 {
+		t.Errorf("unquote argument not resolved. got=%+v", unquoted)
+	}
+}
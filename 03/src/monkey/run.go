@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/cover"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/profile"
+	"monkey/stdlib"
+	"os"
+)
+
+// monkey run [-I dir] [-root dir] [-env name] [-profile out.pprof] [-cover] file.mk 运行程序，输出最后一个表达式的值，出错时返回非0
+func runRun(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	profilePath := fs.String("profile", "", "分析每个函数的调用次数和时间，pprof格式写入该文件，文本报告输出到标准错误")
+	optimize := fs.Bool("O", false, "求值前做常量折叠等优化")
+	coverage := addCoverFlags(fs)
+	imports := addImportFlag(fs)
+	grants := addGrantFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey run [-O] [-I dir] [-root dir [-write] [-allow pattern]] [-env pattern] [-profile out.pprof] [-cover] [-coverprofile file] [-coverformat text|html|lcov] file.mk")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if err := coverage.check(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	path := fs.Arg(0)
+	src, err := readSource(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	opts := append(stdlib.Options(), evaluator.WithImports(*imports...))
+	opts = append(opts, grants.options()...)
+	if *optimize {
+		opts = append(opts, evaluator.WithOptimizer())
+	}
+	var prof *profile.Profiler
+	if *profilePath != "" {
+		prof = profile.New(path)
+		opts = append(opts, evaluator.WithHooks(prof))
+		prof.Start()
+	}
+	var cov *cover.Profile
+	if coverage.enabled() {
+		cov = cover.New(path, src)
+		opts = append(opts, evaluator.WithHooks(cov))
+	}
+	result, err := evaluator.New(opts...).RunFile(path, src)
+	if prof != nil {
+		prof.Stop()
+		if err := writeProfile(prof, *profilePath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	if cov != nil {
+		if err := coverage.write(os.Stderr, []*cover.Profile{cov}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		return 1
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "%s: error: %s\n", path, errObj.Message)
+		return 1
+	}
+	if result != nil && result != evaluator.NULL {
+		fmt.Println(result.Inspect())
+	}
+	return 0
+}
+
+func writeProfile(prof *profile.Profiler, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := prof.WriteProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return prof.WriteReport(os.Stderr)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"monkey/evaluator"
+	"monkey/server"
+	"monkey/stdlib"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// monkey serve [-addr :7777] 通过网络提供REPL，每个连接是独立的会话，例：nc localhost 7777
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":7777", "监听地址，unix:/path/to/sock 表示Unix套接字")
+	maxSessions := fs.Int("max-sessions", 64, "同时连接的最大会话数，0表示不限制")
+	idle := fs.Duration("idle", 10*time.Minute, "会话空闲多久后断开，0表示不限制")
+	timeout := fs.Duration("timeout", 5*time.Second, "每次求值的最长时间，0表示不限制")
+	maxSteps := fs.Int64("max-steps", 50000000, "每次求值最多求值的ast节点数，0表示不限制")
+	maxDepth := fs.Int("max-depth", 10000, "函数调用的最大嵌套层数，0表示默认的上限")
+	maxSize := fs.Int("max-size", 1<<24, "字符串的最大字节数和数组的最大元素个数，0表示不限制")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey serve [-addr :7777 | -addr unix:/path] [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	network, address := "tcp", *addr
+	if strings.HasPrefix(address, "unix:") {
+		network, address = "unix", strings.TrimPrefix(address, "unix:")
+	}
+	l, err := net.Listen(network, address)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	logger := log.New(os.Stderr, "monkey serve: ", log.LstdFlags)
+	srv := server.New(server.Config{
+		MaxSessions: *maxSessions,
+		IdleTimeout: *idle,
+		Limits:      evaluator.Limits{MaxSteps: *maxSteps, MaxDepth: *maxDepth, Timeout: *timeout, MaxSize: *maxSize},
+		Options:     stdlib.Options(), //标准库不读取文件系统，可以提供给客户端
+		Logf:        logger.Printf,
+	})
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(l) }()
+	logger.Printf("listening on %s %s", network, l.Addr())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-served:
+		logger.Print(err)
+		return 1
+	case sig := <-signals:
+		logger.Printf("%s received, shutting down", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Printf("shutdown: %s", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,226 @@
+package server
+
+// 网络REPL服务：通过TCP或Unix套接字接受连接，每个连接是一个独立的REPL会话（见repl.Start），
+// 有自己的解释器和全局环境。会话中的每次求值受Config.Limits限制，空闲过久的会话被断开，
+// 同时存在的会话数有上限；Shutdown停止接受连接，等待正在求值的会话输出结果后断开。
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/evaluator"
+	"monkey/repl"
+	"net"
+	"sync"
+	"time"
+)
+
+// Config 服务的配置，零值字段表示不限制
+type Config struct {
+	MaxSessions int                //同时存在的最大会话数
+	IdleTimeout time.Duration      //会话等待输入超过该时间时断开
+	Limits      evaluator.Limits   //会话中每次求值的资源限制
+	Options     []evaluator.Option //会话的解释器的其他配置，例：标准库
+
+	Logf func(format string, args ...interface{}) //记录会话的建立和结束，nil时不记录
+}
+
+// ErrServerClosed Shutdown或Close之后Serve返回的错误
+var ErrServerClosed = errors.New("server: server closed")
+
+// Server 网络REPL服务
+type Server struct {
+	cfg    Config
+	ctx    context.Context //强制关闭时取消，中断会话中正在进行的求值
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	closing   bool
+	listeners map[net.Listener]bool
+	sessions  map[*session]bool
+	nextID    int
+	wg        sync.WaitGroup //正在运行的会话
+}
+
+// 一个连接上的会话
+type session struct {
+	id       int
+	conn     net.Conn
+	timedOut bool //因空闲超时结束
+}
+
+// New 创建服务，用Serve或ServeConn开始处理连接
+func New(cfg Config) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		cfg:       cfg,
+		ctx:       ctx,
+		cancel:    cancel,
+		listeners: map[net.Listener]bool{},
+		sessions:  map[*session]bool{},
+	}
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.cfg.Logf != nil {
+		s.cfg.Logf(format, args...)
+	}
+}
+
+func (s *Server) isClosing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closing
+}
+
+// Serve 接受l上的连接，每个连接在单独的goroutine中运行会话。
+// 总是返回非nil的错误，服务关闭后返回ErrServerClosed
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosing() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn 在conn上运行一个会话，直到客户端断开、空闲超时或服务关闭，返回前关闭conn
+func (s *Server) ServeConn(conn net.Conn) {
+	defer conn.Close()
+
+	sess, err := s.register(conn)
+	if err != nil {
+		fmt.Fprintf(conn, "%s\n", err)
+		return
+	}
+	defer s.unregister(sess)
+
+	s.logf("session %d: connected from %s", sess.id, conn.RemoteAddr())
+	fmt.Fprintf(conn, "monkey REPL session %d, type :help for a list of commands\n", sess.id)
+	repl.Start(&sessionReader{server: s, session: sess}, conn,
+		repl.WithoutFileAccess(),
+		repl.WithInterpreterOptions(append([]evaluator.Option{evaluator.WithLimits(s.cfg.Limits), evaluator.WithContext(s.ctx)}, s.cfg.Options...)...),
+	)
+
+	switch {
+	case s.isClosing():
+		fmt.Fprintf(conn, "\nserver is shutting down, bye\n")
+	case sess.timedOut:
+		fmt.Fprintf(conn, "\nidle for more than %s, disconnected\n", s.cfg.IdleTimeout)
+	}
+	s.logf("session %d: closed", sess.id)
+}
+
+func (s *Server) register(conn net.Conn) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return nil, errors.New("server is shutting down")
+	}
+	if s.cfg.MaxSessions > 0 && len(s.sessions) >= s.cfg.MaxSessions {
+		return nil, fmt.Errorf("too many sessions (max %d), try again later", s.cfg.MaxSessions)
+	}
+	s.nextID++
+	sess := &session{id: s.nextID, conn: conn}
+	s.sessions[sess] = true
+	s.wg.Add(1)
+	return sess, nil
+}
+
+func (s *Server) unregister(sess *session) {
+	s.mu.Lock()
+	delete(s.sessions, sess)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// Shutdown 平稳地关闭服务：停止接受新连接，正在等待输入的会话立即结束，
+// 正在求值的会话输出结果后结束。ctx结束时中断所有求值、关闭所有连接，返回ctx.Err()
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for sess := range s.sessions {
+		sess.conn.SetReadDeadline(time.Now()) //唤醒阻塞在读取上的会话
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.Close()
+		return ctx.Err()
+	}
+}
+
+// Close 立即关闭服务：中断所有求值，关闭所有监听和连接
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closing = true
+	s.cancel()
+	for l := range s.listeners {
+		l.Close()
+	}
+	for sess := range s.sessions {
+		sess.conn.Close()
+	}
+	return nil
+}
+
+// 会话的输入：每次读取前设置空闲超时，服务关闭后返回io.EOF使REPL结束
+type sessionReader struct {
+	server  *Server
+	session *session
+}
+
+func (r *sessionReader) Read(p []byte) (int, error) {
+	conn := r.session.conn
+	timeout := r.server.cfg.IdleTimeout
+	if timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+	if r.server.isClosing() { //在设置超时之后检查，不会覆盖Shutdown设置的超时
+		return 0, io.EOF
+	}
+	n, err := conn.Read(p)
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			if r.server.isClosing() {
+				return n, io.EOF
+			}
+			r.session.timedOut = true
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"monkey/evaluator"
+	"monkey/stdlib"
+)
+
+// 测试用的客户端，后台持续读取服务端的输出，避免net.Pipe的同步写入互相阻塞
+type client struct {
+	conn   net.Conn
+	mu     sync.Mutex
+	output strings.Builder
+	closed chan struct{} //服务端关闭连接后关闭
+}
+
+func newClient(conn net.Conn) *client {
+	c := &client{conn: conn, closed: make(chan struct{})}
+	go func() {
+		defer close(c.closed)
+		buf := make([]byte, 1024)
+		for {
+			n, err := conn.Read(buf)
+			c.mu.Lock()
+			c.output.Write(buf[:n])
+			c.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return c
+}
+
+func (c *client) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.output.String()
+}
+
+func (c *client) send(t *testing.T, line string) {
+	t.Helper()
+	if _, err := c.conn.Write([]byte(line + "\n")); err != nil {
+		t.Fatalf("send %q: %s", line, err)
+	}
+}
+
+// 等待输出中出现want
+func (c *client) expect(t *testing.T, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(c.String(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("output does not contain %q. got=%q", want, c.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 等待服务端关闭连接
+func (c *client) expectClosed(t *testing.T) {
+	t.Helper()
+	select {
+	case <-c.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("connection not closed. output=%q", c.String())
+	}
+}
+
+func pipeSession(s *Server) *client {
+	serverSide, clientSide := net.Pipe()
+	go s.ServeConn(serverSide)
+	return newClient(clientSide)
+}
+
+func TestSessionsAreIsolated(t *testing.T) {
+	s := New(Config{})
+	defer s.Close()
+
+	a := pipeSession(s)
+	a.expect(t, "session 1")
+	b := pipeSession(s)
+	b.expect(t, "session 2")
+
+	a.send(t, "let x = 40;")
+	a.send(t, "x + 2")
+	a.expect(t, "求值结果:\n42\n")
+
+	b.send(t, "x")
+	b.expect(t, "identifier not found: x")
+
+	a.send(t, ":load /etc/passwd")
+	a.expect(t, "command :load is disabled in this session")
+
+	a.conn.Close()
+	a.expectClosed(t)
+	b.conn.Close()
+	b.expectClosed(t)
+}
+
+func TestSessionLimits(t *testing.T) {
+	s := New(Config{Limits: evaluator.Limits{MaxSteps: 5000, MaxDepth: 100}})
+	defer s.Close()
+
+	c := pipeSession(s)
+	c.send(t, "let loop = fn(n) { loop(n + 1) };")
+	c.send(t, "loop(0)")
+	c.expect(t, "maximum call depth exceeded: 100")
+	c.send(t, "let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } };")
+	c.send(t, "count(50)")
+	c.expect(t, "求值结果:\n50\n")
+	c.send(t, "count(50) + count(50) + count(50) + count(50) + count(50) + count(50) + count(50) + count(50) + count(50) + count(50)")
+	c.expect(t, "execution budget exceeded: more than 5000 steps")
+	c.conn.Close()
+	c.expectClosed(t)
+}
+
+func TestSessionSizeLimit(t *testing.T) {
+	s := New(Config{Limits: evaluator.Limits{MaxSize: 1 << 20}, Options: stdlib.Options()})
+	defer s.Close()
+
+	c := pipeSession(s)
+	c.send(t, `let double = fn(s, n) { if (n == 0) { s } else { double(s + s, n - 1) } };`)
+	c.send(t, `double("x", 20) == double("x", 20)`)
+	c.expect(t, "求值结果:\ntrue\n")
+	c.send(t, `double("x", 40)`)
+	c.expect(t, "size limit exceeded: 2097152 > 1048576")
+	c.send(t, `import "std/strings";`)
+	c.send(t, `len(strings.repeat("ab", 600000))`)
+	c.expect(t, "size limit exceeded: 1200000 > 1048576")
+	c.send(t, `import "std/iter"; len(iter.range(2000000))`)
+	c.expect(t, "size limit exceeded: 1048577 > 1048576")
+	c.conn.Close()
+	c.expectClosed(t)
+}
+
+func TestIdleTimeout(t *testing.T) {
+	s := New(Config{IdleTimeout: 50 * time.Millisecond})
+	defer s.Close()
+
+	c := pipeSession(s)
+	c.expectClosed(t)
+	if got := c.String(); !strings.Contains(got, "idle for more than 50ms, disconnected") {
+		t.Errorf("expected idle message. got=%q", got)
+	}
+}
+
+func TestMaxSessions(t *testing.T) {
+	s := New(Config{MaxSessions: 1})
+	defer s.Close()
+
+	first := pipeSession(s)
+	first.expect(t, "session 1")
+	second := pipeSession(s)
+	second.expectClosed(t)
+	if got := second.String(); got != "too many sessions (max 1), try again later\n" {
+		t.Errorf("wrong rejection message. got=%q", got)
+	}
+
+	first.conn.Close()
+	first.expectClosed(t)
+	//第一个会话结束后可以建立新会话
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		third := pipeSession(s)
+		third.expect(t, "\n")
+		if strings.Contains(third.String(), "session 2") {
+			third.conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("session slot not released. got=%q", third.String())
+		}
+	}
+}
+
+func testListener(t *testing.T, network, address string) {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		t.Skipf("cannot listen on %s %s: %s", network, address, err)
+	}
+	s := New(Config{})
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(l) }()
+
+	conn, err := net.Dial(network, l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(conn)
+	c.send(t, "let add = fn(a, b) {")
+	c.send(t, "  a + b")
+	c.send(t, "};")
+	c.send(t, "add(1, 2)")
+	c.expect(t, "求值结果:\n3\n>> ")
+
+	//空闲的会话在Shutdown时立即结束
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %s", err)
+	}
+	c.expectClosed(t)
+	c.expect(t, "server is shutting down, bye\n")
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("Serve returned %v, want ErrServerClosed", err)
+	}
+	if _, err := net.Dial(network, l.Addr().String()); err == nil {
+		t.Errorf("listener still accepting connections after Shutdown")
+	}
+}
+
+func TestServeTCP(t *testing.T) {
+	testListener(t, "tcp", "127.0.0.1:0")
+}
+
+func TestServeUnix(t *testing.T) {
+	testListener(t, "unix", filepath.Join(t.TempDir(), "monkey.sock"))
+}
+
+// Shutdown等待正在求值的会话输出结果；超过期限时中断求值并返回ctx.Err()
+func TestShutdownWaitsForEvaluation(t *testing.T) {
+	s := New(Config{})
+	c := pipeSession(s)
+	c.send(t, "let spin = fn(n) { if (n == 0) { 0 } else { spin(n - 1) + spin(n - 1) } };")
+	c.send(t, "spin(40)")
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown returned %v, want context.DeadlineExceeded", err)
+	}
+	c.expectClosed(t)
+
+	s = New(Config{})
+	c = pipeSession(s)
+	c.send(t, "let spin = fn(n) { if (n == 0) { 0 } else { spin(n - 1) + spin(n - 1) } };")
+	c.send(t, "spin(16)")
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown: %s", err)
+	}
+	c.expectClosed(t)
+	c.expect(t, "求值结果:\n0\n")
+}
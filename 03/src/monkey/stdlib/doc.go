@@ -0,0 +1,155 @@
+package stdlib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+// ModuleDoc 一个模块的文档
+type ModuleDoc struct {
+	Name  string //import路径，例：std/strings
+	Doc   string //源文件开头的注释
+	Funcs []FuncDoc
+}
+
+// FuncDoc 模块导出的一个成员的文档
+type FuncDoc struct {
+	Sig    string //调用形式，例：map(xs, f)；不是函数时只有名字
+	Doc    string //export let前紧挨着的注释，或Go实现的函数的说明
+	Native bool   //用Go实现
+}
+
+// Docs 标准库每个模块的文档，按模块名排序，模块的成员按名字排序
+func Docs() ([]ModuleDoc, error) {
+	var docs []ModuleDoc
+	for _, name := range Modules() {
+		m := ModuleDoc{Name: Prefix + "/" + name}
+		for _, f := range natives[name] {
+			m.Funcs = append(m.Funcs, FuncDoc{Sig: f.Sig, Doc: f.Doc, Native: true})
+		}
+		src, err := fs.ReadFile(source, name+sourceExt)
+		if err == nil {
+			doc, funcs, err := sourceDocs(name+sourceExt, string(src))
+			if err != nil {
+				return nil, err
+			}
+			m.Doc = doc
+			m.Funcs = append(m.Funcs, funcs...)
+		}
+		sort.SliceStable(m.Funcs, func(i, j int) bool { return m.Funcs[i].Sig < m.Funcs[j].Sig })
+		docs = append(docs, m)
+	}
+	return docs, nil
+}
+
+// 从Monkey源码中取出文件开头的注释和导出的成员的文档
+func sourceDocs(file, src string) (string, []FuncDoc, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", nil, fmt.Errorf("%s: %s", file, strings.Join(p.Errors(), "; "))
+	}
+	comments := commentsBefore(src)
+
+	var funcs []FuncDoc
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || let == nil || !let.Exported() {
+			continue
+		}
+		sig := let.Name.Value
+		if fl, ok := let.Value.(*ast.FunctionLiteral); ok {
+			params := make([]string, len(fl.Parameters))
+			for i, param := range fl.Parameters {
+				params[i] = param.Value
+			}
+			sig += "(" + strings.Join(params, ", ") + ")"
+		}
+		funcs = append(funcs, FuncDoc{Sig: sig, Doc: comments[let.Export.Line]})
+	}
+	return fileComment(src), funcs, nil
+}
+
+// 按行号：该行之前紧挨着的连续注释行，去掉 // 后用空格连接
+func commentsBefore(src string) map[int]string {
+	docs := map[int]string{}
+	var block []string
+	last := 0 //block中最后一行注释的行号
+	for _, tok := range lexer.TokenizeWithTrivia(src) {
+		switch tok.Type {
+		case token.WHITESPACE:
+			continue
+		case token.COMMENT:
+			if tok.Pos.Line != last+1 {
+				block = nil
+			}
+			block = append(block, commentText(tok.Literal))
+			last = tok.Pos.Line
+			continue
+		}
+		if len(block) > 0 && tok.Pos.Line == last+1 {
+			docs[tok.Pos.Line] = strings.Join(block, " ")
+		}
+		block = nil
+	}
+	return docs
+}
+
+// 文件开头的注释块（到第一个空行为止）
+func fileComment(src string) string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+		lines = append(lines, commentText(line))
+	}
+	return strings.Join(lines, " ")
+}
+
+func commentText(comment string) string {
+	return strings.TrimSpace(strings.TrimPrefix(comment, "//"))
+}
+
+// WriteMarkdown 以Markdown格式输出内置函数和标准库各模块的文档
+func WriteMarkdown(w io.Writer) error {
+	docs, err := Docs()
+	if err != nil {
+		return err
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "# 标准库\n\n")
+	fmt.Fprintf(b, "<!-- 由 go generate 根据 stdlib 的源码生成，不要手动修改 -->\n\n")
+	fmt.Fprintf(b, "用 `import \"%s/<模块>\"` 使用，例：`import \"%s/strings\"; strings.upper(\"monkey\")`。", Prefix, Prefix)
+	fmt.Fprintf(b, "标有 (Go) 的函数用Go实现，其余用Monkey实现。\n\n")
+
+	fmt.Fprintf(b, "## 内置函数\n\n全局环境和每个模块中都可以直接使用。\n\n")
+	for _, f := range builtins {
+		fmt.Fprintf(b, "- `%s` %s\n", f.Sig, f.Doc)
+	}
+	for _, m := range docs {
+		fmt.Fprintf(b, "\n## %s\n\n", m.Name)
+		if m.Doc != "" {
+			fmt.Fprintf(b, "%s\n\n", m.Doc)
+		}
+		for _, f := range m.Funcs {
+			native := ""
+			if f.Native {
+				native = " (Go)"
+			}
+			fmt.Fprintf(b, "- `%s`%s %s\n", f.Sig, native, f.Doc)
+		}
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,85 @@
+// 数组的常用操作。数组不会被修改，返回的都是新数组
+
+// 数组是否为空
+export let isEmpty = fn(xs) { len(xs) == 0 };
+
+// x在xs中第一次出现的下标，没有时返回-1。用==比较，适用于整数、字符串和布尔值
+export let indexOf = fn(xs, x) {
+    let loop = fn(i) {
+        if (i == len(xs)) {
+            return -1;
+        }
+        if (xs[i] == x) {
+            return i;
+        }
+        loop(i + 1)
+    };
+    loop(0)
+};
+
+// xs中是否有等于x的元素
+export let contains = fn(xs, x) { indexOf(xs, x) != -1 };
+
+// xs中等于x的元素个数
+export let count = fn(xs, x) {
+    let loop = fn(i, n) {
+        if (i == len(xs)) {
+            return n;
+        }
+        if (xs[i] == x) {
+            return loop(i + 1, n + 1);
+        }
+        loop(i + 1, n)
+    };
+    loop(0, 0)
+};
+
+// 逆序排列的新数组
+export let reverse = fn(xs) {
+    let loop = fn(i, acc) {
+        if (i < 0) {
+            return acc;
+        }
+        loop(i - 1, push(acc, xs[i]))
+    };
+    loop(len(xs) - 1, [])
+};
+
+// 去掉重复的元素，保留每个值第一次出现的位置
+export let unique = fn(xs) {
+    let loop = fn(i, acc) {
+        if (i == len(xs)) {
+            return acc;
+        }
+        if (contains(acc, xs[i])) {
+            return loop(i + 1, acc);
+        }
+        loop(i + 1, push(acc, xs[i]))
+    };
+    loop(0, [])
+};
+
+// 按下标配对：zip([1, 2], ["a", "b"]) 得到 [[1, "a"], [2, "b"]]，长度取较短的数组
+export let zip = fn(xs, ys) {
+    let loop = fn(i, acc) {
+        if (i == len(xs)) {
+            return acc;
+        }
+        if (i == len(ys)) {
+            return acc;
+        }
+        loop(i + 1, push(acc, [xs[i], ys[i]]))
+    };
+    loop(0, [])
+};
+
+// 把数组的数组展开一层：flatten([[1], [2, 3]]) 得到 [1, 2, 3]
+export let flatten = fn(xss) {
+    let loop = fn(i, acc) {
+        if (i == len(xss)) {
+            return acc;
+        }
+        loop(i + 1, concat(acc, xss[i]))
+    };
+    loop(0, [])
+};
@@ -0,0 +1,41 @@
+import "std/collections"
+
+let test_indexOf = fn() {
+    assertEq(collections.indexOf([1, 2, 3], 3), 2);
+    assertEq(collections.indexOf(["a", "b"], "c"), -1);
+    assert(collections.contains([true, false], false));
+    assert(!collections.contains([], 1));
+    assertEq(collections.count([1, 2, 1, 1], 1), 3);
+    assert(collections.isEmpty([]));
+};
+
+let test_reverse = fn() {
+    assertEq(collections.reverse([1, 2, 3]), [3, 2, 1]);
+    assertEq(collections.reverse([]), []);
+};
+
+let test_unique = fn() {
+    assertEq(collections.unique([3, 1, 3, 2, 1]), [3, 1, 2]);
+};
+
+let test_zip = fn() {
+    assertEq(collections.zip([1, 2, 3], ["a", "b"]), [[1, "a"], [2, "b"]]);
+    assertEq(collections.flatten([[1], [], [2, 3]]), [1, 2, 3]);
+};
+
+let test_slice = fn() {
+    let xs = [1, 2, 3, 4];
+    assertEq(collections.slice(xs, 1, 3), [2, 3]);
+    assertEq(collections.slice(xs, -5, 2), [1, 2]);
+    assertEq(collections.slice(xs, 3, 100), [4]);
+    assertEq(collections.slice(xs, 3, 1), []);
+    assertEq(collections.concat(xs, [5]), [1, 2, 3, 4, 5]);
+    assertEq(xs, [1, 2, 3, 4]);
+};
+
+let test_sort = fn() {
+    assertEq(collections.sort([3, -1, 2]), [-1, 2, 3]);
+    assertEq(collections.sort(["b", "c", "a"]), ["a", "b", "c"]);
+    assertError(fn() { collections.sort([1, "a"]) });
+    assertError(fn() { collections.sort([true]) });
+};
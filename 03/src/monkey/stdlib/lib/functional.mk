@@ -0,0 +1,75 @@
+// 函数式编程的常用函数。数组不会被修改，返回的都是新数组
+
+// 对xs的每个元素调用f，结果组成新数组
+export let map = fn(xs, f) {
+    let loop = fn(i, acc) {
+        if (i == len(xs)) {
+            return acc;
+        }
+        loop(i + 1, push(acc, f(xs[i])))
+    };
+    loop(0, [])
+};
+
+// xs中使pred(x)为真的元素组成的新数组
+export let filter = fn(xs, pred) {
+    let loop = fn(i, acc) {
+        if (i == len(xs)) {
+            return acc;
+        }
+        if (pred(xs[i])) {
+            return loop(i + 1, push(acc, xs[i]));
+        }
+        loop(i + 1, acc)
+    };
+    loop(0, [])
+};
+
+// 从init开始依次用f(acc, x)合并xs的元素
+export let reduce = fn(xs, init, f) {
+    let loop = fn(i, acc) {
+        if (i == len(xs)) {
+            return acc;
+        }
+        loop(i + 1, f(acc, xs[i]))
+    };
+    loop(0, init)
+};
+
+// 返回参数本身
+export let identity = fn(x) { x };
+
+// 组合两个函数：compose(f, g)(x) 等于 f(g(x))
+export let compose = fn(f, g) { fn(x) { f(g(x)) } };
+
+// 交换两个参数的顺序：flip(f)(a, b) 等于 f(b, a)
+export let flip = fn(f) { fn(a, b) { f(b, a) } };
+
+// xs中是否有元素使pred(x)为真，空数组为false
+export let any = fn(xs, pred) {
+    let loop = fn(i) {
+        if (i == len(xs)) {
+            return false;
+        }
+        if (pred(xs[i])) {
+            return true;
+        }
+        loop(i + 1)
+    };
+    loop(0)
+};
+
+// xs中是否所有元素都使pred(x)为真，空数组为true
+export let all = fn(xs, pred) {
+    !any(xs, fn(x) { !pred(x) })
+};
+
+// xs中第一个使pred(x)为真的元素，没有时返回null
+export let find = fn(xs, pred) {
+    let loop = fn(i) {
+        if (i < len(xs)) { //没有else：找完时if的值是null
+            if (pred(xs[i])) { xs[i] } else { loop(i + 1) }
+        }
+    };
+    loop(0)
+};
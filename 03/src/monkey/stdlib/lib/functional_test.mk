@@ -0,0 +1,45 @@
+import "std/functional"
+
+let double = fn(x) { x * 2 };
+let isEven = fn(x) { (x / 2) * 2 == x };
+
+let test_map = fn() {
+    assertEq(functional.map([1, 2, 3], double), [2, 4, 6]);
+    assertEq(functional.map([], double), []);
+};
+
+let test_filter = fn() {
+    assertEq(functional.filter([1, 2, 3, 4], isEven), [2, 4]);
+    assertEq(functional.filter([1, 3], isEven), []);
+};
+
+let test_reduce = fn() {
+    assertEq(functional.reduce([1, 2, 3, 4], 0, fn(acc, x) { acc + x }), 10);
+    assertEq(functional.reduce([], 7, fn(acc, x) { acc + x }), 7);
+    assertEq(functional.reduce(["a", "b"], "", fn(acc, x) { acc + x }), "ab");
+};
+
+let test_compose = fn() {
+    let inc = fn(x) { x + 1 };
+    assertEq(functional.compose(double, inc)(5), 12);
+    assertEq(functional.compose(functional.identity, inc)(5), 6);
+    assertEq(functional.flip(fn(a, b) { a - b })(1, 10), 9);
+};
+
+let test_predicates = fn() {
+    assert(functional.any([1, 3, 4], isEven));
+    assert(!functional.any([], isEven));
+    assert(functional.all([2, 4], isEven));
+    assert(functional.all([], isEven));
+    assert(!functional.all([2, 3], isEven));
+};
+
+let test_find = fn() {
+    assertEq(functional.find([1, 4, 6], isEven), 4);
+    assertEq(functional.find([1, 3], isEven), [][0]);
+};
+
+let test_errors = fn() {
+    assertError(fn() { functional.map(1, double) });
+    assertError(fn() { functional.map([1], fn(x) { x + true }) });
+};
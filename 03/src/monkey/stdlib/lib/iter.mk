@@ -0,0 +1,54 @@
+// 生成和截取序列
+
+// 依次以0到n-1调用f，结果组成数组
+export let times = fn(n, f) {
+    let loop = fn(i, acc) {
+        if (!(i < n)) {
+            return acc;
+        }
+        loop(i + 1, push(acc, f(i)))
+    };
+    loop(0, [])
+};
+
+// n个x组成的数组
+export let replicate = fn(n, x) { times(n, fn(i) { x }) };
+
+// [x, f(x), f(f(x)), ...] 共n个元素
+export let iterate = fn(f, x, n) {
+    let loop = fn(i, cur, acc) {
+        if (!(i < n)) {
+            return acc;
+        }
+        loop(i + 1, f(cur), push(acc, cur))
+    };
+    loop(0, x, [])
+};
+
+// 下标和元素配对：enumerate(["a", "b"]) 得到 [[0, "a"], [1, "b"]]
+export let enumerate = fn(xs) { times(len(xs), fn(i) { [i, xs[i]] }) };
+
+// xs的前n个元素，n超过长度时是整个数组
+export let take = fn(xs, n) {
+    let loop = fn(i, acc) {
+        if (!(i < n)) {
+            return acc;
+        }
+        if (!(i < len(xs))) {
+            return acc;
+        }
+        loop(i + 1, push(acc, xs[i]))
+    };
+    loop(0, [])
+};
+
+// 去掉前n个元素后的数组
+export let drop = fn(xs, n) {
+    let loop = fn(i, acc) {
+        if (!(i < len(xs))) {
+            return acc;
+        }
+        loop(i + 1, push(acc, xs[i]))
+    };
+    if (n < 0) { loop(0, []) } else { loop(n, []) }
+};
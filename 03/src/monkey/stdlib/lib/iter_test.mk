@@ -0,0 +1,29 @@
+import "std/iter"
+
+let test_range = fn() {
+    assertEq(iter.range(3), [0, 1, 2]);
+    assertEq(iter.range(2, 5), [2, 3, 4]);
+    assertEq(iter.range(10, 0, -3), [10, 7, 4, 1]);
+    assertEq(iter.range(0), []);
+    assertError(fn() { iter.range(0, 1, 0) });
+    assertError(fn() { iter.range("a") });
+};
+
+let test_times = fn() {
+    assertEq(iter.times(3, fn(i) { i * i }), [0, 1, 4]);
+    assertEq(iter.replicate(2, "x"), ["x", "x"]);
+    assertEq(iter.iterate(fn(x) { x * 2 }, 1, 5), [1, 2, 4, 8, 16]);
+};
+
+let test_enumerate = fn() {
+    assertEq(iter.enumerate(["a", "b"]), [[0, "a"], [1, "b"]]);
+};
+
+let test_take_drop = fn() {
+    let xs = iter.range(5);
+    assertEq(iter.take(xs, 2), [0, 1]);
+    assertEq(iter.take(xs, 10), xs);
+    assertEq(iter.drop(xs, 3), [3, 4]);
+    assertEq(iter.drop(xs, -1), xs);
+    assertEq(iter.drop(xs, 9), []);
+};
@@ -0,0 +1,77 @@
+// 整数运算。语言本身只有 + - * /，取余数用 mod
+
+// 绝对值
+export let abs = fn(n) { if (n < 0) { -n } else { n } };
+
+// 符号：负数为-1，0为0，正数为1
+export let sign = fn(n) {
+    if (n < 0) {
+        return -1;
+    }
+    if (n > 0) { 1 } else { 0 }
+};
+
+// 两个数中较小的一个
+export let min = fn(a, b) { if (b < a) { b } else { a } };
+
+// 两个数中较大的一个
+export let max = fn(a, b) { if (b > a) { b } else { a } };
+
+// 把n限制在[lo, hi]之内
+export let clamp = fn(n, lo, hi) { min(max(n, lo), hi) };
+
+// b的e次方，e小于0时返回0
+export let pow = fn(b, e) {
+    if (e < 0) {
+        return 0;
+    }
+    if (e == 0) {
+        return 1;
+    }
+    let half = pow(b, e / 2);
+    if (mod(e, 2) == 0) { half * half } else { half * half * b }
+};
+
+// 最大公约数，结果不小于0
+export let gcd = fn(a, b) {
+    if (b == 0) {
+        return abs(a);
+    }
+    gcd(b, mod(a, b))
+};
+
+// 最小公倍数，结果不小于0
+export let lcm = fn(a, b) {
+    if (a == 0) {
+        return 0;
+    }
+    abs(a / gcd(a, b) * b)
+};
+
+// 是否是偶数
+export let isEven = fn(n) { mod(n, 2) == 0 };
+
+// 是否是奇数
+export let isOdd = fn(n) { mod(n, 2) != 0 };
+
+// 整数数组的和，空数组为0
+export let sum = fn(xs) {
+    let loop = fn(i, acc) {
+        if (i == len(xs)) {
+            return acc;
+        }
+        loop(i + 1, acc + xs[i])
+    };
+    loop(0, 0)
+};
+
+// 整数数组的积，空数组为1
+export let product = fn(xs) {
+    let loop = fn(i, acc) {
+        if (i == len(xs)) {
+            return acc;
+        }
+        loop(i + 1, acc * xs[i])
+    };
+    loop(0, 1)
+};
@@ -0,0 +1,45 @@
+import "std/math"
+
+let test_basic = fn() {
+    assertEq(math.abs(-3), 3);
+    assertEq(math.sign(-3), -1);
+    assertEq(math.sign(0), 0);
+    assertEq(math.min(2, 5), 2);
+    assertEq(math.max(2, 5), 5);
+    assertEq(math.clamp(12, 0, 10), 10);
+};
+
+let test_mod = fn() {
+    assertEq(math.mod(7, 3), 1);
+    assertEq(math.mod(-7, 3), -1);
+    assertError(fn() { math.mod(1, 0) });
+    assert(math.isEven(4));
+    assert(math.isOdd(-3));
+};
+
+let test_pow = fn() {
+    assertEq(math.pow(2, 10), 1024);
+    assertEq(math.pow(3, 0), 1);
+    assertEq(math.pow(2, -1), 0);
+    assertEq(math.isqrt(50), 7);
+    assertEq(math.isqrt(49), 7);
+    assertEq(math.isqrt(0), 0);
+    assertEq(math.isqrt(1), 1);
+    assertEq(math.isqrt(2), 1);
+    assertEq(math.isqrt(4), 2);
+    assertEq(math.isqrt(9223372036854775807), 3037000499);
+    assertError(fn() { math.isqrt(-1) });
+};
+
+let test_gcd = fn() {
+    assertEq(math.gcd(12, 18), 6);
+    assertEq(math.gcd(-4, 6), 2);
+    assertEq(math.lcm(4, 6), 12);
+    assertEq(math.lcm(0, 6), 0);
+};
+
+let test_aggregate = fn() {
+    assertEq(math.sum([1, 2, 3]), 6);
+    assertEq(math.sum([]), 0);
+    assertEq(math.product([2, 3, 4]), 24);
+};
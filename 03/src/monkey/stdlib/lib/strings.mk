@@ -0,0 +1,32 @@
+// 字符串操作。字符串按字节存储，下标、len和substring的位置都是字节位置
+
+// 字符串是否为空
+export let isEmpty = fn(s) { len(s) == 0 };
+
+// 按换行符切分为行
+export let lines = fn(s) { split(s, "\n") };
+
+// 首字母大写
+export let capitalize = fn(s) { upper(substring(s, 0, 1)) + substring(s, 1, len(s)) };
+
+// 在左边用pad补足到至少n个字节，pad为空时不补
+export let padLeft = fn(s, n, pad) {
+    if (!(len(s) < n)) {
+        return s;
+    }
+    if (len(pad) == 0) {
+        return s;
+    }
+    padLeft(pad + s, n, pad)
+};
+
+// 在右边用pad补足到至少n个字节，pad为空时不补
+export let padRight = fn(s, n, pad) {
+    if (!(len(s) < n)) {
+        return s;
+    }
+    if (len(pad) == 0) {
+        return s;
+    }
+    padRight(s + pad, n, pad)
+};
@@ -0,0 +1,47 @@
+import "std/strings"
+
+let test_split_join = fn() {
+    assertEq(strings.split("a,b,,c", ","), ["a", "b", "", "c"]);
+    assertEq(strings.join(["a", "b"], "-"), "a-b");
+    assertEq(strings.lines("x\ny"), ["x", "y"]);
+    assertError(fn() { strings.join([1], "") });
+};
+
+let test_case = fn() {
+    assertEq(strings.upper("abc"), "ABC");
+    assertEq(strings.lower("ABC"), "abc");
+    assertEq(strings.capitalize("monkey"), "Monkey");
+    assertEq(strings.capitalize(""), "");
+    assertEq(strings.trim("  hi \t"), "hi");
+};
+
+let test_search = fn() {
+    assert(strings.contains("monkey", "key"));
+    assert(strings.hasPrefix("monkey", "mon"));
+    assert(!strings.hasSuffix("monkey", "mon"));
+    assertEq(strings.indexOf("monkey", "k"), 3);
+    assertEq(strings.indexOf("monkey", "z"), -1);
+};
+
+let test_build = fn() {
+    assertEq(strings.replace("a-b-c", "-", "+"), "a+b+c");
+    assertEq(strings.repeat("ab", 3), "ababab");
+    assertEq(strings.padLeft("7", 3, "0"), "007");
+    assertEq(strings.padRight("ab", 4, "."), "ab..");
+    assertEq(strings.padLeft("abc", 2, " "), "abc");
+    assertError(fn() { strings.repeat("a", -1) });
+};
+
+let test_chars = fn() {
+    assertEq(strings.chars("héllo"), ["h", "é", "l", "l", "o"]);
+    assertEq(strings.substring("monkey", 3, 100), "key");
+    assert(strings.isEmpty(""));
+};
+
+let test_convert = fn() {
+    assertEq(strings.toInt("42"), 42);
+    assertEq(strings.toInt("-7"), -7);
+    assertError(fn() { strings.toInt("4x") });
+    assertEq(str(12) + str([1, "a"]), "12[1, a]");
+    assertEq(type("a"), "STRING");
+};
@@ -0,0 +1,442 @@
+package stdlib
+
+import (
+	"fmt"
+	"monkey/evaluator"
+	"monkey/object"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 内置函数，全局环境和每个模块中都可以使用
+var builtins = []Func{
+	{"len", "len(x)", "字符串的字节数或数组的元素个数", builtinLen},
+	{"push", "push(xs, x)", "在数组末尾加上x得到的新数组，xs不变", builtinPush},
+	{"first", "first(xs)", "数组的第一个元素，空数组返回null", builtinFirst},
+	{"last", "last(xs)", "数组的最后一个元素，空数组返回null", builtinLast},
+	{"rest", "rest(xs)", "去掉第一个元素的新数组，空数组返回null", builtinRest},
+	{"str", "str(x)", "值的字符串形式，与REPL的输出相同", builtinStr},
+	{"type", "type(x)", "值的类型名，例：INTEGER、STRING、ARRAY", builtinType},
+}
+
+// 模块中Go实现的函数，按模块名
+var natives = map[string][]Func{
+	"strings": {
+		{"split", "split(s, sep)", "按sep切分字符串，sep为空时切分为单个字符", stringsSplit},
+		{"join", "join(xs, sep)", "用sep连接字符串数组", stringsJoin},
+		{"upper", "upper(s)", "转换为大写", stringFunc("upper", strings.ToUpper)},
+		{"lower", "lower(s)", "转换为小写", stringFunc("lower", strings.ToLower)},
+		{"trim", "trim(s)", "去掉首尾的空白", stringFunc("trim", strings.TrimSpace)},
+		{"contains", "contains(s, sub)", "s中是否包含sub", stringPredicate("contains", strings.Contains)},
+		{"hasPrefix", "hasPrefix(s, prefix)", "s是否以prefix开头", stringPredicate("hasPrefix", strings.HasPrefix)},
+		{"hasSuffix", "hasSuffix(s, suffix)", "s是否以suffix结尾", stringPredicate("hasSuffix", strings.HasSuffix)},
+		{"indexOf", "indexOf(s, sub)", "sub在s中第一次出现的字节位置，没有时返回-1", stringsIndexOf},
+		{"replace", "replace(s, old, new)", "把s中所有的old替换为new", stringsReplace},
+		{"repeat", "repeat(s, n)", "s重复n次", stringsRepeat},
+		{"substring", "substring(s, start, end)", "字节位置[start, end)的子串，位置超出范围时截断到字符串两端", stringsSubstring},
+		{"chars", "chars(s)", "按字符（UTF-8）拆分为字符串数组", stringsChars},
+		{"toInt", "toInt(s)", "把十进制数字串转换为整数，格式错误时返回错误", stringsToInt},
+	},
+	"math": {
+		{"mod", "mod(a, b)", "a除以b的余数，符号与a相同；b为0时返回错误", mathMod},
+		{"isqrt", "isqrt(n)", "n的整数平方根（向下取整），n为负数时返回错误", mathIsqrt},
+	},
+	"iter": {
+		{"range", "range(end) / range(start, end) / range(start, end, step)", "从start（默认0）开始、每次增加step（默认1）、不包括end的整数数组", iterRange},
+	},
+	"collections": {
+		{"slice", "slice(xs, start, end)", "下标[start, end)的元素组成的新数组，下标超出范围时截断到数组两端", collectionsSlice},
+		{"concat", "concat(xs, ys)", "连接两个数组得到的新数组", collectionsConcat},
+		{"sort", "sort(xs)", "按升序排列的新数组，元素必须都是整数或都是字符串", collectionsSort},
+	},
+}
+
+// range返回的数组的最大长度，避免一次调用耗尽内存
+const maxRange = 1 << 24
+
+// 结果的长度为n时检查解释器的大小限制（见evaluator.Limits.MaxSize），没有限制时返回nil
+func checkSize(c object.Caller, n int) *object.Error {
+	if sc, ok := c.(object.SizeChecker); ok {
+		return sc.CheckSize(n)
+	}
+	return nil
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func wrongArgs(name string, want, got int) *object.Error {
+	return newError("wrong number of arguments to %s: want=%d, got=%d", name, want, got)
+}
+
+func wrongType(name string, want object.ObjectType, got object.Object) *object.Error {
+	return newError("argument to %s must be %s, got %s", name, want, got.Type())
+}
+
+func nativeBool(b bool) object.Object {
+	if b {
+		return evaluator.TRUE
+	}
+	return evaluator.FALSE
+}
+
+// 检查参数个数和类型，types中的每一项是对应参数的类型
+func checkArgs(name string, args []object.Object, types ...object.ObjectType) *object.Error {
+	if len(args) != len(types) {
+		return wrongArgs(name, len(types), len(args))
+	}
+	for i, t := range types {
+		if args[i].Type() != t {
+			return wrongType(name, t, args[i])
+		}
+	}
+	return nil
+}
+
+func builtinLen(c object.Caller, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongArgs("len", 1, len(args))
+	}
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(len(arg.Value))}
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
+	default:
+		return newError("argument to len not supported, got %s", arg.Type())
+	}
+}
+
+func builtinPush(c object.Caller, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return wrongArgs("push", 2, len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return wrongType("push", object.ARRAY_OBJ, args[0])
+	}
+	if err := checkSize(c, len(arr.Elements)+1); err != nil {
+		return err
+	}
+	elements := make([]object.Object, len(arr.Elements), len(arr.Elements)+1)
+	copy(elements, arr.Elements)
+	return &object.Array{Elements: append(elements, args[1])}
+}
+
+func builtinFirst(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("first", args, object.ARRAY_OBJ); err != nil {
+		return err
+	}
+	elements := args[0].(*object.Array).Elements
+	if len(elements) == 0 {
+		return evaluator.NULL
+	}
+	return elements[0]
+}
+
+func builtinLast(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("last", args, object.ARRAY_OBJ); err != nil {
+		return err
+	}
+	elements := args[0].(*object.Array).Elements
+	if len(elements) == 0 {
+		return evaluator.NULL
+	}
+	return elements[len(elements)-1]
+}
+
+func builtinRest(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("rest", args, object.ARRAY_OBJ); err != nil {
+		return err
+	}
+	elements := args[0].(*object.Array).Elements
+	if len(elements) == 0 {
+		return evaluator.NULL
+	}
+	return &object.Array{Elements: append([]object.Object{}, elements[1:]...)}
+}
+
+func builtinStr(c object.Caller, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongArgs("str", 1, len(args))
+	}
+	if s, ok := args[0].(*object.String); ok {
+		return s
+	}
+	return &object.String{Value: args[0].Inspect()}
+}
+
+func builtinType(c object.Caller, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongArgs("type", 1, len(args))
+	}
+	return &object.String{Value: string(args[0].Type())}
+}
+
+// 一个字符串参数、返回字符串的函数
+func stringFunc(name string, f func(string) string) object.BuiltinFunction {
+	return func(c object.Caller, args ...object.Object) object.Object {
+		if err := checkArgs(name, args, object.STRING_OBJ); err != nil {
+			return err
+		}
+		return &object.String{Value: f(args[0].(*object.String).Value)}
+	}
+}
+
+// 两个字符串参数、返回布尔值的函数
+func stringPredicate(name string, f func(string, string) bool) object.BuiltinFunction {
+	return func(c object.Caller, args ...object.Object) object.Object {
+		if err := checkArgs(name, args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+		return nativeBool(f(args[0].(*object.String).Value, args[1].(*object.String).Value))
+	}
+}
+
+func stringArray(values []string) *object.Array {
+	elements := make([]object.Object, len(values))
+	for i, v := range values {
+		elements[i] = &object.String{Value: v}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func stringsSplit(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("split", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+		return err
+	}
+	s, sep := args[0].(*object.String).Value, args[1].(*object.String).Value
+	n := strings.Count(s, sep) + 1
+	if sep == "" {
+		n = utf8.RuneCountInString(s)
+	}
+	if err := checkSize(c, n); err != nil {
+		return err
+	}
+	return stringArray(strings.Split(s, sep))
+}
+
+func stringsJoin(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("join", args, object.ARRAY_OBJ, object.STRING_OBJ); err != nil {
+		return err
+	}
+	elements := args[0].(*object.Array).Elements
+	sep := args[1].(*object.String).Value
+	parts := make([]string, len(elements))
+	size := 0
+	for i, e := range elements {
+		s, ok := e.(*object.String)
+		if !ok {
+			return newError("join: element %d must be STRING, got %s", i, e.Type())
+		}
+		parts[i] = s.Value
+		size += len(s.Value)
+	}
+	if len(parts) > 1 {
+		size += len(sep) * (len(parts) - 1)
+	}
+	if err := checkSize(c, size); err != nil {
+		return err
+	}
+	return &object.String{Value: strings.Join(parts, sep)}
+}
+
+func stringsIndexOf(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("indexOf", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+		return err
+	}
+	return &object.Integer{Value: int64(strings.Index(args[0].(*object.String).Value, args[1].(*object.String).Value))}
+}
+
+func stringsReplace(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("replace", args, object.STRING_OBJ, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+		return err
+	}
+	s, old, new := args[0].(*object.String).Value, args[1].(*object.String).Value, args[2].(*object.String).Value
+	if err := checkSize(c, len(s)+strings.Count(s, old)*(len(new)-len(old))); err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ReplaceAll(s, old, new)}
+}
+
+func stringsRepeat(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("repeat", args, object.STRING_OBJ, object.INTEGER_OBJ); err != nil {
+		return err
+	}
+	s, n := args[0].(*object.String).Value, args[1].(*object.Integer).Value
+	if n < 0 {
+		return newError("repeat: negative count %d", n)
+	}
+	if len(s) > 0 && n > maxRange/int64(len(s)) {
+		return newError("repeat: result too long")
+	}
+	if err := checkSize(c, len(s)*int(n)); err != nil {
+		return err
+	}
+	return &object.String{Value: strings.Repeat(s, int(n))}
+}
+
+// 把[start, end)截断到[0, n]之内，start > end时得到空范围
+func clampRange(start, end int64, n int) (int, int) {
+	clamp := func(i int64) int {
+		if i < 0 {
+			return 0
+		}
+		if i > int64(n) {
+			return n
+		}
+		return int(i)
+	}
+	s, e := clamp(start), clamp(end)
+	if s > e {
+		s = e
+	}
+	return s, e
+}
+
+func stringsSubstring(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("substring", args, object.STRING_OBJ, object.INTEGER_OBJ, object.INTEGER_OBJ); err != nil {
+		return err
+	}
+	s := args[0].(*object.String).Value
+	start, end := clampRange(args[1].(*object.Integer).Value, args[2].(*object.Integer).Value, len(s))
+	return &object.String{Value: s[start:end]}
+}
+
+func stringsChars(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("chars", args, object.STRING_OBJ); err != nil {
+		return err
+	}
+	s := args[0].(*object.String).Value
+	if err := checkSize(c, utf8.RuneCountInString(s)); err != nil {
+		return err
+	}
+	var chars []string
+	for _, r := range s {
+		chars = append(chars, string(r))
+	}
+	return stringArray(chars)
+}
+
+func stringsToInt(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("toInt", args, object.STRING_OBJ); err != nil {
+		return err
+	}
+	s := args[0].(*object.String).Value
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return newError("toInt: invalid integer %q", s)
+	}
+	return &object.Integer{Value: n}
+}
+
+func mathMod(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("mod", args, object.INTEGER_OBJ, object.INTEGER_OBJ); err != nil {
+		return err
+	}
+	a, b := args[0].(*object.Integer).Value, args[1].(*object.Integer).Value
+	if b == 0 {
+		return newError("division by zero: mod(%d, %d)", a, b)
+	}
+	return &object.Integer{Value: a % b}
+}
+
+func mathIsqrt(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("isqrt", args, object.INTEGER_OBJ); err != nil {
+		return err
+	}
+	n := args[0].(*object.Integer).Value
+	if n < 0 {
+		return newError("isqrt of negative number %d", n)
+	}
+	if n < 2 {
+		return &object.Integer{Value: n}
+	}
+	//牛顿迭代，初值n/2+1不小于结果（写成(n+1)/2在n=MaxInt64时溢出），x单调递减到结果
+	x := n/2 + 1
+	for y := (x + n/x) / 2; y < x; y = (x + n/x) / 2 {
+		x = y
+	}
+	return &object.Integer{Value: x}
+}
+
+func iterRange(c object.Caller, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError("wrong number of arguments to range: want=1 to 3, got=%d", len(args))
+	}
+	bounds := []int64{0, 0, 1}
+	for i, arg := range args {
+		n, ok := arg.(*object.Integer)
+		if !ok {
+			return wrongType("range", object.INTEGER_OBJ, arg)
+		}
+		bounds[i] = n.Value
+	}
+	if len(args) == 1 {
+		bounds[0], bounds[1] = 0, bounds[0]
+	}
+	start, end, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return newError("range: step must not be zero")
+	}
+	var elements []object.Object
+	for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+		if len(elements) == maxRange {
+			return newError("range: more than %d elements", maxRange)
+		}
+		if err := checkSize(c, len(elements)+1); err != nil {
+			return err
+		}
+		elements = append(elements, &object.Integer{Value: i})
+	}
+	return &object.Array{Elements: elements}
+}
+
+func collectionsSlice(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("slice", args, object.ARRAY_OBJ, object.INTEGER_OBJ, object.INTEGER_OBJ); err != nil {
+		return err
+	}
+	elements := args[0].(*object.Array).Elements
+	start, end := clampRange(args[1].(*object.Integer).Value, args[2].(*object.Integer).Value, len(elements))
+	return &object.Array{Elements: append([]object.Object{}, elements[start:end]...)}
+}
+
+func collectionsConcat(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("concat", args, object.ARRAY_OBJ, object.ARRAY_OBJ); err != nil {
+		return err
+	}
+	a, b := args[0].(*object.Array).Elements, args[1].(*object.Array).Elements
+	if err := checkSize(c, len(a)+len(b)); err != nil {
+		return err
+	}
+	elements := make([]object.Object, 0, len(a)+len(b))
+	return &object.Array{Elements: append(append(elements, a...), b...)}
+}
+
+func collectionsSort(c object.Caller, args ...object.Object) object.Object {
+	if err := checkArgs("sort", args, object.ARRAY_OBJ); err != nil {
+		return err
+	}
+	elements := append([]object.Object{}, args[0].(*object.Array).Elements...)
+	for i, e := range elements {
+		switch t := e.Type(); {
+		case t != object.INTEGER_OBJ && t != object.STRING_OBJ:
+			return newError("sort: cannot sort %s at index %d", t, i)
+		case t != elements[0].Type():
+			return newError("sort: cannot compare %s with %s at index %d", elements[0].Type(), t, i)
+		}
+	}
+	sort.SliceStable(elements, func(i, j int) bool {
+		switch a := elements[i].(type) {
+		case *object.Integer:
+			return a.Value < elements[j].(*object.Integer).Value
+		case *object.String:
+			return a.Value < elements[j].(*object.String).Value
+		}
+		return false
+	})
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,102 @@
+package stdlib
+
+// 标准库：import "std/strings" 等模块随解释器一起发布，不需要WithImports，也不读取文件系统。
+//
+// 每个模块由两部分组成：lib/<模块>.mk 中用Monkey编写的函数（嵌入到程序中），
+// 以及natives.go中用Go实现的函数，两者都是模块导出的成员。另有 len、push 等内置函数
+// 在全局环境和每个模块中直接可用。lib/<模块>_test.mk 是标准库自己的测试，用 monkey test 运行。
+// README.md 由 go generate 根据源码中的文档注释生成。
+
+//go:generate go run monkey doc -o README.md
+
+import (
+	"embed"
+	"io/fs"
+	"monkey/evaluator"
+	"monkey/object"
+	"sort"
+	"strings"
+)
+
+// Prefix 标准库模块的import路径前缀
+const Prefix = "std"
+
+const sourceExt = ".mk"
+
+//go:embed lib/*.mk
+var files embed.FS
+
+// 模块的Monkey源文件所在的目录
+var source, _ = fs.Sub(files, "lib")
+
+// Func 标准库中的一个Go实现的函数
+type Func struct {
+	Name string
+	Sig  string //调用形式，例：split(s, sep)
+	Doc  string
+	Fn   object.BuiltinFunction
+}
+
+func (f *Func) builtin() *object.Builtin {
+	return &object.Builtin{Name: f.Name, Fn: f.Fn}
+}
+
+// Options 使用标准库的解释器选项：内置函数和 std/ 下的模块
+func Options() []evaluator.Option {
+	return []evaluator.Option{evaluator.WithBuiltins(Builtins()...), evaluator.WithLibrary(Library())}
+}
+
+// Library 标准库的模块，见evaluator.WithLibrary
+func Library() *evaluator.Library {
+	lib := &evaluator.Library{Prefix: Prefix, FS: source, Natives: map[string][]*object.Builtin{}}
+	for name, funcs := range natives {
+		for i := range funcs {
+			lib.Natives[name] = append(lib.Natives[name], funcs[i].builtin())
+		}
+	}
+	return lib
+}
+
+// Builtins 全局环境和每个模块中都可以直接使用的内置函数
+func Builtins() []*object.Builtin {
+	list := make([]*object.Builtin, len(builtins))
+	for i := range builtins {
+		list[i] = builtins[i].builtin()
+	}
+	return list
+}
+
+// BuiltinFuncs 内置函数及其文档
+func BuiltinFuncs() []Func {
+	return append([]Func{}, builtins...)
+}
+
+// Names 内置函数的名字，供analysis检查时作为预先定义的名字
+func Names() []string {
+	names := make([]string, len(builtins))
+	for i, f := range builtins {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// Modules 标准库中模块的名字（不含前缀），按名字排序
+func Modules() []string {
+	set := map[string]bool{}
+	for name := range natives {
+		set[name] = true
+	}
+	entries, _ := fs.ReadDir(source, ".")
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, sourceExt) && !strings.HasSuffix(name, "_test"+sourceExt) {
+			set[strings.TrimSuffix(name, sourceExt)] = true
+		}
+	}
+	modules := make([]string, 0, len(set))
+	for name := range set {
+		modules = append(modules, name)
+	}
+	sort.Strings(modules)
+	return modules
+}
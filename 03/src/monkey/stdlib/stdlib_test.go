@@ -0,0 +1,70 @@
+package stdlib
+
+import (
+	"bytes"
+	"monkey/evaluator"
+	"monkey/testrunner"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 用monkey test的方式运行lib/*_test.mk
+func TestLibrary(t *testing.T) {
+	files, err := filepath.Glob("lib/*_test.mk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test files in lib")
+	}
+	for _, file := range files {
+		var out bytes.Buffer
+		result := testrunner.RunFile(file, testrunner.Config{Out: &out, Options: Options()})
+		if !result.OK() || result.Passed == 0 {
+			t.Errorf("%s\n%s", result.Status(), out.String())
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "std/functional"; import "std/iter"; functional.map(iter.range(3), fn(x) { x * x })`, "[0, 1, 4]"},
+		{`import s "std/strings"; s.join(s.split("a b", " "), ",")`, "a,b"},
+		{`len("abc") + len([1])`, "4"},
+		{`import "std/strings"; strings.upper`, "builtin function upper"},
+		{`import "std/nope"`, `ERRORimport "std/nope": cannot find module nope in library std`},
+		{`import "std/strings_test"`, `ERRORimport "std/strings_test": cannot find module strings_test in library std`},
+		{`import "lib/math"`, `ERRORimport "lib/math": cannot find module lib/math: importing files is not enabled`},
+		{`import "std/math"; math.mod(1, 0)`, "ERRORdivision by zero: mod(1, 0)"},
+	}
+
+	for _, tt := range tests {
+		result, err := evaluator.New(Options()...).Run(tt.input)
+		if err != nil {
+			t.Errorf("%s: %s", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s: got %s, want %s", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+}
+
+// README.md与源码中的文档一致，不一致时运行 go generate ./stdlib
+func TestREADME(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, buf.Bytes()) {
+		t.Errorf("README.md is out of date, run go generate ./stdlib")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/cover"
+	"monkey/evaluator"
+	"monkey/stdlib"
+	"monkey/testrunner"
+	"os"
+	"regexp"
+)
+
+// monkey test [-run regexp] [-v] [-I dir] [-cover] [paths...] 运行*_test.mk中的测试，有失败时返回非0
+func runTest(args []string) int {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	run := fs.String("run", "", "只运行名字匹配该正则表达式的测试")
+	verbose := fs.Bool("v", false, "输出每个测试的结果")
+	coverage := addCoverFlags(fs)
+	imports := addImportFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey test [-run regexp] [-v] [-I dir] [-cover] [-coverprofile file] [-coverformat text|html|lcov] [files or dirs...]")
+		fmt.Fprintln(fs.Output(), "目录中的*_test.mk都是测试文件，dir/...包括全部子目录，默认为当前目录")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := coverage.check(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	cfg := testrunner.Config{Verbose: *verbose, Out: os.Stdout, ImportPath: *imports, Options: stdlib.Options()}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -run pattern: %s\n", err)
+			return 2
+		}
+		cfg.Run = re
+	}
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	files, err := testrunner.Discover(patterns)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(files) == 0 {
+		fmt.Println("no test files")
+		return 0
+	}
+
+	status := 0
+	var profiles []*cover.Profile
+	for _, file := range files {
+		fileCfg := cfg
+		var cov *cover.Profile
+		if coverage.enabled() {
+			src, err := readSource(file)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			cov = cover.New(file, src)
+			profiles = append(profiles, cov)
+			fileCfg.Options = append(stdlib.Options(), evaluator.WithHooks(cov))
+		}
+
+		result := testrunner.RunFile(file, fileCfg)
+		if !result.OK() {
+			status = 1
+			fmt.Println("FAIL")
+		}
+		line := result.Status()
+		if cov != nil && result.Err == nil {
+			line += "\t" + cover.Summary([]*cover.Profile{cov})
+		}
+		fmt.Println(line)
+	}
+
+	if len(profiles) > 0 && *coverage.profile != "" {
+		if err := coverage.write(os.Stdout, profiles); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return status
+}
@@ -0,0 +1,140 @@
+package testrunner
+
+import (
+	"fmt"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"strings"
+)
+
+// Builtins monkey test在每个测试的全局环境中提供的断言函数，断言失败时返回*object.Error，测试随之停止：
+//
+//	assert(cond)         cond为false或null时失败
+//	assertEq(got, want)  两个值不相等时失败，输出差异
+//	assertError(f)       调用无参函数f，没有出错时失败
+func Builtins() []*object.Builtin {
+	return []*object.Builtin{
+		{Name: "assert", Fn: assert},
+		{Name: "assertEq", Fn: assertEq},
+		{Name: "assertError", Fn: assertError},
+	}
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func wrongArgs(name string, want, got int) *object.Error {
+	return newError("wrong number of arguments to %s: want=%d, got=%d", name, want, got)
+}
+
+func assert(c object.Caller, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongArgs("assert", 1, len(args))
+	}
+	if args[0] == evaluator.FALSE || args[0] == evaluator.NULL {
+		return newError("assertion failed: got %s", args[0].Inspect())
+	}
+	return evaluator.NULL
+}
+
+func assertEq(c object.Caller, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return wrongArgs("assertEq", 2, len(args))
+	}
+	got, want := args[0], args[1]
+	if equal(got, want) {
+		return evaluator.NULL
+	}
+	return newError("values differ (-want +got):\n%s", diff(describe(want), describe(got)))
+}
+
+func assertError(c object.Caller, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return wrongArgs("assertError", 1, len(args))
+	}
+	switch args[0].(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return newError("argument to assertError must be a function, got %s", args[0].Type())
+	}
+	result := c.Call(args[0])
+	if result != nil && result.Type() == object.ERROR_OBJ {
+		return evaluator.NULL
+	}
+	if result == nil {
+		result = evaluator.NULL
+	}
+	return newError("expected an error, got %s", result.Inspect())
+}
+
+// 整数、字符串比较值，数组逐个比较元素，其他对象（布尔值、null是共享的实例）比较是否同一个
+func equal(a, b object.Object) bool {
+	switch x := a.(type) {
+	case *object.Integer:
+		y, ok := b.(*object.Integer)
+		return ok && x.Value == y.Value
+	case *object.String:
+		y, ok := b.(*object.String)
+		return ok && x.Value == y.Value
+	case *object.Array:
+		y, ok := b.(*object.Array)
+		if !ok || len(x.Elements) != len(y.Elements) {
+			return false
+		}
+		for i := range x.Elements {
+			if !equal(x.Elements[i], y.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// 值的类型和内容，类型不同但显示相同时（例：函数）也能看出差异
+func describe(obj object.Object) string {
+	if s, ok := obj.(*object.String); ok { //字符串加上引号，看得出首尾的空白
+		return fmt.Sprintf("%s %s", s.Type(), lexer.Quote(s.Value))
+	}
+	return fmt.Sprintf("%s %s", obj.Type(), obj.Inspect())
+}
+
+// 按行比较want和got，相同的行以两个空格开头，只在want中的以"- "开头，只在got中的以"+ "开头
+func diff(want, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+	//lcs[i][j]是a[i:]和b[j:]的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out = append(out, "  "+a[i])
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			out = append(out, "- "+a[i])
+			i++
+		default:
+			out = append(out, "+ "+b[j])
+			j++
+		}
+	}
+	return strings.Join(out, "\n")
+}
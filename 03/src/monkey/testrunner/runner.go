@@ -0,0 +1,241 @@
+package testrunner
+
+// monkey test的实现：在*_test.mk文件中，顶层的 let test_xxx = fn() {...} 是一个测试。
+// 每个测试使用独立的解释器和全局环境：重新求值整个文件的顶层代码，再调用测试函数，
+// 返回错误（包括断言失败）时测试失败。输出格式参照 go test
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	testPrefix = "test_"
+	fileSuffix = "_test.mk"
+)
+
+// Config 运行测试的配置
+type Config struct {
+	Run        *regexp.Regexp     //只运行名字匹配的测试，nil表示全部
+	Verbose    bool               //输出每个测试的开始和结果，而不只是失败的测试
+	Out        io.Writer          //测试的输出
+	ImportPath []string           //import在测试文件所在目录之后查找模块的目录，见evaluator.WithImports
+	Options    []evaluator.Option //每个测试的解释器的额外配置，例：覆盖率统计、资源限制
+}
+
+// Result 一个测试文件的结果
+type Result struct {
+	File    string
+	Passed  int
+	Failed  int
+	Err     error //文件无法运行（读取失败、语法错误）
+	Elapsed time.Duration
+}
+
+// OK 文件可以运行并且没有失败的测试
+func (r *Result) OK() bool {
+	return r.Err == nil && r.Failed == 0
+}
+
+// Status 文件的结果行，例：ok  	math_test.mk	0.003s
+func (r *Result) Status() string {
+	status := "ok  "
+	if !r.OK() {
+		status = "FAIL"
+	}
+	line := fmt.Sprintf("%s\t%s\t%.3fs", status, r.File, r.Elapsed.Seconds())
+	if r.OK() && r.Passed == 0 {
+		line += " [no tests to run]"
+	}
+	return line
+}
+
+// Discover 找出测试文件：文件直接使用，目录取其中的*_test.mk文件，以/...结尾的目录包括全部子目录
+func Discover(patterns []string) ([]string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		recursive := false
+		if pattern == "..." || strings.HasSuffix(pattern, "/...") {
+			recursive = true
+			pattern = strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/")
+			if pattern == "" {
+				pattern = "."
+			}
+		}
+		info, err := os.Stat(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, pattern)
+			continue
+		}
+		err = filepath.WalkDir(pattern, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && path != pattern && !recursive {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && strings.HasSuffix(path, fileSuffix) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Tests 程序中测试函数的名字，按源码顺序
+func Tests(program *ast.Program) []string {
+	var names []string
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || let == nil || !strings.HasPrefix(let.Name.Value, testPrefix) {
+			continue
+		}
+		if fl, ok := let.Value.(*ast.FunctionLiteral); ok && len(fl.Parameters) == 0 {
+			names = append(names, let.Name.Value)
+		}
+	}
+	return names
+}
+
+// RunFile 运行一个文件中的测试，输出到cfg.Out，不输出文件的结果行（见Result.Status）
+func RunFile(path string, cfg Config) *Result {
+	start := time.Now()
+	r := &Result{File: path}
+	defer func() { r.Elapsed = time.Since(start) }()
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		r.Err = err
+		fmt.Fprintf(cfg.Out, "%s\n", err)
+		return r
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		r.Err = &evaluator.ParseError{Errors: p.Errors()}
+		for _, e := range p.ErrorList() {
+			fmt.Fprintf(cfg.Out, "%s:%s\n", path, e)
+		}
+		return r
+	}
+
+	for _, name := range Tests(program) {
+		if cfg.Run != nil && !cfg.Run.MatchString(name) {
+			continue
+		}
+		if cfg.Verbose {
+			fmt.Fprintf(cfg.Out, "=== RUN   %s\n", name)
+		}
+		testStart := time.Now()
+		opts := append([]evaluator.Option{evaluator.WithImports(cfg.ImportPath...)}, cfg.Options...)
+		failure := runTest(path, string(src), name, opts)
+		elapsed := time.Since(testStart).Seconds()
+		if failure != "" {
+			r.Failed++
+			fmt.Fprintf(cfg.Out, "--- FAIL: %s (%.2fs)\n", name, elapsed)
+			fmt.Fprintln(cfg.Out, indent(failure))
+			continue
+		}
+		r.Passed++
+		if cfg.Verbose {
+			fmt.Fprintf(cfg.Out, "--- PASS: %s (%.2fs)\n", name, elapsed)
+		}
+	}
+	return r
+}
+
+// 失败信息缩进4个空格，多行信息的后续行再缩进4个空格，与go test一致
+func indent(msg string) string {
+	return "    " + strings.ReplaceAll(msg, "\n", "\n        ")
+}
+
+// 在新的解释器中求值文件并调用测试函数，返回失败信息，通过时返回空字符串
+func runTest(path, src, name string, opts []evaluator.Option) string {
+	origins := &origins{path: path, nodes: map[*object.Error]ast.Node{}, files: map[ast.Node]string{}}
+	in := evaluator.New(append([]evaluator.Option{evaluator.WithHooks(origins)}, opts...)...)
+	for _, b := range Builtins() {
+		in.Env().Set(b.Name, b)
+	}
+
+	result, err := in.RunFile(path, src)
+	if err != nil {
+		return err.Error()
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		return origins.describe(path, src, errObj) + " (in top-level code)"
+	}
+	fn, ok := in.Env().Get(name)
+	if !ok {
+		return fmt.Sprintf("%s is not defined", name)
+	}
+	if errObj, ok := in.Call(fn).(*object.Error); ok {
+		return origins.describe(path, src, errObj)
+	}
+	return ""
+}
+
+// 记录每个错误最先出现的节点，用于在失败信息中给出位置
+type origins struct {
+	evaluator.NopHooks
+	path  string
+	nodes map[*object.Error]ast.Node
+	files map[ast.Node]string //import的模块中的节点所在的文件
+}
+
+func (o *origins) EnterNode(node ast.Node, env *object.Environment) {
+	program, ok := node.(*ast.Program)
+	if !ok || program.File == "" || program.File == o.path {
+		return
+	}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n != nil {
+			o.files[n] = program.File
+		}
+		return true
+	})
+}
+
+func (o *origins) Error(node ast.Node, err *object.Error) {
+	o.nodes[err] = node
+}
+
+// 带位置的错误信息，断言等函数调用出错时带上调用的源码，例：
+// math_test.mk:3:5: assertEq(add(1, 2), 4): values differ (-want +got): ...
+func (o *origins) describe(path, src string, err *object.Error) string {
+	node, ok := o.nodes[err]
+	if !ok || node.Pos().Line == 0 {
+		return err.Message
+	}
+	if file, ok := o.files[node]; ok { //出错的是模块中的代码
+		data, readErr := os.ReadFile(file)
+		if readErr != nil {
+			return fmt.Sprintf("%s:%s: %s", file, node.Pos(), err.Message)
+		}
+		path, src = file, string(data)
+	}
+	if _, ok := node.(*ast.CallExpression); ok && node.End().Offset <= len(src) {
+		call := src[node.Pos().Offset:node.End().Offset]
+		return fmt.Sprintf("%s:%s: %s: %s", path, node.Pos(), call, err.Message)
+	}
+	return fmt.Sprintf("%s:%s: %s", path, node.Pos(), err.Message)
+}
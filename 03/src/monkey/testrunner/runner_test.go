@@ -0,0 +1,146 @@
+package testrunner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const testFile = `let add = fn(a, b) { a + b };
+let counter = 0;
+
+let test_add = fn() {
+    assertEq(add(1, 2), 3);
+};
+
+let test_isolated = fn() {
+    let counter = counter + 1;
+    assertEq(counter, 1);
+};
+
+let test_fail = fn() {
+    assert(add(1, 1) == 2);
+    assertEq(add(2, 2), 5);
+};
+
+let test_error = fn() {
+    assertError(fn() { 1 / 0 });
+    assertError(fn() { 1 });
+};
+
+let test_panic = fn() { undefined };
+let helper = fn() { assert(false) };
+let test_args = fn(t) { assert(false) };
+`
+
+func writeFile(t *testing.T, dir, name, src string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+var elapsed = regexp.MustCompile(`\(\d+\.\d+s\)`)
+
+func run(t *testing.T, cfg Config) (*Result, string) {
+	t.Helper()
+	path := writeFile(t, t.TempDir(), "math_test.mk", testFile)
+	var out bytes.Buffer
+	cfg.Out = &out
+	r := RunFile(path, cfg)
+	return r, strings.ReplaceAll(elapsed.ReplaceAllString(out.String(), "(0s)"), path, "math_test.mk")
+}
+
+func TestRunFile(t *testing.T) {
+	r, out := run(t, Config{Verbose: true})
+	expected := `=== RUN   test_add
+--- PASS: test_add (0s)
+=== RUN   test_isolated
+--- PASS: test_isolated (0s)
+=== RUN   test_fail
+--- FAIL: test_fail (0s)
+    math_test.mk:15:5: assertEq(add(2, 2), 5): values differ (-want +got):
+        - INTEGER 5
+        + INTEGER 4
+=== RUN   test_error
+--- FAIL: test_error (0s)
+    math_test.mk:20:5: assertError(fn() { 1 }): expected an error, got 1
+=== RUN   test_panic
+--- FAIL: test_panic (0s)
+    math_test.mk:23:25: identifier not found: undefined
+`
+	if out != expected {
+		t.Errorf("wrong output.\nwant:\n%s\ngot:\n%s", expected, out)
+	}
+	if r.Passed != 2 || r.Failed != 3 || r.OK() {
+		t.Errorf("result = %+v", r)
+	}
+	if !strings.HasPrefix(r.Status(), "FAIL\t") {
+		t.Errorf("status = %q", r.Status())
+	}
+}
+
+func TestRunFilter(t *testing.T) {
+	r, out := run(t, Config{Run: regexp.MustCompile("add|isolated")})
+	if out != "" || r.Passed != 2 || !r.OK() {
+		t.Errorf("result = %+v, output:\n%s", r, out)
+	}
+	r, _ = run(t, Config{Run: regexp.MustCompile("nothing")})
+	if !strings.HasSuffix(r.Status(), "[no tests to run]") {
+		t.Errorf("status = %q", r.Status())
+	}
+}
+
+func TestParseError(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "bad_test.mk", "let = 1;")
+	var out bytes.Buffer
+	r := RunFile(path, Config{Out: &out})
+	if r.Err == nil || r.OK() || !strings.Contains(out.String(), "bad_test.mk:1:5:") {
+		t.Errorf("result = %+v, output:\n%s", r, out.String())
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a_test.mk", "b.mk", "sub/c_test.mk", "sub/deep/d_test.mk"} {
+		writeFile(t, dir, name, "")
+	}
+	tests := []struct {
+		patterns []string
+		expected []string
+	}{
+		{[]string{dir}, []string{"a_test.mk"}},
+		{[]string{dir + "/..."}, []string{"a_test.mk", "sub/c_test.mk", "sub/deep/d_test.mk"}},
+		{[]string{dir + "/sub", dir + "/b.mk"}, []string{"b.mk", "sub/c_test.mk"}},
+	}
+	for _, tt := range tests {
+		files, err := Discover(tt.patterns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, f := range files {
+			rel, _ := filepath.Rel(dir, f)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("Discover(%v) = %v, want %v", tt.patterns, got, tt.expected)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	got := diff("a\nb\nc", "a\nx\nc\nd")
+	expected := "  a\n- b\n+ x\n  c\n+ d"
+	if got != expected {
+		t.Errorf("diff:\n%s\nwant:\n%s", got, expected)
+	}
+}
@@ -1,51 +1,103 @@
 package token
 
-type TokenType string
+import (
+	"fmt"
+	"sort"
+)
+
+//go:generate stringer -type=TokenType -linecomment
+type TokenType int
 
 type Token struct { //文本中读取出的单个字符串的类型和值
-	Type    TokenType
-	Literal string
+	Type    TokenType `json:"type"`
+	Literal string    `json:"literal"`
+	Pos     Position  `json:"pos"` //词法单元第一个字符在源码中的位置
 }
 
+// Position 源码位置，Line和Column从1开始计数，Column按字节计算
+type Position struct {
+	Offset int `json:"offset"` //字节偏移，从0开始
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// 词法单元类型是整数枚举，语法分析器可以直接用它索引解析函数表和优先级表。
+// 行尾注释是String()的返回值，增删类型后需重新运行 go generate
 const (
-	ILIEGAL = "ILIEGAL"
-	EOF     = "EOF"
+	ILIEGAL TokenType = iota // ILIEGAL
+	EOF                      // EOF
+
+	//琐碎内容，仅在保留琐碎内容模式下由词法分析器产生，语法分析器看不到
+	WHITESPACE // WHITESPACE
+	COMMENT    // COMMENT
 
-	//标识符+字面量
-	IDENT = "IDENT" //字母或下划线组成的用户定义标识符
-	INT   = "INT"
+	//标识符+字面量，IDENT是字母或下划线组成的用户定义标识符
+	IDENT  // IDENT
+	INT    // INT
+	STRING // STRING
 	//运算符
-	ASSIGN = "="
-	PLUS   = "+"
+	ASSIGN // =
+	PLUS   // +
 
-	MINUS    = "-"
-	BANG     = "!"
-	ASTERISK = "*"
-	SLASH    = "/"
+	MINUS    // -
+	BANG     // !
+	ASTERISK // *
+	SLASH    // /
 
-	LT = "<"
-	GT = ">"
+	LT // <
+	GT // >
 	//双字
-	EQ     = "=="
-	NOT_EQ = "!="
+	EQ     // ==
+	NOT_EQ // !=
+	ARROW  // ->
 	//分隔符
-	COMMA     = ","
-	SEMICOLON = ";"
-	LPAREN    = "("
-	RPAREN    = ")"
-	LBRACE    = "{"
-	RBRACE    = "}"
+	COMMA     // ,
+	SEMICOLON // ;
+	COLON     // :
+	DOT       // .
+	LPAREN    // (
+	RPAREN    // )
+	LBRACE    // {
+	RBRACE    // }
+	LBRACKET  // [
+	RBRACKET  // ]
 	//关键字
-	FUNCTION = "FUNCTION"
-	LET      = "LET"
-
-	TRUE   = "TRUE"
-	FALSE  = "FALSE"
-	IF     = "IF"
-	ELSE   = "ELSE"
-	RETURN = "RETURN"
+	FUNCTION // FUNCTION
+	LET      // LET
+
+	TRUE   // TRUE
+	FALSE  // FALSE
+	IF     // IF
+	ELSE   // ELSE
+	RETURN // RETURN
+	MACRO  // MACRO
+	IMPORT // IMPORT
+	EXPORT // EXPORT
 )
 
+// NumTypes 词法单元类型总数，用于定义按类型索引的表
+const NumTypes = len(_TokenType_index) - 1
+
+// MarshalText 以String()的文本编码类型，JSON中显示为 "IDENT"、"+" 等
+func (t TokenType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText 由String()的文本还原类型
+func (t *TokenType) UnmarshalText(text []byte) error {
+	for i := 0; i < NumTypes; i++ {
+		if TokenType(i).String() == string(text) {
+			*t = TokenType(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown token type %q", text)
+}
+
 var keywords = map[string]TokenType{ //关键字
 	"fn":     FUNCTION,
 	"let":    LET,
@@ -54,6 +106,19 @@ var keywords = map[string]TokenType{ //关键字
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"macro":  MACRO,
+	"import": IMPORT,
+	"export": EXPORT,
+}
+
+// Keywords 返回所有关键字，按字母顺序排列，例：供REPL补全
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for w := range keywords {
+		words = append(words, w)
+	}
+	sort.Strings(words)
+	return words
 }
 
 func LookUpIdent(ident string) TokenType { //区分关键字和用户定义标识符
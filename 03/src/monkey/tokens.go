@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"monkey/lexer"
+	"monkey/token"
+	"os"
+	"text/tabwriter"
+)
+
+// monkey tokens [-json] [-trivia] file.mk 输出源码的全部词法单元
+func runTokens(args []string) int {
+	fs := flag.NewFlagSet("tokens", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "以JSON格式输出")
+	trivia := fs.Bool("trivia", false, "同时输出空白和注释")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey tokens [-json] [-trivia] file.mk")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	src, err := readSource(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var tokens []token.Token
+	if *trivia {
+		tokens = lexer.TokenizeWithTrivia(src)
+	} else {
+		tokens = lexer.Tokenize(src)
+	}
+
+	if *asJSON {
+		err = writeTokensJSON(os.Stdout, tokens)
+	} else {
+		err = writeTokensTable(os.Stdout, tokens)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// 读取源码文件，"-"表示标准输入
+func readSource(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	return string(data), err
+}
+
+// 表格输出：位置 类型 字面量
+func writeTokensTable(out io.Writer, tokens []token.Token) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POS\tTYPE\tLITERAL")
+	for _, tok := range tokens {
+		fmt.Fprintf(w, "%s\t%s\t%q\n", tok.Pos, tok.Type, tok.Literal)
+	}
+	return w.Flush()
+}
+
+type tokenJSON struct {
+	Type    string `json:"type"`
+	Literal string `json:"literal"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Offset  int    `json:"offset"`
+}
+
+func writeTokensJSON(out io.Writer, tokens []token.Token) error {
+	items := make([]tokenJSON, 0, len(tokens))
+	for _, tok := range tokens {
+		items = append(items, tokenJSON{
+			Type:    fmt.Sprint(tok.Type),
+			Literal: tok.Literal,
+			Line:    tok.Pos.Line,
+			Column:  tok.Pos.Column,
+			Offset:  tok.Pos.Offset,
+		})
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(items)
+}
@@ -0,0 +1,537 @@
+package typecheck
+
+// Hindley–Milner 风格的类型推导与检查，在运行前发现求值器会报 "type mismatch" 等错误的代码。
+//
+// 类型注解是可选的：let x: int = 5、fn(a: int, b: int) -> int {...}。
+// 没有注解的地方用类型变量表示，通过合一推导；let绑定的值会被泛化，
+// 例：let id = fn(x) { x }; 之后 id(1) 和 id(true) 都可以通过检查。
+//
+// 作用域规则与求值器和analysis包一致：if的{}不产生新作用域，函数体中可以引用外层作用域
+// 中稍后定义的名字（先用单态的占位类型，定义时再合一）。未定义的名字由analysis包报告，
+// 这里给它们一个新的类型变量。宏调用和quote中的代码不做检查。
+
+import (
+	"fmt"
+	"monkey/analysis"
+	"monkey/ast"
+	"sort"
+)
+
+// Config 检查选项
+type Config struct {
+	Globals map[string]Type //预先定义的名字及其类型，其中的类型变量视为多态
+}
+
+// Check 推导并检查程序中的类型，返回按位置排序的诊断信息
+func Check(program *ast.Program, cfg *Config) []analysis.Diagnostic {
+	c := &checker{globals: map[string]*scheme{}, macros: map[string]bool{}}
+	if cfg != nil {
+		for name, t := range cfg.Globals {
+			vars := map[*Var]bool{}
+			freeVars(t, vars)
+			c.globals[name] = &scheme{vars: varList(vars), typ: t}
+		}
+	}
+	for _, s := range program.Statements { //宏只能在顶层定义
+		if let, ok := s.(*ast.LetStatement); ok && let.Name != nil {
+			if _, ok := let.Value.(*ast.MacroLiteral); ok {
+				c.macros[let.Name.Value] = true
+			}
+		}
+	}
+
+	c.openScope()
+	c.hoist(program.Statements)
+	c.statements(program.Statements)
+	c.scope = c.scope.outer
+
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		return c.diags[i].Pos.Offset < c.diags[j].Pos.Offset
+	})
+	return c.diags
+}
+
+type binding struct {
+	scheme      *scheme
+	placeholder bool //提升时创建、尚未执行到定义的绑定
+}
+
+type scope struct {
+	outer *scope
+	names map[string]*binding
+}
+
+type checker struct {
+	scope   *scope
+	result  Type //当前函数的返回类型，顶层为nil
+	globals map[string]*scheme
+	macros  map[string]bool
+	nextVar int
+	diags   []analysis.Diagnostic
+}
+
+func (c *checker) report(node ast.Node, sev analysis.Severity, format string, args ...interface{}) {
+	c.diags = append(c.diags, analysis.Diagnostic{
+		Pos:      node.Pos(),
+		End:      node.End(),
+		Severity: sev,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *checker) fresh() *Var {
+	c.nextVar++
+	return &Var{id: c.nextVar}
+}
+
+func (c *checker) openScope() {
+	c.scope = &scope{outer: c.scope, names: map[string]*binding{}}
+}
+
+func (c *checker) bind(name string, t Type) {
+	c.scope.names[name] = &binding{scheme: &scheme{typ: t}}
+}
+
+// 为作用域中的let绑定预先创建占位类型（不进入内层函数和quote）
+func (c *checker) hoist(stmts []ast.Statement) {
+	for _, s := range stmts {
+		ast.Inspect(s, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FunctionLiteral, *ast.MacroLiteral:
+				return false
+			case *ast.CallExpression:
+				return n.Function.TokenLiteral() != "quote"
+			case *ast.LetStatement:
+				if n.Name != nil {
+					if _, ok := c.scope.names[n.Name.Value]; !ok {
+						c.scope.names[n.Name.Value] = &binding{scheme: &scheme{typ: c.fresh()}, placeholder: true}
+					}
+				}
+			}
+			return true
+		})
+	}
+}
+
+// 合一两个类型，失败时返回false（可能已经绑定了部分类型变量）
+func (c *checker) unify(a, b Type) bool {
+	a, b = prune(a), prune(b)
+	if va, ok := a.(*Var); ok {
+		if va == b {
+			return true
+		}
+		if occurs(va, b) {
+			return false
+		}
+		va.instance = b
+		return true
+	}
+	if _, ok := b.(*Var); ok {
+		return c.unify(b, a)
+	}
+	switch a := a.(type) {
+	case *Basic:
+		return a == b
+	case *Array:
+		ba, ok := b.(*Array)
+		return ok && c.unify(a.Elem, ba.Elem)
+	case *Function:
+		bf, ok := b.(*Function)
+		if !ok || len(a.Params) != len(bf.Params) {
+			return false
+		}
+		for i := range a.Params {
+			if !c.unify(a.Params[i], bf.Params[i]) {
+				return false
+			}
+		}
+		return c.unify(a.Result, bf.Result)
+	}
+	return false
+}
+
+// 泛化：t中不在环境里出现的类型变量成为多态变量
+func (c *checker) generalize(t Type) *scheme {
+	envVars := map[*Var]bool{}
+	for s := c.scope; s != nil; s = s.outer {
+		for _, b := range s.names {
+			free := map[*Var]bool{}
+			freeVars(b.scheme.typ, free)
+			for _, v := range b.scheme.vars {
+				delete(free, v)
+			}
+			for v := range free {
+				envVars[v] = true
+			}
+		}
+	}
+	vars := map[*Var]bool{}
+	freeVars(t, vars)
+	for v := range envVars {
+		delete(vars, v)
+	}
+	return &scheme{vars: varList(vars), typ: t}
+}
+
+func varList(set map[*Var]bool) []*Var {
+	vars := make([]*Var, 0, len(set))
+	for v := range set {
+		vars = append(vars, v)
+	}
+	return vars
+}
+
+// 实例化：多态变量换成新的类型变量
+func (c *checker) instantiate(s *scheme) Type {
+	if len(s.vars) == 0 {
+		return s.typ
+	}
+	mapping := map[*Var]Type{}
+	for _, v := range s.vars {
+		mapping[v] = c.fresh()
+	}
+	return substitute(s.typ, mapping)
+}
+
+func substitute(t Type, mapping map[*Var]Type) Type {
+	switch t := prune(t).(type) {
+	case *Var:
+		if r, ok := mapping[t]; ok {
+			return r
+		}
+		return t
+	case *Array:
+		return &Array{Elem: substitute(t.Elem, mapping)}
+	case *Function:
+		params := make([]Type, len(t.Params))
+		for i, p := range t.Params {
+			params[i] = substitute(p, mapping)
+		}
+		return &Function{Params: params, Result: substitute(t.Result, mapping)}
+	default:
+		return t
+	}
+}
+
+// 类型注解转换为类型
+func (c *checker) annotation(te ast.TypeExpr) Type {
+	switch te := te.(type) {
+	case *ast.NamedType:
+		switch te.Name {
+		case "int":
+			return Int
+		case "bool":
+			return Bool
+		case "string":
+			return Str
+		}
+		c.report(te, analysis.Error, "unknown type: %s", te.Name)
+	case *ast.FunctionType:
+		params := make([]Type, len(te.Parameters))
+		for i, p := range te.Parameters {
+			params[i] = c.annotation(p)
+		}
+		return &Function{Params: params, Result: c.annotation(te.Result)}
+	}
+	return c.fresh()
+}
+
+func (c *checker) lookup(name string) (*binding, bool) {
+	for s := c.scope; s != nil; s = s.outer {
+		if b, ok := s.names[name]; ok {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// 语句序列的值的类型，即最后一条语句的类型
+func (c *checker) statements(stmts []ast.Statement) Type {
+	var t Type = c.fresh()
+	for i, s := range stmts {
+		t = c.statement(s, i == len(stmts)-1)
+	}
+	return t
+}
+
+// 语句的值的类型；let没有值，return不把值交给所在的语句序列，都返回新的类型变量。
+// used为false表示语句的值被丢弃
+func (c *checker) statement(s ast.Statement, used bool) Type {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		c.let(s)
+	case *ast.ImportStatement: //模块的成员在运行时才知道，不做检查
+		c.bind(s.Binding(), c.fresh())
+	case *ast.ReturnStatement:
+		t := c.expr(s.ReturnValue)
+		if c.result != nil && !c.unify(t, c.result) {
+			c.report(s.ReturnValue, analysis.Error, "cannot use %s as %s in return", t, c.result)
+		}
+	case *ast.ExpressionStatement:
+		if ie, ok := s.Expression.(*ast.IfExpression); ok {
+			return c.ifExpr(ie, used)
+		}
+		return c.expr(s.Expression)
+	}
+	return c.fresh()
+}
+
+func (c *checker) let(s *ast.LetStatement) {
+	t := c.expr(s.Value)
+	if s.Type != nil {
+		want := c.annotation(s.Type)
+		if !c.unify(t, want) && s.Value != nil {
+			c.report(s.Value, analysis.Error, "cannot use %s as %s in let %s", t, want, s.Name)
+		}
+		t = want
+	}
+	if s.Name == nil {
+		return
+	}
+	name := s.Name.Value
+	if b, ok := c.scope.names[name]; ok && b.placeholder {
+		placeholder := b.scheme.typ
+		if !c.unify(placeholder, t) {
+			c.report(s.Name, analysis.Error, "%s is used as %s before its declaration as %s", name, placeholder, t)
+		}
+	}
+	delete(c.scope.names, name) //被替换的绑定不参与泛化时的环境
+	c.scope.names[name] = &binding{scheme: c.generalize(t)}
+}
+
+func (c *checker) block(b *ast.BlockStatement, used bool) Type {
+	if b == nil {
+		return c.fresh()
+	}
+	if !used && len(b.Statements) > 0 {
+		c.statements(b.Statements)
+		return c.fresh()
+	}
+	return c.statements(b.Statements)
+}
+
+func (c *checker) expr(e ast.Expression) Type {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.Boolean:
+		return Bool
+	case *ast.StringLiteral:
+		return Str
+	case *ast.ArrayLiteral:
+		return c.array(e)
+	case *ast.IndexExpression:
+		return c.index(e)
+	case *ast.Identifier:
+		if b, ok := c.lookup(e.Value); ok {
+			return c.instantiate(b.scheme)
+		}
+		if s, ok := c.globals[e.Value]; ok {
+			return c.instantiate(s)
+		}
+		return c.fresh() //未定义的名字由analysis报告
+	case *ast.PrefixExpression:
+		return c.prefix(e)
+	case *ast.InfixExpression:
+		return c.infix(e)
+	case *ast.IfExpression:
+		return c.ifExpr(e, true)
+	case *ast.BlockStatement:
+		return c.statements(e.Statements)
+	case *ast.FunctionLiteral:
+		return c.function(e)
+	case *ast.SelectorExpression:
+		c.expr(e.X)
+		return c.fresh()
+	case *ast.CallExpression:
+		return c.call(e)
+	}
+	return c.fresh()
+}
+
+func (c *checker) prefix(e *ast.PrefixExpression) Type {
+	right := c.expr(e.Right)
+	switch e.Operator {
+	case "!": //任何值都有真假
+		return Bool
+	case "-":
+		if !c.unify(right, Int) {
+			c.report(e, analysis.Error, "unknown operator: -%s", objectName(right))
+		}
+		return Int
+	}
+	return c.fresh()
+}
+
+func (c *checker) infix(e *ast.InfixExpression) Type {
+	left := c.expr(e.Left)
+	right := c.expr(e.Right)
+	switch e.Operator {
+	case "==", "!=":
+		if !c.unify(left, right) { //求值器比较不同类型的值不会出错，但结果总是不相等
+			c.report(e, analysis.Warning, "type mismatch: %s %s %s is always %t",
+				objectName(left), e.Operator, objectName(right), e.Operator == "!=")
+		}
+		return Bool
+	case "+", "-", "*", "/", "<", ">":
+		if e.Operator == "+" && (prune(left) == Str || prune(right) == Str) { //字符串拼接
+			if !c.unify(left, Str) || !c.unify(right, Str) {
+				c.report(e, analysis.Error, "type mismatch: %s + %s", objectName(left), objectName(right))
+			}
+			return Str
+		}
+		okLeft := c.unify(left, Int)
+		okRight := c.unify(right, Int)
+		if !okLeft || !okRight {
+			if objectName(left) != objectName(right) {
+				c.report(e, analysis.Error, "type mismatch: %s %s %s", objectName(left), e.Operator, objectName(right))
+			} else {
+				c.report(e, analysis.Error, "unknown operator: %s %s %s", objectName(left), e.Operator, objectName(right))
+			}
+		}
+		if e.Operator == "<" || e.Operator == ">" {
+			return Bool
+		}
+		return Int
+	}
+	return c.fresh()
+}
+
+// 数组字面量：元素类型一致时是 [元素类型]，否则元素类型不做约束
+func (c *checker) array(e *ast.ArrayLiteral) Type {
+	elem := Type(c.fresh())
+	same := true
+	for _, el := range e.Elements {
+		t := c.expr(el)
+		if same && !c.unify(elem, t) {
+			same = false
+		}
+	}
+	if !same {
+		return &Array{Elem: c.fresh()}
+	}
+	return &Array{Elem: elem}
+}
+
+// 索引：数组得到元素类型，字符串得到字符串，下标必须是整数
+func (c *checker) index(e *ast.IndexExpression) Type {
+	left := c.expr(e.Left)
+	index := c.expr(e.Index)
+	if !c.unify(index, Int) {
+		c.report(e.Index, analysis.Error, "index must be INTEGER, got %s", objectName(index))
+	}
+	if prune(left) == Str {
+		return Str
+	}
+	elem := c.fresh()
+	if !c.unify(left, &Array{Elem: elem}) {
+		c.report(e, analysis.Error, "index operator not supported: %s", objectName(left))
+	}
+	return elem
+}
+
+// if的值被使用时两个分支的类型必须一致；没有else时值可能是null，不做约束
+func (c *checker) ifExpr(e *ast.IfExpression, used bool) Type {
+	c.expr(e.Condition) //任何值都有真假
+	cons := c.block(e.Consequence, used)
+	if e.Alternative == nil {
+		return c.fresh()
+	}
+	alt := c.block(e.Alternative, used)
+	if used && !c.unify(cons, alt) {
+		c.report(e, analysis.Error, "if branches have different types: %s and %s", cons, alt)
+	}
+	return cons
+}
+
+func (c *checker) function(fl *ast.FunctionLiteral) Type {
+	outerResult := c.result
+	c.openScope()
+	defer func() {
+		c.scope = c.scope.outer
+		c.result = outerResult
+	}()
+
+	params := make([]Type, len(fl.Parameters))
+	for i, p := range fl.Parameters {
+		if p.Type != nil {
+			params[i] = c.annotation(p.Type)
+		} else {
+			params[i] = c.fresh()
+		}
+		c.bind(p.Value, params[i])
+	}
+	if fl.ReturnType != nil {
+		c.result = c.annotation(fl.ReturnType)
+	} else {
+		c.result = c.fresh()
+	}
+	fnType := &Function{Params: params, Result: c.result}
+	if fl.Body == nil {
+		return fnType
+	}
+
+	c.hoist(fl.Body.Statements)
+	body := c.statements(fl.Body.Statements)
+	if n := len(fl.Body.Statements); n > 0 && !c.unify(body, c.result) {
+		c.report(fl.Body.Statements[n-1], analysis.Error, "cannot use %s as %s as function result", body, c.result)
+	}
+	return fnType
+}
+
+func (c *checker) call(e *ast.CallExpression) Type {
+	if ident, ok := e.Function.(*ast.Identifier); ok {
+		switch {
+		case ident.Value == "quote":
+			for _, a := range e.Arguments {
+				c.unquotes(a)
+			}
+			return c.fresh()
+		case c.macros[ident.Value]: //宏的参数以ast传入，展开后的代码不在这里检查
+			return c.fresh()
+		}
+	}
+
+	callee := prune(c.expr(e.Function))
+	args := make([]Type, len(e.Arguments))
+	for i, a := range e.Arguments {
+		args[i] = c.expr(a)
+	}
+
+	switch fn := callee.(type) {
+	case *Function:
+		if len(fn.Params) != len(args) {
+			c.report(e, analysis.Error, "wrong number of arguments: want=%d, got=%d", len(fn.Params), len(args))
+			return fn.Result
+		}
+		for i, a := range args {
+			if !c.unify(a, fn.Params[i]) {
+				c.report(e.Arguments[i], analysis.Error, "cannot use %s as %s in argument %d to %s",
+					a, fn.Params[i], i+1, e.Function)
+			}
+		}
+		return fn.Result
+	case *Var:
+		result := c.fresh()
+		if !c.unify(fn, &Function{Params: args, Result: result}) { //只有出现检查会失败，例：x(x)
+			c.report(e, analysis.Error, "cannot infer a type for %s: recursive type", e.Function)
+		}
+		return result
+	}
+	c.report(e.Function, analysis.Error, "not a function: %s", objectName(callee))
+	return c.fresh()
+}
+
+// quote中的代码不求值，只检查其中unquote的参数
+func (c *checker) unquotes(node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpression)
+		if !ok || call.Function.TokenLiteral() != "unquote" {
+			return true
+		}
+		for _, a := range call.Arguments {
+			c.expr(a)
+		}
+		return false
+	})
+}
@@ -0,0 +1,88 @@
+package typecheck
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func check(t *testing.T, input string) []string {
+	var msgs []string
+	for _, d := range Check(parse(t, input), nil) {
+		msgs = append(msgs, d.String())
+	}
+	return msgs
+}
+
+func TestWellTyped(t *testing.T) {
+	tests := []string{
+		"let x: int = 5; let y = x * 2 + 1; y > 3",
+		"let add = fn(a: int, b: int) -> int { a + b }; add(1, 2)",
+		"let id = fn(x) { x }; id(1) + 1; !id(true)",
+		"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(10)",
+		"let twice = fn(f, x) { f(f(x)) }; twice(fn(y) { y * 2 }, 5); twice(fn(b) { !b }, true)",
+		"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo: fn(int) -> int = newAdder(2); addTwo(3)",
+		"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(4)",
+		"let f = fn(x) { if (x) { return 1; } 2 }; f(true) + 1",
+		"let f = fn() { if (true) { 1 } else { false }; 5 }; f()",
+		"let a = 1; let a = true; !a",
+		"let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) }; unless(1 > 2, 3, true)",
+		"undefinedName + 1",
+		`let greet = fn(name) { "hello, " + name }; greet("monkey") == "hello, monkey"`,
+		`let xs = [1, 2 * 3]; xs[0] + xs[1]; let s: string = "abc"[1]`,
+		`let mixed = [1, true, "x"]; mixed[0]`,
+	}
+
+	for _, input := range tests {
+		if msgs := check(t, input); len(msgs) != 0 {
+			t.Errorf("%s: unexpected diagnostics: %v", input, msgs)
+		}
+	}
+}
+
+func TestTypeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true", "1:1: error: type mismatch: INTEGER + BOOLEAN"},
+		{"true + false", "1:1: error: unknown operator: BOOLEAN + BOOLEAN"},
+		{"-true", "1:1: error: unknown operator: -BOOLEAN"},
+		{"1 == true", "1:1: warning: type mismatch: INTEGER == BOOLEAN is always false"},
+		{"let x: int = true;", "1:14: error: cannot use bool as int in let x"},
+		{"let x: string = 1;", "1:17: error: cannot use int as string in let x"},
+		{"let x: text = 1;", "1:8: error: unknown type: text"},
+		{`"a" + 1`, "1:1: error: type mismatch: STRING + INTEGER"},
+		{`[1, 2][true]`, "1:8: error: index must be INTEGER, got BOOLEAN"},
+		{`5[0]`, "1:1: error: index operator not supported: INTEGER"},
+		{`let xs = [1, 2]; -xs[0]; !xs[1]; let s: string = xs[0];`, "1:50: error: cannot use int as string in let s"},
+		{"let f = fn(a: int) { a }; f(true)", "1:29: error: cannot use bool as int in argument 1 to f"},
+		{"let f = fn(a) { a + 1 }; f(true)", "1:28: error: cannot use bool as int in argument 1 to f"},
+		{"let f = fn(a, b) { a }; f(1)", "1:25: error: wrong number of arguments: want=2, got=1"},
+		{"let f = fn() -> int { true }", "1:23: error: cannot use bool as int as function result"},
+		{"let f = fn() -> bool { return 1; }", "1:31: error: cannot use int as bool in return"},
+		{"5(1)", "1:1: error: not a function: INTEGER"},
+		{"let v = if (x) { 1 } else { true };", "1:9: error: if branches have different types: int and bool"},
+		{"let f = fn(x) { x(x) }", "1:17: error: cannot infer a type for x: recursive type"},
+		{"let g = fn() { h + 1 }; let h = true;", "1:29: error: h is used as int before its declaration as bool"},
+		{"let f = fn(g: fn(int) -> bool) { g(1) }; f(fn(x) { x })", "1:44: error: cannot use fn(int) -> int as fn(int) -> bool in argument 1 to f"},
+	}
+
+	for _, tt := range tests {
+		msgs := check(t, tt.input)
+		if len(msgs) != 1 || msgs[0] != tt.expected {
+			t.Errorf("%s:\nwant %q\ngot  %q", tt.input, tt.expected, strings.Join(msgs, "; "))
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package typecheck
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type 推导中的类型：基本类型、函数类型或类型变量
+type Type interface {
+	String() string
+}
+
+// Basic 基本类型 int bool string
+type Basic struct {
+	Name string
+}
+
+func (b *Basic) String() string { return b.Name }
+
+var (
+	Int  = &Basic{Name: "int"}
+	Bool = &Basic{Name: "bool"}
+	Str  = &Basic{Name: "string"}
+)
+
+// Array 数组类型 [<元素类型>]，元素类型不一致的数组字面量得到 [t]
+type Array struct {
+	Elem Type
+}
+
+func (a *Array) String() string { return "[" + a.Elem.String() + "]" }
+
+// Function 函数类型 fn(<参数类型>, ...) -> <返回类型>
+type Function struct {
+	Params []Type
+	Result Type
+}
+
+func (f *Function) String() string {
+	params := make([]string, 0, len(f.Params))
+	for _, p := range f.Params {
+		params = append(params, p.String())
+	}
+	return "fn(" + strings.Join(params, ", ") + ") -> " + f.Result.String()
+}
+
+// Var 类型变量，合一时绑定到instance
+type Var struct {
+	id       int
+	instance Type
+}
+
+func (v *Var) String() string {
+	if v.instance != nil {
+		return v.instance.String()
+	}
+	return fmt.Sprintf("t%d", v.id)
+}
+
+// 沿着已绑定的类型变量找到代表的类型
+func prune(t Type) Type {
+	if v, ok := t.(*Var); ok && v.instance != nil {
+		v.instance = prune(v.instance)
+		return v.instance
+	}
+	return t
+}
+
+// 类型变量v是否出现在t中，合一时防止构造无限类型
+func occurs(v *Var, t Type) bool {
+	switch t := prune(t).(type) {
+	case *Var:
+		return t == v
+	case *Array:
+		return occurs(v, t.Elem)
+	case *Function:
+		for _, p := range t.Params {
+			if occurs(v, p) {
+				return true
+			}
+		}
+		return occurs(v, t.Result)
+	}
+	return false
+}
+
+// 收集t中未绑定的类型变量
+func freeVars(t Type, set map[*Var]bool) {
+	switch t := prune(t).(type) {
+	case *Var:
+		set[t] = true
+	case *Array:
+		freeVars(t.Elem, set)
+	case *Function:
+		for _, p := range t.Params {
+			freeVars(p, set)
+		}
+		freeVars(t.Result, set)
+	}
+}
+
+// 运行时的对象类型名，与求值器的错误消息一致，例：INTEGER + BOOLEAN
+func objectName(t Type) string {
+	switch t := prune(t).(type) {
+	case *Basic:
+		switch t {
+		case Int:
+			return "INTEGER"
+		case Bool:
+			return "BOOLEAN"
+		case Str:
+			return "STRING"
+		}
+	case *Array:
+		return "ARRAY"
+	case *Function:
+		return "FUNCTION"
+	}
+	return t.String()
+}
+
+// scheme 多态类型 ∀vars. typ，let绑定的值推导后泛化得到
+type scheme struct {
+	vars []*Var
+	typ  Type
+}